@@ -6,6 +6,7 @@ import (
 	"activity-punch-system/internal/global/httpclient"
 	"activity-punch-system/internal/global/logger"
 	"activity-punch-system/internal/global/middleware"
+	"activity-punch-system/internal/global/pictureBed"
 	"activity-punch-system/internal/global/redis"
 	"activity-punch-system/internal/global/sentry"
 	"activity-punch-system/internal/module"
@@ -71,6 +72,8 @@ func Run() {
 	r.Use(middleware.Recovery())
 
 	r.Static("/static/punch", "./upload/punch")
+	// 本地存储驱动的预签名上传与对象访问
+	pictureBed.RegisterRoutes(r)
 
 	for _, m := range module.Modules {
 		log.Info(fmt.Sprintf("Init Router: %s", m.GetName()))
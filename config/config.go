@@ -41,10 +41,13 @@ type Tracing struct {
 }
 
 type Storage struct {
-	Home string
+	Home            string
+	ExportRetention int `mapstructure:"export_retention" envconfig:"EXPORT_RETENTION"` // 导出文件保留时长（小时），0 表示默认 72 小时
 }
 
 type S3 struct {
+	Driver          string `mapstructure:"driver"`    // 存储驱动：s3（默认）或 local
+	LocalDir        string `mapstructure:"local_dir"` // 本地存储目录，为空时使用 Storage.Home/objects
 	Endpoint        string `mapstructure:"endpoint"`
 	BaseURL         string `mapstructure:"base_url"`
 	BackupHost      string `mapstructure:"backup_host"`
@@ -54,6 +57,11 @@ type S3 struct {
 	SecretAccessKey string `mapstructure:"secret_key"`
 	Prefix          string `mapstructure:"prefix"`
 	UsePathStyle    bool   `mapstructure:"path_style"`
+	MaxImageSize    int    `mapstructure:"max_image_size"` // 打卡图片大小上限（MB），0 表示默认 10MB
+	GCGrace         int    `mapstructure:"gc_grace"`       // 未被引用的对象保留时长（小时），超过后由清理任务删除，0 表示默认 72 小时
+	GCDryRun        bool   `mapstructure:"gc_dry_run"`     // 定时清理只生成报告不删除
+	Private         bool   `mapstructure:"private"`        // 私有桶模式：打卡图片与附件只保存对象 key，读取时签发短期链接
+	SignExpire      int    `mapstructure:"sign_expire"`    // 私有模式下读取链接的有效期（秒），0 表示默认 600 秒
 }
 
 type Sdulogin struct {
@@ -0,0 +1,107 @@
+// Package clock 统一的日期划分服务：按时区与每日切换时刻确定某一时刻属于哪一天，
+// 打卡次数、连续天数、统计与日历等按天汇总的逻辑均通过 Day 计算
+package clock
+
+import (
+	"strconv"
+	"time"
+	_ "time/tzdata" // 内置时区数据，部署环境缺少 zoneinfo 时也能加载 IANA 时区
+)
+
+// DefaultTimezone 活动未设置时区时使用的时区
+const DefaultTimezone = "Asia/Shanghai"
+
+// defaultLocation 默认时区，加载失败时退化为固定的 UTC+8
+var defaultLocation = func() *time.Location {
+	if loc, err := time.LoadLocation(DefaultTimezone); err == nil {
+		return loc
+	}
+	return time.FixedZone("CST", 8*60*60)
+}()
+
+// Day 日界规则：Location 为时区，Rollover 为每日切换时刻（0-23 时），
+// 切换时刻之前的时间计入前一天，例如 Rollover 为 4 时凌晨 3 点的打卡算作前一天
+type Day struct {
+	Location *time.Location
+	Rollover int
+}
+
+// Default 默认日界规则：默认时区，零点切换
+func Default() Day {
+	return Day{Location: defaultLocation}
+}
+
+// New 按时区名与切换时刻创建日界规则，时区为空或无效时使用默认时区，切换时刻超出范围时按零点处理
+func New(timezone string, rollover int) Day {
+	d := Default()
+	if loc, err := LoadLocation(timezone); err == nil {
+		d.Location = loc
+	}
+	if rollover > 0 && rollover < 24 {
+		d.Rollover = rollover
+	}
+	return d
+}
+
+// LoadLocation 加载 IANA 时区，为空时返回默认时区
+func LoadLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return defaultLocation, nil
+	}
+	return time.LoadLocation(timezone)
+}
+
+// Now 当前时间，位于该规则的时区
+func (d Day) Now() time.Time {
+	return time.Now().In(d.Location)
+}
+
+// Date 某一时刻所属的日期，以该日期在当地的零点表示
+func (d Day) Date(t time.Time) time.Time {
+	t = t.In(d.Location).Add(-time.Duration(d.Rollover) * time.Hour)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, d.Location)
+}
+
+// Start 某一时刻所属那一天的开始时刻，即日期当天的切换时刻
+func (d Day) Start(t time.Time) time.Time {
+	return d.StartOf(d.Date(t))
+}
+
+// StartOf 某个日期（只取年月日）这一天的开始时刻
+func (d Day) StartOf(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), d.Rollover, 0, 0, 0, d.Location)
+}
+
+// Today 今天的开始时刻
+func (d Day) Today() time.Time {
+	return d.Start(time.Now())
+}
+
+// Int 某一时刻所属的日期，格式为 yyyymmdd
+func (d Day) Int(t time.Time) int64 {
+	i, _ := strconv.ParseInt(d.Date(t).Format("20060102"), 10, 64)
+	return i
+}
+
+// Index 某一时刻所属日期的天序号，相邻两天相差 1，用于计算连续天数。
+// 按日历日期计算，不受夏令时影响；减 1 与引入日界规则前以北京时间零点 Unix 天数保存的数据保持一致
+func (d Day) Index(t time.Time) int64 {
+	y, m, day := d.Date(t).Date()
+	return time.Date(y, m, day, 0, 0, 0, 0, time.UTC).Unix()/(24*60*60) - 1
+}
+
+// ParseDate 按 layout 解析日期，返回该日期在当地的零点
+func (d Day) ParseDate(layout, value string) (time.Time, error) {
+	return time.ParseInLocation(layout, value, d.Location)
+}
+
+// DateRange 日期范围 [start, end]（yyyymmdd）覆盖的时间段 [from, to)，
+// from 为开始日期的开始时刻，to 为结束日期次日的开始时刻，日期无效时 ok 为 false
+func (d Day) DateRange(start, end int64) (from, to time.Time, ok bool) {
+	s, err1 := d.ParseDate("20060102", strconv.FormatInt(start, 10))
+	e, err2 := d.ParseDate("20060102", strconv.FormatInt(end, 10))
+	if err1 != nil || err2 != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return d.StartOf(s), d.StartOf(e.AddDate(0, 0, 1)), true
+}
@@ -27,6 +27,19 @@ var autoMigrateModels = []any{
 	&model.TotalScore{},
 	&model.Score{},
 	&model.Continuity{},
+	&model.ActivityDailyStat{},
+	&model.ActivityDashboard{},
+	&model.RankSnapshot{},
+	&model.ExportJob{},
+	&model.Participant{},
+	&model.ActivityMember{},
+	&model.Announcement{},
+	&model.AnnouncementRead{},
+	&model.UploadKey{},
+	&model.StorageGCReport{},
+	&model.PunchAttachment{},
+	&model.PunchRevision{},
+	&model.PunchDraft{},
 	// 在这里添加其他模型
 }
 
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/redis"
+	"activity-punch-system/internal/global/response"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// IdempotencyHeader 客户端携带幂等键的请求头，同一用户在同一接口重复使用同一个键时返回首次请求的响应
+const IdempotencyHeader = "Idempotency-Key"
+
+// IdempotencyReplayedHeader 响应为重放结果时设置的响应头
+const IdempotencyReplayedHeader = "Idempotency-Replayed"
+
+const (
+	idempotencyTTL      = 24 * time.Hour         // 保存请求结果的时长
+	idempotencyLockTTL  = 30 * time.Second       // 首个请求处理中的占位时长，超时后允许重新执行
+	idempotencyWait     = 10 * time.Second       // 并发的相同请求等待首个请求完成的最长时间
+	idempotencyPoll     = 100 * time.Millisecond // 等待期间查询结果的间隔
+	idempotencyMaxKey   = 128                    // 幂等键最大长度
+	idempotencyMaxBody  = 1 << 20                // 可保存的最大响应体，超过时不保存结果
+	idempotencyMaxInput = 1 << 20                // 参与指纹计算的最大请求体
+)
+
+// idempotencyRecord 保存在 Redis 中的请求状态，Status 为 0 表示首个请求仍在处理中
+type idempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// recordingWriter 记录响应状态码与响应体，供保存为幂等结果
+type recordingWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if !w.overflow {
+		if w.body.Len()+len(b) > idempotencyMaxBody {
+			w.overflow = true
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Idempotent 幂等中间件，须在 Auth 之后使用。请求未携带 Idempotency-Key 或 Redis 不可用时直接放行。
+// 首个请求以 SETNX 占位后执行，非 5xx 的结果保存 idempotencyTTL；
+// 之后携带相同键的请求直接重放保存的响应，与首个请求并发到达时等待其完成后重放。
+// 同一个键用于内容不同的请求时拒绝处理
+func Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" || redis.RedisClient == nil {
+			c.Next()
+			return
+		}
+		if len(key) > idempotencyMaxKey {
+			response.Fail(c, response.ErrInvalidRequest.WithTips("幂等键过长"))
+			return
+		}
+		userPayload, ok := jwt.GetUserPayload(c)
+		if !ok {
+			response.Fail(c, response.ErrUnauthorized)
+			return
+		}
+		fingerprint, err := requestFingerprint(c)
+		if err != nil {
+			response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
+			return
+		}
+
+		ctx := c.Request.Context()
+		scope := sha256.Sum256([]byte(c.Request.Method + " " + c.FullPath() + "\n" + key))
+		redisKey := "idempotency:" + strconv.FormatUint(uint64(userPayload.ID), 10) + ":" + hex.EncodeToString(scope[:])
+
+		pending, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint})
+		acquired, err := redis.RedisClient.SetNX(ctx, redisKey, pending, idempotencyLockTTL).Result()
+		if err != nil {
+			// Redis 故障时不阻断业务，退化为普通请求
+			c.Next()
+			return
+		}
+		if !acquired {
+			replay(c, redisKey, fingerprint)
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		// 服务端错误与过大的响应不保存，释放占位以便客户端重试
+		status := w.Status()
+		if status >= http.StatusInternalServerError || w.overflow {
+			redis.RedisClient.Del(context.Background(), redisKey)
+			return
+		}
+		done, _ := json.Marshal(idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: w.Header().Get("Content-Type"),
+			Body:        w.body.Bytes(),
+		})
+		redis.RedisClient.Set(context.Background(), redisKey, done, idempotencyTTL)
+	}
+}
+
+// replay 重放已保存的响应，首个请求仍在处理时轮询等待其结果
+func replay(c *gin.Context, redisKey, fingerprint string) {
+	ctx := c.Request.Context()
+	deadline := time.Now().Add(idempotencyWait)
+	for {
+		data, err := redis.RedisClient.Get(ctx, redisKey).Bytes()
+		if errors.Is(err, goredis.Nil) {
+			// 首个请求以服务端错误结束或占位已过期，结果未保存，由客户端重试
+			response.Fail(c, response.ErrAlreadyExists.WithTips("相同的请求处理失败，请稍后重试"))
+			return
+		}
+		if err != nil {
+			response.Fail(c, response.ErrServerInternal.WithOrigin(err))
+			return
+		}
+		var record idempotencyRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			response.Fail(c, response.ErrServerInternal.WithOrigin(err))
+			return
+		}
+		if record.Fingerprint != fingerprint {
+			response.Fail(c, response.ErrInvalidRequest.WithTips("幂等键已用于内容不同的请求"))
+			return
+		}
+		if record.Status != 0 {
+			c.Header(IdempotencyReplayedHeader, "true")
+			c.Data(record.Status, record.ContentType, record.Body)
+			c.Abort()
+			return
+		}
+		if time.Now().After(deadline) {
+			response.Fail(c, response.ErrAlreadyExists.WithTips("相同的请求正在处理中，请稍后重试"))
+			return
+		}
+		select {
+		case <-ctx.Done():
+			c.Abort()
+			return
+		case <-time.After(idempotencyPoll):
+		}
+	}
+}
+
+// requestFingerprint 以请求路径、查询参数与请求体计算指纹，读取后恢复请求体供后续处理
+func requestFingerprint(c *gin.Context) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(c.Request.URL.Path + "?" + c.Request.URL.RawQuery + "\n"))
+	if c.Request.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, idempotencyMaxInput+1))
+		if err != nil {
+			return "", err
+		}
+		if len(body) > idempotencyMaxInput {
+			return "", errors.New("请求体过大")
+		}
+		_ = c.Request.Body.Close()
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,49 @@
+// Package permission 活动成员角色校验，各模块的接口在修改活动、项目、栏目与公告前使用
+package permission
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/logger"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// log 在首次使用时创建，包初始化时配置尚未加载
+var log = sync.OnceValue(func() *slog.Logger { return logger.New("Permission") })
+
+// RequireRole 校验用户在活动中至少拥有 minRole 角色，不满足时写入失败响应，action 用于提示，如"更新该活动"
+func RequireRole(c *gin.Context, activityID uint, studentID string, minRole int, action string) bool {
+	role, err := model.MemberRole(database.DB, activityID, studentID)
+	if err != nil {
+		log().Error("查询活动成员失败", "error", err, "activity_id", activityID, "student_id", studentID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return false
+	}
+	if role < minRole {
+		log().Warn("无权限"+action, "activity_id", activityID, "student_id", studentID, "role", role)
+		response.Fail(c, response.ErrForbidden.WithTips("无权限"+action))
+		return false
+	}
+	return true
+}
+
+// RequireWritableRole 在 RequireRole 的基础上要求活动未归档，不满足时写入失败响应
+func RequireWritableRole(c *gin.Context, activityID uint, studentID string, minRole int, action string) bool {
+	if !RequireRole(c, activityID, studentID, minRole, action) {
+		return false
+	}
+	if err := model.CheckActivityWritable(database.DB, activityID); errors.Is(err, model.ErrActivityArchived) {
+		response.Fail(c, response.ErrForbidden.WithTips(err.Error()))
+		return false
+	} else if err != nil {
+		log().Error("查询活动失败", "error", err, "activity_id", activityID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return false
+	}
+	return true
+}
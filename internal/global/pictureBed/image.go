@@ -0,0 +1,268 @@
+package pictureBed
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// 图片各尺寸的最长边（像素）
+const (
+	FullSize   = 2560 // 去除元数据后的原图
+	MediumSize = 1080 // 详情页展示
+	ThumbSize  = 320  // 列表缩略图
+)
+
+const (
+	fullQuality      = 85
+	renditionQuality = 80
+	maxProcessBytes  = 64 << 20 // 超过此大小的对象不做处理
+	maxProcessPixels = 64 << 20 // 超过此像素数的图片不解码，避免高压缩比的小文件解码后占用过多内存
+)
+
+// ErrUnsupportedImage 图片格式无法解码、文件过大或像素过多，调用方应直接使用原图（已尽量去除元数据）
+var ErrUnsupportedImage = errors.New("不支持处理的图片格式")
+
+// ProcessedImage 图片处理结果，URL 为空表示原图未被改写
+type ProcessedImage struct {
+	URL       string
+	MediumURL string
+	ThumbURL  string
+	MediumKey string
+	ThumbKey  string
+}
+
+// ProcessImage 读取对象并生成各尺寸图片：
+// JPEG 与 PNG 按 EXIF 方向摆正、限制尺寸后重新编码覆盖原对象，从而去除 EXIF/GPS 等元数据；
+// WebP 去除 EXIF/XMP 块后覆盖原对象；GIF 保持原对象不变。
+// 像素数超过 maxProcessPixels 的图片不解码，仅在字节层面去除元数据后返回 ErrUnsupportedImage。
+// 中图与缩略图统一输出为 JPEG，key 为原 key 加 _medium/_thumb 后缀
+func ProcessImage(ctx context.Context, store Storage, key string) (*ProcessedImage, error) {
+	body, err := store.GetObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxProcessBytes+1))
+	_ = body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxProcessBytes {
+		return nil, ErrUnsupportedImage
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxProcessPixels {
+		if stripped, ok := stripMetadata(format, data); ok {
+			if _, err := store.PutObject(ctx, key, bytes.NewReader(stripped), "image/"+format); err != nil {
+				return nil, err
+			}
+		}
+		return nil, fmt.Errorf("%w: 像素过多 %dx%d", ErrUnsupportedImage, cfg.Width, cfg.Height)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+
+	full := fit(img, FullSize)
+	if format == "jpeg" {
+		full = orient(full, jpegOrientation(data))
+	}
+
+	res := &ProcessedImage{}
+	switch format {
+	case "jpeg":
+		if res.URL, err = putJPEG(ctx, store, key, full, fullQuality); err != nil {
+			return nil, err
+		}
+	case "png":
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, full); err != nil {
+			return nil, err
+		}
+		if res.URL, err = store.PutObject(ctx, key, &buf, "image/png"); err != nil {
+			return nil, err
+		}
+	case "webp":
+		// 没有 WebP 编码器，直接去除元数据块
+		if stripped, ok := stripWebP(data); ok {
+			if res.URL, err = store.PutObject(ctx, key, bytes.NewReader(stripped), "image/webp"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	base := strings.TrimSuffix(key, path.Ext(key))
+	res.MediumKey, res.ThumbKey = base+"_medium.jpg", base+"_thumb.jpg"
+	if res.MediumURL, err = putJPEG(ctx, store, res.MediumKey, fit(full, MediumSize), renditionQuality); err != nil {
+		return nil, err
+	}
+	if res.ThumbURL, err = putJPEG(ctx, store, res.ThumbKey, fit(full, ThumbSize), renditionQuality); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// putJPEG 将图片铺在白色背景上编码为 JPEG 并写入对象存储
+func putJPEG(ctx context.Context, store Storage, key string, img image.Image, quality int) (string, error) {
+	b := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, b.Min, draw.Over)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: quality}); err != nil {
+		return "", err
+	}
+	return store.PutObject(ctx, key, &buf, "image/jpeg")
+}
+
+// fit 等比缩放使最长边不超过 size，本身更小时原样返回
+func fit(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	if w >= h {
+		w, h = size, max(1, h*size/w)
+	} else {
+		w, h = max(1, w*size/h), size
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	return dst
+}
+
+// orient 按 EXIF Orientation（1-8）将图片摆正
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	// 统一为 RGBA/NRGBA 后按 4 字节整像素复制，避免逐像素经 color.Color 接口转换
+	var src *image.RGBA
+	switch m := img.(type) {
+	case *image.NRGBA:
+		dst := image.NewNRGBA(orientedRect(m.Bounds(), orientation))
+		orientPix(dst.Pix, dst.Stride, m.Pix, m.Stride, m.Rect.Dx(), m.Rect.Dy(), orientation)
+		return dst
+	case *image.RGBA:
+		src = m
+	default:
+		src = image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+		draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+	dst := image.NewRGBA(orientedRect(src.Bounds(), orientation))
+	orientPix(dst.Pix, dst.Stride, src.Pix, src.Stride, src.Rect.Dx(), src.Rect.Dy(), orientation)
+	return dst
+}
+
+// orientedRect 按 EXIF 方向调整后的图片范围，5~8 需交换宽高
+func orientedRect(b image.Rectangle, orientation int) image.Rectangle {
+	if orientation >= 5 {
+		return image.Rect(0, 0, b.Dy(), b.Dx())
+	}
+	return image.Rect(0, 0, b.Dx(), b.Dy())
+}
+
+// orientPix 将 w×h、每像素 4 字节的 src 按 EXIF 方向复制到 dst
+func orientPix(dst []byte, dstStride int, src []byte, srcStride, w, h, orientation int) {
+	for y := 0; y < h; y++ {
+		row := src[y*srcStride : y*srcStride+w*4]
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, h-1-y
+			case 5: // 沿主对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转 90°
+				dx, dy = h-1-y, x
+			case 7: // 沿副对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转 90°
+				dx, dy = y, w-1-x
+			}
+			copy(dst[dy*dstStride+dx*4:dy*dstStride+dx*4+4], row[x*4:x*4+4])
+		}
+	}
+}
+
+// jpegOrientation 从 JPEG 的 APP1 EXIF 段中读取 Orientation，缺失或无法解析时返回 1
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // 图像数据开始或结束，之后不再有元数据段
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		seg := data[i+4 : i+2+size]
+		if marker == 0xE1 && len(seg) > 6 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// tiffOrientation 在 TIFF 结构的 IFD0 中查找 Orientation（0x0112）标签
+func tiffOrientation(t []byte) int {
+	if len(t) < 8 {
+		return 1
+	}
+	var bo binary.ByteOrder
+	switch string(t[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return 1
+	}
+	off := int(bo.Uint32(t[4:8]))
+	if off < 8 || off+2 > len(t) {
+		return 1
+	}
+	n := int(bo.Uint16(t[off:]))
+	for j := 0; j < n; j++ {
+		e := off + 2 + j*12
+		if e+12 > len(t) {
+			break
+		}
+		if bo.Uint16(t[e:]) == 0x0112 {
+			if v := int(bo.Uint16(t[e+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
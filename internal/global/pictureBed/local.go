@@ -0,0 +1,345 @@
+package pictureBed
+
+import (
+	sysconfig "activity-punch-system/config"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 本地存储的路由：上传 PUT {base}/upload/{key}，访问 GET {base}/object/{key}
+const (
+	localBasePath   = "/storage"
+	localUploadPath = "/upload/"
+	localObjectPath = "/object/"
+	localTempPrefix = ".upload-"
+	localMaxUpload  = 256 << 20 // 单次上传大小上限，视频附件较大
+)
+
+var errInvalidKey = errors.New("无效的对象 key")
+
+// LocalStorage 本地磁盘对象存储，对象保存在 Root/key，
+// 预签名 URL 以 HMAC 签名，由 RegisterRoutes 注册的路由校验后读写
+type LocalStorage struct {
+	Root    string // 存储目录
+	BaseURL string // 对外访问的基础 URL，例如 http://127.0.0.1:8080/storage，可为相对路径
+	Prefix  string // 对象 Key 前缀
+	secret  []byte
+}
+
+// NewLocalStorage 按配置创建本地存储
+func NewLocalStorage() *LocalStorage {
+	cfg := sysconfig.Get()
+	root := cfg.S3.LocalDir
+	if root == "" {
+		root = filepath.Join(cfg.Storage.Home, "objects")
+	}
+	base := strings.TrimRight(cfg.S3.BaseURL, "/")
+	if base == "" {
+		base = localBasePath
+	}
+	return &LocalStorage{
+		Root:    root,
+		BaseURL: base,
+		Prefix:  cfg.S3.Prefix,
+		secret:  []byte("storage:" + cfg.JWT.AccessSecret),
+	}
+}
+
+// filePath 将对象 key 转换为磁盘路径，拒绝包含 .. 或绝对路径的 key
+func (ls *LocalStorage) filePath(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return "", errInvalidKey
+	}
+	if strings.HasPrefix(path.Base(key), localTempPrefix) {
+		return "", errInvalidKey
+	}
+	return filepath.Join(ls.Root, filepath.FromSlash(key)), nil
+}
+
+// sign 计算预签名参数的签名
+func (ls *LocalStorage) sign(method, key string, expires int64, contentType string) string {
+	mac := hmac.New(sha256.New, ls.secret)
+	_, _ = fmt.Fprintf(mac, "%s\n%s\n%d\n%s", method, key, expires, contentType)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify 校验预签名参数，过期或签名不符时返回 false
+func (ls *LocalStorage) verify(method, key, expiresStr, contentType, signature string) bool {
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	want := ls.sign(method, key, expires, contentType)
+	return hmac.Equal([]byte(want), []byte(signature))
+}
+
+// ObjectURL 对象的公开访问 URL
+func (ls *LocalStorage) ObjectURL(key string) string {
+	return ls.BaseURL + localObjectPath + (&url.URL{Path: key}).EscapedPath()
+}
+
+// PutObject 写入对象并返回访问 URL，先写临时文件再重命名，避免读到不完整的文件
+func (ls *LocalStorage) PutObject(_ context.Context, key string, body io.Reader, _ string) (string, error) {
+	p, err := ls.filePath(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), localTempPrefix+"*")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := io.Copy(tmp, body); err != nil {
+		_ = tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return "", err
+	}
+	return ls.ObjectURL(key), nil
+}
+
+// GeneratePresignedUploadURL 生成由本服务处理的预签名上传 URL，
+// 扩展名与 Content-Type 保持一致，读取时据扩展名还原类型
+func (ls *LocalStorage) GeneratePresignedUploadURL(_ context.Context, req PresignedUploadRequest) (*PresignedUploadResponse, error) {
+	if req.Filename == "" {
+		return nil, fmt.Errorf("文件名不能为空")
+	}
+	if req.ExpiresIn <= 0 {
+		req.ExpiresIn = 900 // 15 分钟
+	}
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	ext := strings.ToLower(path.Ext(req.Filename))
+	if mime.TypeByExtension(ext) != contentType {
+		if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+	key := newObjectKey(ls.Prefix, ext)
+
+	expiresAt := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("content_type", contentType)
+	query.Set("signature", ls.sign(http.MethodPut, key, expiresAt.Unix(), contentType))
+
+	return &PresignedUploadResponse{
+		UploadURL: ls.BaseURL + localUploadPath + (&url.URL{Path: key}).EscapedPath() + "?" + query.Encode(),
+		FileKey:   key,
+		FileURL:   ls.ObjectURL(key),
+		ExpiresAt: expiresAt,
+		Method:    http.MethodPut,
+		Headers: map[string]string{
+			"Content-Type": contentType,
+		},
+	}, nil
+}
+
+// GeneratePresignedDownloadURL 生成带签名的访问 URL
+func (ls *LocalStorage) GeneratePresignedDownloadURL(_ context.Context, key string, expiresIn int64) (string, error) {
+	if _, err := ls.filePath(key); err != nil {
+		return "", err
+	}
+	if expiresIn <= 0 {
+		expiresIn = 3600 // 默认 1 小时
+	}
+	expires := time.Now().Add(time.Duration(expiresIn) * time.Second).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", ls.sign(http.MethodGet, key, expires, ""))
+	return ls.ObjectURL(key) + "?" + query.Encode(), nil
+}
+
+// HeadObject 查询对象的类型与大小，类型按扩展名推断
+func (ls *LocalStorage) HeadObject(_ context.Context, key string) (*ObjectInfo, error) {
+	p, err := ls.filePath(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, ErrObjectNotFound
+	}
+	return &ObjectInfo{
+		Key:          key,
+		ContentType:  contentTypeByKey(key),
+		Size:         fi.Size(),
+		LastModified: fi.ModTime(),
+	}, nil
+}
+
+// GetObject 打开对象文件，调用方负责关闭
+func (ls *LocalStorage) GetObject(_ context.Context, key string) (io.ReadCloser, error) {
+	p, err := ls.filePath(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return f, err
+}
+
+// DeleteObject 删除对象文件
+func (ls *LocalStorage) DeleteObject(_ context.Context, key string) error {
+	p, err := ls.filePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// ListObjects 遍历存储目录，列出 key 以 prefix 开头的对象，跳过未完成的临时文件
+func (ls *LocalStorage) ListObjects(_ context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	err := filepath.WalkDir(ls.Root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), localTempPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(ls.Root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{
+			Key:          key,
+			ContentType:  contentTypeByKey(key),
+			Size:         fi.Size(),
+			LastModified: fi.ModTime(),
+		})
+		return nil
+	})
+	return objects, err
+}
+
+// KeyFromURL 从访问 URL 中还原对象 key，主机不作校验，key 须位于 Prefix 下
+func (ls *LocalStorage) KeyFromURL(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	i := strings.Index(u.Path, localObjectPath)
+	if i < 0 {
+		return "", false
+	}
+	key := u.Path[i+len(localObjectPath):]
+	if prefix := strings.Trim(ls.Prefix, "/"); prefix != "" && !strings.HasPrefix(key, prefix+"/") {
+		return "", false
+	}
+	if _, err := ls.filePath(key); err != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// RegisterRoutes 使用本地存储时注册预签名上传与对象访问路由，S3 存储无需注册
+func RegisterRoutes(r gin.IRouter) {
+	if sysconfig.Get().S3.Driver != DriverLocal {
+		return
+	}
+	ls := NewLocalStorage()
+	base := localBasePath
+	if u, err := url.Parse(ls.BaseURL); err == nil && u.Path != "" {
+		base = strings.TrimRight(u.Path, "/")
+	}
+	g := r.Group(base)
+	g.PUT(localUploadPath+"*key", ls.handleUpload)
+	g.GET(localObjectPath+"*key", ls.handleObject)
+	g.HEAD(localObjectPath+"*key", ls.handleObject)
+}
+
+// handleUpload 校验签名、有效期与 Content-Type 后保存上传的对象
+func (ls *LocalStorage) handleUpload(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	contentType := c.Query("content_type")
+	if !ls.verify(http.MethodPut, key, c.Query("expires"), contentType, c.Query("signature")) {
+		c.String(http.StatusForbidden, "签名无效或已过期")
+		return
+	}
+	if c.GetHeader("Content-Type") != contentType {
+		c.String(http.StatusBadRequest, "Content-Type 与签名不一致")
+		return
+	}
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, localMaxUpload)
+	if _, err := ls.PutObject(c.Request.Context(), key, body, contentType); err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			c.String(http.StatusRequestEntityTooLarge, "文件过大")
+		case errors.Is(err, errInvalidKey):
+			c.String(http.StatusBadRequest, err.Error())
+		default:
+			c.String(http.StatusInternalServerError, "保存文件失败")
+		}
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// handleObject 返回对象文件，携带签名参数时校验签名与有效期，私有模式下必须携带签名
+func (ls *LocalStorage) handleObject(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if (c.Query("signature") != "" || Private()) && !ls.verify(http.MethodGet, key, c.Query("expires"), "", c.Query("signature")) {
+		c.String(http.StatusForbidden, "签名无效或已过期")
+		return
+	}
+	p, err := ls.filePath(key)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if fi, err := os.Stat(p); err != nil || fi.IsDir() {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.File(p)
+}
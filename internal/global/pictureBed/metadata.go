@@ -0,0 +1,137 @@
+package pictureBed
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// stripMetadata 在字节层面去除图片中的 EXIF/XMP/文本等元数据，不解码像素。
+// 用于无需重新编码（WebP）或像素过多不宜解码的图片，不支持的格式返回 false
+func stripMetadata(format string, data []byte) ([]byte, bool) {
+	switch format {
+	case "jpeg":
+		return stripJPEG(data)
+	case "png":
+		return stripPNG(data)
+	case "webp":
+		return stripWebP(data)
+	}
+	return nil, false
+}
+
+// stripJPEG 去除 APP1（EXIF/XMP）、APP13（IPTC）与注释段。
+// EXIF 中的方向信息以仅含 Orientation 的最小 EXIF 段保留，避免图片显示方向错误
+func stripJPEG(data []byte) ([]byte, bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, false
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	for i := 2; ; {
+		if i+4 > len(data) || data[i] != 0xFF {
+			return nil, false
+		}
+		marker := data[i+1]
+		if marker == 0xDA { // 图像数据开始，其后原样保留
+			out.Write(data[i:])
+			return out.Bytes(), true
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return nil, false
+		}
+		seg := data[i+4 : i+2+size]
+		switch {
+		case marker == 0xE1:
+			if len(seg) > 6 && string(seg[:6]) == "Exif\x00\x00" {
+				if o := tiffOrientation(seg[6:]); o > 1 {
+					out.Write(orientationEXIF(o))
+				}
+			}
+		case marker == 0xED, marker == 0xFE:
+		default:
+			out.Write(data[i : i+2+size])
+		}
+		i += 2 + size
+	}
+}
+
+// orientationEXIF 仅包含 Orientation 标签的 APP1 段
+func orientationEXIF(orientation int) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // 大端 TIFF 头，IFD0 偏移 8
+		0x00, 0x01, // 1 个标签
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, byte(orientation), 0x00, 0x00, // Orientation SHORT
+		0x00, 0x00, 0x00, 0x00, // 无后续 IFD
+	}
+	seg := append([]byte("Exif\x00\x00"), tiff...)
+	head := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(head[2:], uint16(len(seg)+2))
+	return append(head, seg...)
+}
+
+// pngMetadataChunks PNG 中可能携带元数据的辅助块
+var pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+// stripPNG 去除 EXIF、文本与时间块，校验每个块的 CRC
+func stripPNG(data []byte) ([]byte, bool) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if len(data) < len(signature) || string(data[:len(signature)]) != signature {
+		return nil, false
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.WriteString(signature)
+	for i := len(signature); i < len(data); {
+		if i+12 > len(data) {
+			return nil, false
+		}
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		if n < 0 || i+12+n > len(data) {
+			return nil, false
+		}
+		chunk := data[i : i+12+n]
+		if crc32.ChecksumIEEE(chunk[4:8+n]) != binary.BigEndian.Uint32(chunk[8+n:]) {
+			return nil, false
+		}
+		if !pngMetadataChunks[string(chunk[4:8])] {
+			out.Write(chunk)
+		}
+		i += 12 + n
+	}
+	return out.Bytes(), true
+}
+
+// stripWebP 去除 EXIF 与 XMP 块，并清除 VP8X 中对应的标志位
+func stripWebP(data []byte) ([]byte, bool) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, false
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, false
+		}
+		n := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + n + n%2 // 块长度为奇数时有 1 字节填充
+		if n < 0 || end > len(data) {
+			return nil, false
+		}
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if n > 0 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF 与 XMP 标志
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	b := out.Bytes()
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)-8))
+	return b, true
+}
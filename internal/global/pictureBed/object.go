@@ -0,0 +1,154 @@
+package pictureBed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// KeyFromURL 从 SaveImage / 预签名上传返回的访问 URL 中还原对象 key，
+// 主机不作校验（可能是 BaseURL、Endpoint 或备用域名），key 须位于 Prefix 下
+func (pb *PictureBed) KeyFromURL(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Path == "" {
+		return "", false
+	}
+	key := strings.TrimLeft(u.Path, "/")
+	if pb.UsePathStyle {
+		var ok bool
+		if key, ok = strings.CutPrefix(key, pb.Bucket+"/"); !ok {
+			return "", false
+		}
+	}
+	if prefix := strings.Trim(pb.Prefix, "/"); prefix != "" && !strings.HasPrefix(key, prefix+"/") {
+		return "", false
+	}
+	return key, key != ""
+}
+
+// GetObject 以流的方式读取对象，调用方负责关闭返回的 Body
+func (pb *PictureBed) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	if pb.s3Client == nil {
+		if err := pb.InitS3(ctx); err != nil {
+			return nil, fmt.Errorf("初始化 S3 客户端失败: %w", err)
+		}
+	}
+	out, err := pb.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(pb.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// HeadObject 查询对象的类型与大小，对象不存在时返回 ErrObjectNotFound
+func (pb *PictureBed) HeadObject(ctx context.Context, key string) (*ObjectInfo, error) {
+	if pb.s3Client == nil {
+		if err := pb.InitS3(ctx); err != nil {
+			return nil, fmt.Errorf("初始化 S3 客户端失败: %w", err)
+		}
+	}
+	out, err := pb.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(pb.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var nf *types.NotFound
+		var re *smithyhttp.ResponseError
+		if errors.As(err, &nf) || (errors.As(err, &re) && re.HTTPStatusCode() == 404) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return &ObjectInfo{
+		Key:          key,
+		ContentType:  aws.ToString(out.ContentType),
+		Size:         aws.ToInt64(out.ContentLength),
+		LastModified: aws.ToTime(out.LastModified),
+	}, nil
+}
+
+// PutObject 上传对象并返回访问 URL
+func (pb *PictureBed) PutObject(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	if pb.s3Client == nil || pb.uploader == nil {
+		if err := pb.InitS3(ctx); err != nil {
+			return "", fmt.Errorf("初始化 S3 客户端失败: %w", err)
+		}
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if _, err := pb.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(pb.Bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	}); err != nil {
+		return "", err
+	}
+	return pb.ObjectURL(key), nil
+}
+
+// DeleteObject 删除对象
+func (pb *PictureBed) DeleteObject(ctx context.Context, key string) error {
+	if pb.s3Client == nil {
+		if err := pb.InitS3(ctx); err != nil {
+			return fmt.Errorf("初始化 S3 客户端失败: %w", err)
+		}
+	}
+	_, err := pb.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(pb.Bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// ListObjects 分页列出 prefix 下的全部对象
+func (pb *PictureBed) ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if pb.s3Client == nil {
+		if err := pb.InitS3(ctx); err != nil {
+			return nil, fmt.Errorf("初始化 S3 客户端失败: %w", err)
+		}
+	}
+	var objects []ObjectInfo
+	paginator := s3.NewListObjectsV2Paginator(pb.s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(pb.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
+
+// ObjectURL 对象的公开访问 URL：path-style 为 base/bucket/key，virtual-host 风格需要 baseURL 自行包含 bucket 域名
+func (pb *PictureBed) ObjectURL(key string) string {
+	base := strings.TrimRight(pb.BaseURL, "/")
+	if base == "" {
+		base = strings.TrimRight(pb.Endpoint, "/")
+	}
+	if pb.UsePathStyle {
+		return base + "/" + pb.Bucket + "/" + key
+	}
+	return base + "/" + key
+}
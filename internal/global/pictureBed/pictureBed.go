@@ -5,10 +5,8 @@ import (
 	"context"
 	"fmt"
 	"mime/multipart"
-	"path"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -111,10 +109,7 @@ func (pb *PictureBed) SaveImage(fileHeader *multipart.FileHeader) (string, error
 	}
 	defer file.Close()
 
-	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
-	filename := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
-	key := path.Join(strings.Trim(pb.Prefix, "/"), filename)
-	key = strings.TrimLeft(key, "/")
+	key := newObjectKey(pb.Prefix, strings.ToLower(filepath.Ext(fileHeader.Filename)))
 
 	contentType := fileHeader.Header.Get("Content-Type")
 	if contentType == "" {
@@ -122,25 +117,5 @@ func (pb *PictureBed) SaveImage(fileHeader *multipart.FileHeader) (string, error
 		contentType = "application/octet-stream"
 	}
 
-	_, err = pb.uploader.Upload(context.Background(), &s3.PutObjectInput{
-		Bucket:      aws.String(pb.Bucket),
-		Key:         aws.String(key),
-		Body:        file,
-		ContentType: aws.String(contentType),
-	})
-	if err != nil {
-		return "", err
-	}
-
-	base := strings.TrimRight(pb.BaseURL, "/")
-	if base == "" {
-		base = strings.TrimRight(pb.Endpoint, "/")
-	}
-
-	// 返回可访问 URL：优先走 path-style（base/bucket/key），方便 BaseURL 为自定义域名场景。
-	if pb.UsePathStyle {
-		return base + "/" + pb.Bucket + "/" + key, nil
-	}
-	// virtual-host 风格需要 baseURL 自行包含 bucket 域名，这里仅拼 key
-	return base + "/" + key, nil
+	return pb.PutObject(context.Background(), key, file, contentType)
 }
@@ -66,13 +66,8 @@ func (pb *PictureBed) GeneratePresignedUploadURL(ctx context.Context, req Presig
 		req.ExpiresIn = 900 // 15 分钟
 	}
 
-	// 生成唯一的文件名（时间戳 + 原始扩展名）
-	ext := strings.ToLower(path.Ext(req.Filename))
-	uniqueFilename := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
-
-	// 构建完整的对象 key（包含前缀）
-	key := path.Join(strings.Trim(pb.Prefix, "/"), uniqueFilename)
-	key = strings.TrimLeft(key, "/")
+	// 生成唯一的对象 key（前缀 + 时间戳 + 原始扩展名）
+	key := newObjectKey(pb.Prefix, strings.ToLower(path.Ext(req.Filename)))
 
 	// 设置默认 Content-Type
 	contentType := req.ContentType
@@ -97,17 +92,7 @@ func (pb *PictureBed) GeneratePresignedUploadURL(ctx context.Context, req Presig
 	}
 
 	// 构建访问 URL
-	base := strings.TrimRight(pb.BaseURL, "/")
-	if base == "" {
-		base = strings.TrimRight(pb.Endpoint, "/")
-	}
-
-	var fileURL string
-	if pb.UsePathStyle {
-		fileURL = base + "/" + pb.Bucket + "/" + key
-	} else {
-		fileURL = base + "/" + key
-	}
+	fileURL := pb.ObjectURL(key)
 
 	backupHost := sysconfig.Get().S3.BackupHost
 	var backupURL string
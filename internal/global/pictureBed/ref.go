@@ -0,0 +1,93 @@
+package pictureBed
+
+import (
+	sysconfig "activity-punch-system/config"
+	"context"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// defaultSignExpire 私有模式下读取链接的默认有效期（秒）
+const defaultSignExpire = 600
+
+// 数据库中保存的对象引用有两种形式：公开模式下为访问 URL（历史数据均为此形式），
+// 私有模式下为对象 key。读取时统一经 ReadURL 转换为客户端可访问的地址
+
+// Private 是否开启私有桶模式
+func Private() bool {
+	return sysconfig.Get().S3.Private
+}
+
+// ResolveKey 从对象引用中还原对象 key，引用可以是访问 URL（含预签名 URL）或对象 key 本身，
+// key 须位于 Prefix 下
+func ResolveKey(store Storage, ref string) (string, bool) {
+	if ref == "" {
+		return "", false
+	}
+	if strings.Contains(ref, "://") || strings.HasPrefix(ref, "/") {
+		if store == nil {
+			return "", false
+		}
+		return store.KeyFromURL(ref)
+	}
+	if path.Clean(ref) != ref || ref == ".." || strings.HasPrefix(ref, "../") {
+		return "", false
+	}
+	if prefix := strings.Trim(sysconfig.Get().S3.Prefix, "/"); prefix != "" && !strings.HasPrefix(ref, prefix+"/") {
+		return "", false
+	}
+	return ref, true
+}
+
+// StoredRef 返回应保存到数据库的对象引用：私有模式下保存 key；
+// 公开模式下保存客户端提交的访问 URL（去除签名参数），提交的是 key 时补全为公开地址
+func StoredRef(store Storage, key, submitted string) string {
+	if Private() {
+		return key
+	}
+	if submitted == "" || submitted == key {
+		return store.ObjectURL(key)
+	}
+	if u, err := url.Parse(submitted); err == nil {
+		u.RawQuery, u.Fragment = "", ""
+		return u.String()
+	}
+	return submitted
+}
+
+// ReadURL 将对象引用转换为客户端可访问的地址：私有模式下签发短期读取链接，
+// 公开模式下 key 补全为公开地址。无法识别的引用（如站外链接）或签名失败时原样返回
+func ReadURL(ctx context.Context, store Storage, ref string) string {
+	if ref == "" || store == nil {
+		return ref
+	}
+	key, ok := ResolveKey(store, ref)
+	if !ok {
+		return ref
+	}
+	if !Private() {
+		if key == ref {
+			return store.ObjectURL(key)
+		}
+		return ref
+	}
+	expire := sysconfig.Get().S3.SignExpire
+	if expire <= 0 {
+		expire = defaultSignExpire
+	}
+	link, err := store.GeneratePresignedDownloadURL(ctx, key, int64(expire))
+	if err != nil {
+		return ref
+	}
+	return link
+}
+
+// ReadURLs 批量转换对象引用，见 ReadURL
+func ReadURLs(ctx context.Context, store Storage, refs []string) []string {
+	urls := make([]string, len(refs))
+	for i, ref := range refs {
+		urls[i] = ReadURL(ctx, store, ref)
+	}
+	return urls
+}
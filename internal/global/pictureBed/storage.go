@@ -0,0 +1,114 @@
+package pictureBed
+
+import (
+	sysconfig "activity-punch-system/config"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// 存储驱动，通过 S3.Driver 配置
+const (
+	DriverS3    = "s3"    // S3 兼容对象存储（默认）
+	DriverLocal = "local" // 本地磁盘，上传与下载由服务自身处理
+)
+
+// ErrObjectNotFound 对象在存储桶中不存在
+var ErrObjectNotFound = errors.New("对象不存在")
+
+// ObjectInfo 对象元信息
+type ObjectInfo struct {
+	Key          string
+	ContentType  string
+	Size         int64
+	LastModified time.Time
+}
+
+// Storage 对象存储接口，S3 与本地磁盘各有一个实现，key 均位于 Prefix 下
+type Storage interface {
+	// PutObject 写入对象并返回访问 URL
+	PutObject(ctx context.Context, key string, body io.Reader, contentType string) (string, error)
+	// GeneratePresignedUploadURL 生成预签名上传 URL，由前端直接上传
+	GeneratePresignedUploadURL(ctx context.Context, req PresignedUploadRequest) (*PresignedUploadResponse, error)
+	// GeneratePresignedDownloadURL 生成有时效的下载 URL
+	GeneratePresignedDownloadURL(ctx context.Context, key string, expiresIn int64) (string, error)
+	// HeadObject 查询对象元信息，对象不存在时返回 ErrObjectNotFound
+	HeadObject(ctx context.Context, key string) (*ObjectInfo, error)
+	// GetObject 以流的方式读取对象，调用方负责关闭返回的 Body
+	GetObject(ctx context.Context, key string) (io.ReadCloser, error)
+	// DeleteObject 删除对象，对象不存在时不报错
+	DeleteObject(ctx context.Context, key string) error
+	// ListObjects 列出 prefix 下的全部对象
+	ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// KeyFromURL 从访问 URL 中还原对象 key
+	KeyFromURL(rawURL string) (string, bool)
+	// ObjectURL 对象的公开访问 URL，私有模式下不带签名无法访问
+	ObjectURL(key string) string
+}
+
+// New 按配置创建对象存储
+func New(ctx context.Context) (Storage, error) {
+	cfg := sysconfig.Get().S3
+	switch cfg.Driver {
+	case "", DriverS3:
+		pb := NewPictureBed(cfg.Endpoint, "")
+		if err := pb.InitS3(ctx); err != nil {
+			return nil, fmt.Errorf("初始化 S3 客户端失败: %w", err)
+		}
+		return pb, nil
+	case DriverLocal:
+		return NewLocalStorage(), nil
+	default:
+		return nil, fmt.Errorf("未知的存储驱动: %s", cfg.Driver)
+	}
+}
+
+// extraTypes 部分系统的 MIME 表缺少的扩展名，本地存储按扩展名还原对象类型时需要
+var extraTypes = map[string]string{
+	".m4a":  "audio/mp4",
+	".mp3":  "audio/mpeg",
+	".amr":  "audio/amr",
+	".wav":  "audio/wav",
+	".mov":  "video/quicktime",
+	".3gp":  "video/3gpp",
+	".heic": "image/heic",
+	".heif": "image/heif",
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xls":  "application/vnd.ms-excel",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+func init() {
+	for ext, typ := range extraTypes {
+		if mime.TypeByExtension(ext) == "" {
+			_ = mime.AddExtensionType(ext, typ)
+		}
+	}
+}
+
+// newObjectKey 生成唯一的对象 key（前缀 + 时间戳 + 扩展名）
+func newObjectKey(prefix, ext string) string {
+	key := path.Join(strings.Trim(prefix, "/"), fmt.Sprintf("%d%s", time.Now().UnixNano(), ext))
+	return strings.TrimLeft(key, "/")
+}
+
+// contentTypeByKey 按扩展名推断对象的 MIME 类型
+func contentTypeByKey(key string) string {
+	if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+var (
+	_ Storage = (*PictureBed)(nil)
+	_ Storage = (*LocalStorage)(nil)
+)
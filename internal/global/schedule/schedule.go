@@ -0,0 +1,91 @@
+// Package schedule 简单的进程内定时任务，多实例部署时通过 Redis 保证同一时刻只有一个实例执行
+package schedule
+
+import (
+	"activity-punch-system/internal/global/clock"
+	"activity-punch-system/internal/global/logger"
+	"activity-punch-system/internal/global/redis"
+	"context"
+	"fmt"
+	"time"
+)
+
+// Daily 每天默认时区（Asia/Shanghai）的 hour:minute 执行一次 job，
+// 需要按各活动自身时区执行的任务应使用 Hourly 并在任务内判断
+func Daily(name string, hour, minute int, job func()) {
+	go func() {
+		for {
+			next := nextDaily(time.Now(), hour, minute)
+			time.Sleep(time.Until(next))
+			run(name, next.Format("20060102"), time.Hour, job)
+		}
+	}()
+}
+
+// Hourly 每小时的第 minute 分钟执行一次 job
+func Hourly(name string, minute int, job func()) {
+	go func() {
+		for {
+			next := time.Now().Truncate(time.Hour).Add(time.Duration(minute) * time.Minute)
+			if !next.After(time.Now()) {
+				next = next.Add(time.Hour)
+			}
+			time.Sleep(time.Until(next))
+			run(name, next.UTC().Format("2006010215"), time.Hour, job)
+		}
+	}()
+}
+
+// Every 每隔 interval 执行一次 job，启动后先等待一个周期
+func Every(name string, interval time.Duration, job func()) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for t := range ticker.C {
+			run(name, fmt.Sprintf("%d", t.Unix()/int64(interval.Seconds())), interval, job)
+		}
+	}()
+}
+
+func nextDaily(now time.Time, hour, minute int) time.Time {
+	loc := clock.Default().Location
+	n := now.In(loc)
+	t := time.Date(n.Year(), n.Month(), n.Day(), hour, minute, 0, 0, loc)
+	if !t.After(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// Acquire 以 name 抢占当前 interval 时间段内的执行权，供接口手动触发任务时限流，
+// 与定时任务使用同一组键；未配置 Redis 时总是返回 true
+func Acquire(name string, interval time.Duration) (bool, error) {
+	if redis.RedisClient == nil {
+		return true, nil
+	}
+	slot := fmt.Sprintf("%d", time.Now().Unix()/int64(interval.Seconds()))
+	return redis.RedisClient.SetNX(context.Background(), "schedule:"+name+":"+slot, time.Now().Unix(), interval).Result()
+}
+
+// run 以 name+slot 为键抢占执行权，键在 ttl 内不删除，避免各实例时钟略有偏差时重复执行
+func run(name, slot string, ttl time.Duration, job func()) {
+	log := logger.New("Schedule").With("job", name)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("定时任务发生 panic", "panic", r)
+		}
+	}()
+	if redis.RedisClient != nil {
+		locked, err := redis.RedisClient.SetNX(context.Background(), "schedule:"+name+":"+slot, time.Now().Unix(), ttl).Result()
+		if err != nil {
+			log.Error("获取定时任务锁失败", "error", err.Error())
+			return
+		}
+		if !locked {
+			return
+		}
+	}
+	start := time.Now()
+	job()
+	log.Info("定时任务执行完成", "cost", time.Since(start).String())
+}
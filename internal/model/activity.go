@@ -1,15 +1,88 @@
 package model
 
+import (
+	"activity-punch-system/internal/global/clock"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 活动状态，已发布为零值，状态字段上线前的活动均视为已发布
+const (
+	ActivityPublished = iota // 已发布：所有人可见，可以加入、打卡与审核
+	ActivityDraft            // 草稿：仅活动成员可见
+	ActivityScheduled        // 定时发布：仅活动成员可见，到达 PublishAt 后自动转为已发布
+	ActivityArchived         // 已归档：只读，不能再打卡或修改审核结果
+)
+
+// ErrActivityArchived 活动已归档，不能再修改
+var ErrActivityArchived = errors.New("活动已归档，不能再修改")
+
 type Activity struct {
 	Model
-	Name            string `gorm:"type:varchar(100);not null" json:"name" `   // 活动名称
-	Description     string `gorm:"type:varchar(255);" json:"description" `    // 活动描述
-	OwnerID         string `gorm:"type:varchar(20);not null" json:"owner_id"` // 所有者学号，外键指向用户表的学号
-	StartDate       int64  `gorm:"" json:"start_date"`                        // 活动开始时间
-	EndDate         int64  `gorm:"" json:"end_date"`                          // 活动结束时间
-	Avatar          string `gorm:"type:varchar(255);" json:"avatar"`          // 活动封面URL
-	DailyPointLimit uint   `gorm:"default:0" json:"daily_point_limit"`        // 每日积分上限，0表示不限制
-	CompletionBonus uint   `gorm:"default:0" json:"completion_bonus"`         // 完成活动所有栏目后的额外奖励积分，0表示无奖励
+	Name            string     `gorm:"type:varchar(100);not null" json:"name" `   // 活动名称
+	Description     string     `gorm:"type:varchar(255);" json:"description" `    // 活动描述
+	OwnerID         string     `gorm:"type:varchar(20);not null" json:"owner_id"` // 所有者学号，外键指向用户表的学号
+	StartDate       int64      `gorm:"" json:"start_date"`                        // 活动开始时间
+	EndDate         int64      `gorm:"" json:"end_date"`                          // 活动结束时间
+	Avatar          string     `gorm:"type:varchar(255);" json:"avatar"`          // 活动封面URL
+	DailyPointLimit uint       `gorm:"default:0" json:"daily_point_limit"`        // 每日积分上限，0表示不限制
+	CompletionBonus uint       `gorm:"default:0" json:"completion_bonus"`         // 完成活动所有栏目后的额外奖励积分，0表示无奖励
+	JoinMode        int        `gorm:"default:0;not null" json:"join_mode"`       // 加入方式：0 自由加入 1 需审核 2 邀请码
+	InviteCode      string     `gorm:"type:varchar(20);" json:"-"`                // 邀请码，仅加入方式为邀请码时有效，只返回给所有者
+	Capacity        uint       `gorm:"default:0;not null" json:"capacity"`        // 人数上限，0表示不限制，满员后加入的用户进入候补
+	Audience                   // 面向人群规则，普通用户只能看到并参与满足规则的活动
+	Status          int        `gorm:"default:0;not null;index" json:"status"`               // 状态：0 已发布 1 草稿 2 定时发布 3 已归档
+	PublishAt       *time.Time `gorm:"default:null" json:"publish_at"`                       // 定时发布时间，仅定时发布状态有效
+	Timezone        string     `gorm:"type:varchar(64);not null;default:''" json:"timezone"` // IANA 时区，为空表示默认时区 Asia/Shanghai
+	DayRollover     int        `gorm:"not null;default:0" json:"day_rollover"`               // 每日切换时刻（0-23 时），之前的打卡计入前一天
 	// 关联到用户
 	User User `gorm:"foreignKey:OwnerID;references:StudentID" json:"user"` // 关联到用户模型，使用学号作为外键
 }
+
+// Day 活动的日界规则，按天计算的打卡次数、连续天数与统计均以此划分
+func (a *Activity) Day() clock.Day {
+	return clock.New(a.Timezone, a.DayRollover)
+}
+
+// ActivityDay 查询活动的日界规则，活动不存在时返回默认规则
+func ActivityDay(db *gorm.DB, activityID uint) (clock.Day, error) {
+	var activity Activity
+	err := db.Unscoped().Select("timezone", "day_rollover").Where("id = ?", activityID).Limit(1).Find(&activity).Error
+	return activity.Day(), err
+}
+
+// ColumnDay 查询栏目所属活动的日界规则，栏目或项目不存在时返回默认规则
+func ColumnDay(db *gorm.DB, columnID uint) (clock.Day, error) {
+	var activity Activity
+	err := db.Unscoped().Model(&Activity{}).Select("activity.timezone", "activity.day_rollover").
+		Joins("JOIN project ON project.activity_id = activity.id").
+		Joins("JOIN `column` ON `column`.project_id = project.id").
+		Where("`column`.id = ?", columnID).Limit(1).Find(&activity).Error
+	return activity.Day(), err
+}
+
+// Visible 活动对非成员是否可见
+func (a *Activity) Visible() bool {
+	return a.Status == ActivityPublished || a.Status == ActivityArchived
+}
+
+// ActivityStatusScope 只保留 studentID 可见状态的活动：已发布、已归档，或本人为成员的草稿与定时发布活动
+func ActivityStatusScope(studentID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("(activity.status IN ? OR "+isMember+")", []int{ActivityPublished, ActivityArchived}, studentID)
+	}
+}
+
+// CheckActivityWritable 活动已归档时返回 ErrActivityArchived
+func CheckActivityWritable(db *gorm.DB, activityID uint) error {
+	var statuses []int
+	if err := db.Unscoped().Model(&Activity{}).Where("id = ?", activityID).Limit(1).Pluck("status", &statuses).Error; err != nil {
+		return err
+	}
+	if len(statuses) > 0 && statuses[0] == ActivityArchived {
+		return ErrActivityArchived
+	}
+	return nil
+}
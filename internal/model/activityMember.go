@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 活动成员角色，数值越大权限越高
+const (
+	MemberReviewer = iota + 1 // 审核员：审核活动内的打卡
+	MemberManager             // 管理员：额外可管理活动、项目、栏目、参与者及审核员、管理员
+	MemberOwner               // 所有者：额外可删除活动并转让所有权，每个活动有且只有一个
+)
+
+// ActivityMember 活动的管理成员，所有者与 Activity.OwnerID 保持一致
+type ActivityMember struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ActivityID uint      `gorm:"not null;uniqueIndex:idx_activity_student" json:"activity_id"`
+	StudentID  string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_activity_student;index" json:"student_id"`
+	Role       int       `gorm:"not null" json:"role"`                                   // 1 审核员 2 管理员 3 所有者
+	InvitedBy  string    `gorm:"type:varchar(20);not null;default:''" json:"invited_by"` // 邀请人学号
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	User       User      `gorm:"foreignKey:StudentID;references:StudentID" json:"-"`
+}
+
+// MemberRole 查询用户在活动中的角色，不是成员时返回 0
+func MemberRole(db *gorm.DB, activityID uint, studentID string) (int, error) {
+	var roles []int
+	if err := db.Model(&ActivityMember{}).Where("activity_id = ? AND student_id = ?", activityID, studentID).
+		Limit(1).Pluck("role", &roles).Error; err != nil {
+		return 0, err
+	}
+	if len(roles) == 0 {
+		return 0, nil
+	}
+	return roles[0], nil
+}
+
+// MemberColumnIDs 用户至少拥有 minRole 角色的活动下全部栏目 ID 的子查询
+func MemberColumnIDs(db *gorm.DB, studentID string, minRole int) *gorm.DB {
+	return db.Table("`column`").Select("`column`.id").
+		Joins("JOIN project ON project.id = `column`.project_id").
+		Joins("JOIN activity_member m ON m.activity_id = project.activity_id").
+		Where("m.student_id = ? AND m.role >= ?", studentID, minRole)
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// ActivityDailyStat 活动每日统计，由 stats/dashboard 的定时任务按活动的日界规则预先计算
+type ActivityDailyStat struct {
+	ActivityID          uint      `gorm:"not null;uniqueIndex:idx_activity_date" json:"-"`
+	Date                int64     `gorm:"not null;uniqueIndex:idx_activity_date" json:"date"` // 日期，格式同活动的 20060102
+	ActivePunchers      uint      `gorm:"not null" json:"active_punchers"`                    // 当日打卡人数
+	NewParticipants     uint      `gorm:"not null" json:"new_participants"`                   // 当日首次打卡人数
+	PunchCount          uint      `gorm:"not null" json:"punch_count"`                        // 当日打卡数
+	ApprovedCount       uint      `gorm:"not null" json:"approved_count"`                     // 当日打卡中审核通过的数量
+	RejectedCount       uint      `gorm:"not null" json:"rejected_count"`                     // 当日打卡中审核不通过的数量
+	MedianReviewLatency int64     `gorm:"not null" json:"median_review_latency"`              // 当日打卡的审核耗时中位数（秒），无已审核打卡时为 0
+	UpdatedAt           time.Time `json:"-"`
+}
+
+// ActivityDashboard 活动看板中按整个活动聚合的部分，栏目参与度与留存漏斗以 JSON 存储
+type ActivityDashboard struct {
+	ActivityID          uint      `gorm:"primaryKey;autoIncrement:false" json:"activity_id"`
+	Participants        uint      `gorm:"not null" json:"participants"`          // 参与（打过卡的）人数
+	PunchCount          uint      `gorm:"not null" json:"punch_count"`           // 打卡总数
+	ApprovedCount       uint      `gorm:"not null" json:"approved_count"`        // 审核通过数
+	RejectedCount       uint      `gorm:"not null" json:"rejected_count"`        // 审核不通过数
+	MedianReviewLatency int64     `gorm:"not null" json:"median_review_latency"` // 审核耗时中位数（秒）
+	Columns             string    `gorm:"type:mediumtext;not null" json:"-"`
+	Funnel              string    `gorm:"type:mediumtext;not null" json:"-"`
+	RefreshedAt         time.Time `gorm:"not null" json:"refreshed_at"`
+	DayRule             string    `gorm:"type:varchar(80);not null;default:''" json:"-"` // 计算时活动的时区与日界，变化后需重新计算每日统计
+}
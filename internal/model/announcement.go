@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Announcement 活动或项目公告，发布时间之前及过期之后对参与者不可见
+type Announcement struct {
+	Model
+	ActivityID uint       `gorm:"not null;index:idx_activity_publish,priority:1" json:"activity_id"`
+	ProjectID  uint       `gorm:"not null;default:0;index" json:"project_id"` // 所属项目，0 表示面向整个活动
+	Title      string     `gorm:"type:varchar(100);not null" json:"title"`
+	Body       string     `gorm:"type:text;not null" json:"body"`
+	Pinned     bool       `gorm:"not null;default:false" json:"pinned"`                             // 是否置顶
+	PublishAt  time.Time  `gorm:"not null;index:idx_activity_publish,priority:2" json:"publish_at"` // 发布时间
+	ExpiresAt  *time.Time `gorm:"default:null" json:"expires_at"`                                   // 过期时间，为空表示不过期
+	AuthorID   string     `gorm:"type:varchar(20);not null" json:"author_id"`                       // 发布人学号
+}
+
+// AnnouncementRead 用户已读公告的记录，没有记录即为未读
+type AnnouncementRead struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	AnnouncementID uint      `gorm:"not null;uniqueIndex:idx_announcement_user" json:"announcement_id"`
+	UserID         uint      `gorm:"not null;uniqueIndex:idx_announcement_user;index" json:"user_id"`
+	ReadAt         time.Time `gorm:"not null" json:"read_at"`
+}
+
+// AnnouncementActiveScope 只保留当前处于发布期内的公告
+func AnnouncementActiveScope(now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("announcement.publish_at <= ? AND (announcement.expires_at IS NULL OR announcement.expires_at > ?)", now, now)
+	}
+}
+
+// AnnouncementAudienceScope 只保留 u 可见的公告：活动公告，或所属项目对 u 可见的项目公告，
+// 项目可见规则与 ProjectAudienceScope 一致（活动规则由调用方校验），u 为 nil 时不过滤
+func AnnouncementAudienceScope(u *User) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if u == nil {
+			return db
+		}
+		match, args := audienceMatch("project", u)
+		return db.Where("(announcement.project_id = 0"+
+			" OR EXISTS (SELECT 1 FROM activity_member m WHERE m.activity_id = announcement.activity_id AND m.student_id = ?)"+
+			" OR EXISTS (SELECT 1 FROM project WHERE project.id = announcement.project_id AND (project.owner_id = ? OR ("+match+"))))",
+			append([]any{u.StudentID, u.StudentID}, args...)...)
+	}
+}
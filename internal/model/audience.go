@@ -0,0 +1,109 @@
+package model
+
+import (
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Audience 面向人群规则，各字段为逗号分隔的取值列表，为空表示不限制，同时设置多个字段时需全部满足
+type Audience struct {
+	AudienceColleges string `gorm:"type:varchar(1000);not null;default:''" json:"audience_colleges" excel:"限定学院"` // 限定学院
+	AudienceGrades   string `gorm:"type:varchar(255);not null;default:''" json:"audience_grades" excel:"限定年级"`    // 限定年级
+	AudienceMajors   string `gorm:"type:varchar(1000);not null;default:''" json:"audience_majors" excel:"限定专业"`   // 限定专业
+}
+
+// Allows 判断用户资料是否满足规则
+func (a Audience) Allows(u *User) bool {
+	return inList(a.AudienceColleges, u.College) && inList(a.AudienceGrades, u.Grade) && inList(a.AudienceMajors, u.Major)
+}
+
+func inList(list, value string) bool {
+	return list == "" || slices.Contains(strings.Split(list, ","), value)
+}
+
+// NormalizeAudience 整理规则取值列表：支持中英文逗号分隔，去除空白与重复项
+func NormalizeAudience(list string) string {
+	var values []string
+	for _, v := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '，' }) {
+		if v = strings.TrimSpace(v); v != "" && !slices.Contains(values, v) {
+			values = append(values, v)
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+// Normalize 整理全部规则字段
+func (a *Audience) Normalize() {
+	a.AudienceColleges = NormalizeAudience(a.AudienceColleges)
+	a.AudienceGrades = NormalizeAudience(a.AudienceGrades)
+	a.AudienceMajors = NormalizeAudience(a.AudienceMajors)
+}
+
+// AudienceViewer 查询需要按面向人群规则过滤的用户，管理员（roleID 不为 0）返回 nil 表示不过滤
+func AudienceViewer(db *gorm.DB, userID uint, roleID int) (*User, error) {
+	if roleID > 0 {
+		return nil, nil
+	}
+	var u User
+	if err := db.First(&u, userID).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// audienceMatch 生成 table 的规则对 u 成立的 SQL 条件
+func audienceMatch(table string, u *User) (string, []any) {
+	return "(" + table + ".audience_colleges = '' OR FIND_IN_SET(?, " + table + ".audience_colleges)) AND " +
+			"(" + table + ".audience_grades = '' OR FIND_IN_SET(?, " + table + ".audience_grades)) AND " +
+			"(" + table + ".audience_majors = '' OR FIND_IN_SET(?, " + table + ".audience_majors))",
+		[]any{u.College, u.Grade, u.Major}
+}
+
+// isMember 活动成员条件，成员不受面向人群规则限制
+const isMember = "EXISTS (SELECT 1 FROM activity_member m WHERE m.activity_id = activity.id AND m.student_id = ?)"
+
+// ActivityAudienceScope 只保留 u 可见的活动：活动成员或满足活动规则，u 为 nil 时不过滤
+func ActivityAudienceScope(u *User) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if u == nil {
+			return db
+		}
+		match, args := audienceMatch("activity", u)
+		return db.Where("("+isMember+" OR ("+match+"))", append([]any{u.StudentID}, args...)...)
+	}
+}
+
+// ProjectAudienceScope 只保留 u 可见的项目（或项目下的栏目）：活动成员、项目所有者本人，或同时满足活动与项目规则，
+// 查询需已关联 activity 与 project 表，u 为 nil 时不过滤
+func ProjectAudienceScope(u *User) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if u == nil {
+			return db
+		}
+		activityMatch, activityArgs := audienceMatch("activity", u)
+		projectMatch, projectArgs := audienceMatch("project", u)
+		args := append([]any{u.StudentID, u.StudentID}, activityArgs...)
+		return db.Where("("+isMember+" OR project.owner_id = ? OR ("+activityMatch+" AND "+projectMatch+"))",
+			append(args, projectArgs...)...)
+	}
+}
+
+// ActivityVisible 与 ActivityAudienceScope 规则一致，判断 u 能否查看 activity，u 为 nil 时始终可见
+func ActivityVisible(db *gorm.DB, activity *Activity, u *User) (bool, error) {
+	if u == nil || activity.Audience.Allows(u) {
+		return true, nil
+	}
+	role, err := MemberRole(db, activity.ID, u.StudentID)
+	return role > 0, err
+}
+
+// ProjectVisible 与 ProjectAudienceScope 规则一致，判断 u 能否查看 activity 下的 project，u 为 nil 时始终可见
+func ProjectVisible(db *gorm.DB, activity *Activity, project *Project, u *User) (bool, error) {
+	if u == nil || project.OwnerID == u.StudentID || activity.Audience.Allows(u) && project.Audience.Allows(u) {
+		return true, nil
+	}
+	role, err := MemberRole(db, activity.ID, u.StudentID)
+	return role > 0, err
+}
@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"activity-punch-system/internal/global/clock"
+	"time"
+)
 
 // Continuity actually in certain activity 打卡连续天数等 需注意默认值
 // todo: 打卡的时候记得更新
@@ -18,14 +21,9 @@ type FkUserActivity struct {
 	ActivityID uint `gorm:"not null;index:idx_user_activity,unique;index:idx_activity_score,priority:1" json:"-"`
 }
 
-// RefreshTo 仅仅是更新连续天数
-func (c *Continuity) RefreshTo(toTime time.Time) {
-	// 使用北京时区计算"天"
-	loc := time.FixedZone("CST", 8*60*60)
-	t := toTime.In(loc)
-	// 计算北京时间零点的 Unix 时间戳对应的天数
-	dayStart := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
-	day := dayStart.Unix() / (24 * 60 * 60)
+// RefreshTo 仅仅是更新连续天数，day 为活动的日界规则
+func (c *Continuity) RefreshTo(d clock.Day, toTime time.Time) {
+	day := d.Index(toTime)
 
 	if day-c.EndAt >= 1 {
 		c.Total++
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// ExportJob 后台导出任务，导出文件保存在 Storage.Home 下，过期后删除
+type ExportJob struct {
+	Model
+	Kind        string     `gorm:"type:varchar(20);not null;index:idx_kind_activity" json:"kind"` // 导出类型，如 activity、activity_rank
+	ActivityID  uint       `gorm:"not null;index:idx_kind_activity" json:"activity_id"`
+	TargetID    uint       `gorm:"not null;default:0" json:"target_id"`                    // 导出对象的 ID，如栏目、项目，按活动导出时与 ActivityID 相同
+	UserID      uint       `gorm:"not null;index" json:"user_id"`                          // 提交者
+	Format      string     `gorm:"type:varchar(10);not null;default:'xlsx'" json:"format"` // 导出格式：xlsx、csv、jsonl、zip
+	Shared      bool       `gorm:"not null;default:false" json:"shared"`                   // 是否所有登录用户均可下载，如活动结束后的排名
+	Status      int        `gorm:"not null;default:0" json:"status"`                       // 0 排队中 1 进行中 2 已完成 3 失败 4 已过期
+	Progress    int        `gorm:"not null;default:0" json:"progress"`                     // 进度百分比
+	FileName    string     `gorm:"type:varchar(255);not null" json:"file_name"`            // 下载时的文件名
+	ContentType string     `gorm:"type:varchar(100);not null" json:"content_type"`         // 下载时的 Content-Type
+	FilePath    string     `gorm:"type:varchar(255);not null;default:''" json:"-"`         // 导出文件在本地的路径
+	Error       string     `gorm:"type:varchar(255);not null;default:''" json:"error"`
+	FinishedAt  *time.Time `gorm:"default:null" json:"finished_at"`
+	ExpiresAt   *time.Time `gorm:"default:null" json:"expires_at"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// 参与状态
+const (
+	ParticipantPending  = iota // 待审核
+	ParticipantJoined          // 已加入
+	ParticipantWaitlist        // 候补，满员时加入或审核通过的用户，有空位时按申请顺序转为已加入
+	ParticipantRejected        // 审核未通过，可重新申请
+	ParticipantRemoved         // 被管理员移出，不能再次加入
+)
+
+// Participant 用户与活动的参与关系，只有已加入的用户可以在活动中打卡
+type Participant struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	ActivityID uint       `gorm:"not null;uniqueIndex:idx_activity_user;index:idx_activity_status,priority:1" json:"activity_id"`
+	UserID     uint       `gorm:"not null;uniqueIndex:idx_activity_user;index" json:"user_id"`
+	Status     int        `gorm:"not null;default:0;index:idx_activity_status,priority:2" json:"status"` // 0 待审核 1 已加入 2 候补 3 已拒绝 4 已移除
+	JoinedAt   *time.Time `gorm:"default:null" json:"joined_at"`                                         // 转为已加入的时间
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	User       User       `gorm:"foreignKey:UserID;references:ID" json:"-"`
+}
@@ -12,6 +12,7 @@ type Project struct {
 	Avatar          string   `gorm:"type:varchar(255);" json:"avatar" excel:"项目封面URL"`              // 项目封面URL
 	CompletionBonus uint     `gorm:"default:0" json:"completion_bonus" excel:"完成奖励积分"`              // 完成项目所有栏目后的额外奖励积分，0表示无奖励
 	ExemptFromLimit bool     `gorm:"default:false" json:"exempt_from_limit" excel:"不计入每日上限"`        // 该项目的积分是否不计入活动每日积分上限
+	Audience                 // 面向人群规则，在活动规则基础上进一步限定，为空表示沿用活动规则
 	// 关联到用户
 	User User `gorm:"foreignKey:OwnerID;references:StudentID" json:"user" excel:"-"` // 关联到用户模型，使用学号作为外键
 }
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -12,6 +14,23 @@ type Punch struct {
 	UserID   uint   `gorm:"not null" json:"user_id" excel:"-"`
 	Content  string `gorm:"type:text;not null" json:"content" excel:"打卡内容"`
 	Status   int    `gorm:"not null" json:"status" excel:"审核状态"` //status为  0 待审核   1 审核通过   2 不通过
+	// ReviewedAt 最近一次审核为通过/不通过的时间，待审核时为 null
+	ReviewedAt *time.Time `gorm:"default:null" json:"reviewed_at" excel:"审核时间"`
+}
+
+// CanViewPunch 打卡内容、图片、附件与历史版本的查看规则：本人可查看自己的打卡，
+// 打卡所属活动的审核员及以上可查看活动内的全部打卡。打卡详情、历史版本与收藏列表逐条按此判断，
+// 打卡列表在查询条件中按同一规则筛选（本人的打卡或 MemberColumnIDs）。
+// 私有桶模式下只有满足规则的请求才会签发读取链接
+func CanViewPunch(db *gorm.DB, activityID uint, ownerID uint, userID uint, studentID string) (bool, error) {
+	if ownerID == userID {
+		return true, nil
+	}
+	role, err := MemberRole(db, activityID, studentID)
+	if err != nil {
+		return false, err
+	}
+	return role >= MemberReviewer, nil
 }
 
 // todo: 打卡能被删除吗？
@@ -33,8 +52,12 @@ func (p *Punch) AfterCreate(tx *gorm.DB) (err error) {
 		return err
 	}
 
+	day, err := ActivityDay(tx, c.ActivityID)
+	if err != nil {
+		return err
+	}
 	flag := c.Total
-	c.RefreshTo(p.CreatedAt)
+	c.RefreshTo(day, p.CreatedAt)
 
 	if flag == 0 {
 		// 首次创建记录
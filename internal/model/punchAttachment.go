@@ -0,0 +1,73 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+)
+
+// 打卡附件类型，图片仍保存在 punch_img，其余类型保存在 punch_attachment
+const (
+	AttachmentImage    = "image"
+	AttachmentAudio    = "audio"
+	AttachmentVideo    = "video"
+	AttachmentDocument = "document"
+)
+
+// AttachmentKinds 全部附件类型
+var AttachmentKinds = []string{AttachmentImage, AttachmentAudio, AttachmentVideo, AttachmentDocument}
+
+// DefaultImageCount 栏目未配置附件规则时每次打卡可上传的图片数
+const DefaultImageCount = 9
+
+// AttachmentRule 栏目对某类附件的限制
+type AttachmentRule struct {
+	MaxCount    int `json:"max_count"`    // 每次打卡最多上传的数量
+	MaxSize     int `json:"max_size"`     // 单个文件大小上限（MB），0 表示使用该类型的默认上限
+	MaxDuration int `json:"max_duration"` // 音视频时长上限（秒），0 表示不限制
+}
+
+// AttachmentRules 栏目接受的附件类型及限制，未配置时只接受最多 DefaultImageCount 张图片
+type AttachmentRules map[string]AttachmentRule
+
+// Rule 返回某类附件的限制，栏目不接受该类型时 ok 为 false
+func (r AttachmentRules) Rule(kind string) (rule AttachmentRule, ok bool) {
+	if len(r) == 0 {
+		if kind == AttachmentImage {
+			return AttachmentRule{MaxCount: DefaultImageCount}, true
+		}
+		return AttachmentRule{}, false
+	}
+	rule, ok = r[kind]
+	return rule, ok && rule.MaxCount > 0
+}
+
+// Validate 校验附件规则的类型与数值
+func (r AttachmentRules) Validate() error {
+	for kind, rule := range r {
+		if !slices.Contains(AttachmentKinds, kind) {
+			return fmt.Errorf("不支持的附件类型：%s", kind)
+		}
+		if rule.MaxCount < 0 || rule.MaxSize < 0 || rule.MaxDuration < 0 {
+			return fmt.Errorf("附件类型 %s 的限制不能为负数", kind)
+		}
+		if kind == AttachmentImage && rule.MaxCount > DefaultImageCount {
+			return fmt.Errorf("每次打卡最多上传 %d 张图片", DefaultImageCount)
+		}
+	}
+	return nil
+}
+
+// PunchAttachment 打卡的音频、视频或文档附件
+type PunchAttachment struct {
+	Model
+	PunchID  uint   `gorm:"not null;index" json:"punch_id"`
+	ColumnID int    `gorm:"not null" json:"column_id"`
+	Kind     string `gorm:"type:varchar(10);not null" json:"kind"`       // 附件类型：audio、video、document
+	URL      string `gorm:"type:varchar(255);not null" json:"url"`       // 访问地址
+	Name     string `gorm:"type:varchar(255);not null" json:"name"`      // 原始文件名
+	MimeType string `gorm:"type:varchar(100);not null" json:"mime_type"` // 以存储中的对象为准
+	Size     int64  `gorm:"not null" json:"size"`                        // 文件大小（字节）
+	Duration int    `gorm:"not null;default:0" json:"duration"`          // 音视频时长（秒），由客户端上报
+
+	DownloadURL string `gorm:"-" json:"download_url,omitempty"` // 预签名下载链接，仅审核列表与详情返回
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// PunchDraft 打卡草稿，每个用户在每个栏目最多一份，提交成功或栏目结束后删除
+type PunchDraft struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_user_column" json:"user_id"`
+	ColumnID  int       `gorm:"not null;uniqueIndex:idx_user_column;index" json:"column_id"`
+	Content   string    `gorm:"type:text;not null" json:"content"`
+	Images    []string  `gorm:"serializer:json;type:text" json:"images"` // 已上传的图片引用，提交时再校验
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"` // 最近一次自动保存的时间
+}
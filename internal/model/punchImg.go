@@ -1,12 +1,41 @@
 package model
 
+// 打卡图片处理状态
+const (
+	PunchImgPending    = 0 // 待处理
+	PunchImgProcessed  = 1 // 已去除元数据并生成缩略图与中图
+	PunchImgSkipped    = 2 // 格式不支持或对象不在存储中，直接使用原图
+	PunchImgFailed     = 3 // 多次处理失败，不再重试，直接使用原图
+	PunchImgProcessing = 4 // 已被某个实例领取，正在处理
+)
+
 type PunchImg struct {
 	Model
 	//ID       int    `gorm:"primaryKey;autoIncrement" json:"id"`        // 自增ID
-	ColumnID int    `gorm:"not null" json:"column_id"`                 // 关联的栏目ID
-	ImgURL   string `gorm:"type:varchar(255);not null" json:"img_url"` // 图片URL
-	PunchID  uint   `gorm:"not null" json:"punch_id"`                  // 关联的打卡ID
+	ColumnID  int    `gorm:"not null" json:"column_id"`                               // 关联的栏目ID
+	ImgURL    string `gorm:"type:varchar(255);not null" json:"img_url"`               // 图片URL
+	PunchID   uint   `gorm:"not null" json:"punch_id"`                                // 关联的打卡ID
+	ThumbURL  string `gorm:"type:varchar(255);not null;default:''" json:"thumb_url"`  // 缩略图URL，未处理时为空
+	MediumURL string `gorm:"type:varchar(255);not null;default:''" json:"medium_url"` // 中图URL，未处理时为空
+	Process   int8   `gorm:"not null;default:0;index" json:"-"`                       // 处理状态
+	Attempts  int8   `gorm:"not null;default:0" json:"-"`                             // 处理失败的次数
 
 	// 关联到用户
 	Punch Punch `gorm:"foreignKey:PunchID;references:ID" json:"punch"` // 关联到用户模型，使用学号作为外键
 }
+
+// Thumb 列表展示用的缩略图，未生成时使用原图
+func (p *PunchImg) Thumb() string {
+	if p.ThumbURL != "" {
+		return p.ThumbURL
+	}
+	return p.ImgURL
+}
+
+// Medium 详情展示用的中图，未生成时使用原图
+func (p *PunchImg) Medium() string {
+	if p.MediumURL != "" {
+		return p.MediumURL
+	}
+	return p.ImgURL
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// PunchRevision 打卡的历史版本，提交与每次修改后各保存一份快照，供审核比对与申诉核查。
+// 审核与积分始终记在打卡 ID 上，与版本无关
+type PunchRevision struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	PunchID     uint      `gorm:"not null;uniqueIndex:idx_punch_version" json:"punch_id"`
+	Version     int       `gorm:"not null;uniqueIndex:idx_punch_version" json:"version"` // 从 1 开始递增
+	ColumnID    int       `gorm:"not null" json:"column_id"`
+	Content     string    `gorm:"type:text;not null" json:"content"`
+	Images      []string  `gorm:"serializer:json;type:text" json:"images"`      // 图片引用，与 punch_img.img_url 相同
+	Attachments []string  `gorm:"serializer:json;type:text" json:"attachments"` // 附件引用，与 punch_attachment.url 相同
+	PunchedAt   time.Time `gorm:"not null" json:"punched_at"`                   // 该版本的打卡时间
+	CreatedAt   time.Time `json:"created_at"`
+}
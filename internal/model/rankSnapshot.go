@@ -0,0 +1,10 @@
+package model
+
+// RankSnapshot 活动排名的每日快照，记录活动时区下某日结束时各用户的排名，用于计算排名变化
+type RankSnapshot struct {
+	ActivityID uint  `gorm:"not null;uniqueIndex:idx_activity_date_user,priority:1" json:"-"`
+	Date       int64 `gorm:"not null;uniqueIndex:idx_activity_date_user,priority:2" json:"date"` // 日期，格式同活动的 20060102
+	UserID     uint  `gorm:"not null;uniqueIndex:idx_activity_date_user,priority:3" json:"-"`
+	Rank       uint  `gorm:"column:ranks;not null" json:"rank"`
+	Score      uint  `gorm:"not null" json:"score"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// StorageGCReport 一次孤儿对象清理的报告，试运行时只统计不删除
+type StorageGCReport struct {
+	Model
+	DryRun     bool       `gorm:"not null;default:false" json:"dry_run"`
+	Manual     bool       `gorm:"not null;default:false" json:"manual"` // 是否由管理员手动触发
+	Status     int        `gorm:"not null;default:0" json:"status"`     // 0 进行中 1 已完成 2 失败
+	Scanned    int        `gorm:"not null;default:0" json:"scanned"`    // 前缀下的对象数
+	Referenced int        `gorm:"not null;default:0" json:"referenced"` // 仍被引用的对象数
+	Recent     int        `gorm:"not null;default:0" json:"recent"`     // 未被引用但仍在保留期内的对象数
+	Orphaned   int        `gorm:"not null;default:0" json:"orphaned"`   // 超过保留期的孤儿对象数
+	Deleted    int        `gorm:"not null;default:0" json:"deleted"`    // 实际删除的对象数，试运行为 0
+	Failed     int        `gorm:"not null;default:0" json:"failed"`     // 删除失败的对象数
+	Bytes      int64      `gorm:"not null;default:0" json:"bytes"`      // 孤儿对象的总大小
+	OrphanKeys string     `gorm:"type:mediumtext" json:"orphan_keys"`   // 孤儿对象 key，每行一个，最多记录 maxReportKeys 个
+	Error      string     `gorm:"type:varchar(255);not null;default:''" json:"error"`
+	FinishedAt *time.Time `gorm:"default:null" json:"finished_at"`
+}
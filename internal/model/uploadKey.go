@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// UploadKey 预签名上传签发给用户的对象 key，提交打卡时据此校验图片归属
+type UploadKey struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ObjectKey   string    `gorm:"type:varchar(255);not null;uniqueIndex" json:"object_key"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	ContentType string    `gorm:"type:varchar(100);not null" json:"content_type"` // 签发时约定的 MIME 类型
+	ExpiresAt   time.Time `gorm:"not null" json:"expires_at"`                     // 上传链接过期时间
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -1,8 +1,10 @@
 package activity
 
 import (
+	"activity-punch-system/internal/global/clock"
 	"activity-punch-system/internal/global/database"
 	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/permission"
 	"activity-punch-system/internal/global/response"
 	"activity-punch-system/internal/model"
 	"activity-punch-system/tools"
@@ -23,24 +25,38 @@ type Activity struct {
 
 // ActivityCreateReq 定义创建项目请求的结构体
 type ActivityCreateReq struct {
-	Name            string `json:"name" binding:"required,max=75"` // 项目名称
-	Description     string `json:"description" binding:"max=200"`  // 项目描述
-	StartDate       int64  `json:"start_date" binding:"required"`  // 项目开始日期
-	EndDate         int64  `json:"end_date" binding:"required"`    // 项目结束日期
-	Avatar          string `json:"avatar"`                         // 项目封面URL
-	DailyPointLimit uint   `json:"daily_point_limit"`              // 每日积分上限，可选，0表示不限制
-	CompletionBonus uint   `json:"completion_bonus"`               // 完成活动所有栏目后的额外奖励积分，可选，0表示无奖励
+	Name            string `json:"name" binding:"required,max=75"`      // 项目名称
+	Description     string `json:"description" binding:"max=200"`       // 项目描述
+	StartDate       int64  `json:"start_date" binding:"required"`       // 项目开始日期
+	EndDate         int64  `json:"end_date" binding:"required"`         // 项目结束日期
+	Avatar          string `json:"avatar"`                              // 项目封面URL
+	DailyPointLimit uint   `json:"daily_point_limit"`                   // 每日积分上限，可选，0表示不限制
+	CompletionBonus uint   `json:"completion_bonus"`                    // 完成活动所有栏目后的额外奖励积分，可选，0表示无奖励
+	JoinMode        int    `json:"join_mode" binding:"oneof=0 1 2"`     // 加入方式，可选：0 自由加入 1 需审核 2 邀请码
+	InviteCode      string `json:"invite_code" binding:"max=20"`        // 邀请码，可选，加入方式为邀请码且未填写时自动生成
+	Capacity        uint   `json:"capacity"`                            // 人数上限，可选，0表示不限制
+	Timezone        string `json:"timezone" binding:"max=64"`           // IANA 时区，可选，为空表示默认时区 Asia/Shanghai
+	DayRollover     int    `json:"day_rollover" binding:"min=0,max=23"` // 每日切换时刻（0-23 时），可选，之前的打卡计入前一天
+	model.Audience         // 面向人群规则，可选，逗号分隔，为空表示不限制
 }
 
 // ActivityUpdateReq 定义更新项目请求的结构体，使用指针类型支持部分更新
 type ActivityUpdateReq struct {
-	Name            *string `json:"name" binding:"omitempty,max=75"`         // 项目名称，可选
-	Description     *string `json:"description" binding:"omitempty,max=200"` // 项目描述，可选
-	StartDate       *int64  `json:"start_date"`                              // 项目开始日期，可选
-	EndDate         *int64  `json:"end_date"`                                // 项目结束日期，可选
-	Avatar          *string `json:"avatar"`                                  // 项目封面URL，可选
-	DailyPointLimit *uint   `json:"daily_point_limit"`                       // 每日积分上限，可选，0表示不限制
-	CompletionBonus *uint   `json:"completion_bonus"`                        // 完成活动所有栏目后的额外奖励积分，可选，0表示无奖励
+	Name             *string `json:"name" binding:"omitempty,max=75"`               // 项目名称，可选
+	Description      *string `json:"description" binding:"omitempty,max=200"`       // 项目描述，可选
+	StartDate        *int64  `json:"start_date"`                                    // 项目开始日期，可选
+	EndDate          *int64  `json:"end_date"`                                      // 项目结束日期，可选
+	Avatar           *string `json:"avatar"`                                        // 项目封面URL，可选
+	DailyPointLimit  *uint   `json:"daily_point_limit"`                             // 每日积分上限，可选，0表示不限制
+	CompletionBonus  *uint   `json:"completion_bonus"`                              // 完成活动所有栏目后的额外奖励积分，可选，0表示无奖励
+	JoinMode         *int    `json:"join_mode" binding:"omitempty,oneof=0 1 2"`     // 加入方式，可选
+	InviteCode       *string `json:"invite_code" binding:"omitempty,max=20"`        // 邀请码，可选
+	Capacity         *uint   `json:"capacity"`                                      // 人数上限，可选，0表示不限制
+	Timezone         *string `json:"timezone" binding:"omitempty,max=64"`           // IANA 时区，可选，空字符串表示默认时区
+	DayRollover      *int    `json:"day_rollover" binding:"omitempty,min=0,max=23"` // 每日切换时刻（0-23 时），可选
+	AudienceColleges *string `json:"audience_colleges"`                             // 限定学院，可选，逗号分隔，空字符串表示不限制
+	AudienceGrades   *string `json:"audience_grades"`                               // 限定年级，可选
+	AudienceMajors   *string `json:"audience_majors"`                               // 限定专业，可选
 }
 
 // CreateActivity 处理创建项目请求
@@ -64,6 +80,10 @@ func CreateActivity(c *gin.Context) {
 		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
 		return
 	}
+	if _, err := clock.LoadLocation(req.Timezone); err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("时区无效，应为 IANA 时区名，如 Asia/Shanghai"))
+		return
+	}
 
 	var existingactivity model.Activity
 	// 查询项目是否已存在
@@ -90,9 +110,26 @@ func CreateActivity(c *gin.Context) {
 		Avatar:          req.Avatar,
 		DailyPointLimit: req.DailyPointLimit,
 		CompletionBonus: req.CompletionBonus,
-	}
-
-	if err := database.DB.Create(&activity).Error; err != nil {
+		JoinMode:        req.JoinMode,
+		InviteCode:      req.InviteCode,
+		Capacity:        req.Capacity,
+		Timezone:        req.Timezone,
+		DayRollover:     req.DayRollover,
+		Audience:        req.Audience,
+		Status:          model.ActivityDraft, // 新建活动为草稿，配置完成后再发布
+	}
+	activity.Audience.Normalize()
+	if activity.JoinMode == JoinModeInvite && activity.InviteCode == "" {
+		activity.InviteCode = tools.RandString(8)
+	}
+
+	// 创建者同时成为活动的所有者成员
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&activity).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.ActivityMember{ActivityID: activity.ID, StudentID: StudentID, Role: model.MemberOwner}).Error
+	}); err != nil {
 		log.Error("创建项目失败", "error", err, "name", req.Name)
 		response.Fail(c, response.ErrDatabase.WithOrigin(err))
 		return
@@ -106,6 +143,7 @@ func CreateActivity(c *gin.Context) {
 
 	response.Success(c, gin.H{
 		"activity_id": activity.ID,
+		"status":      activity.Status,
 	})
 }
 
@@ -115,10 +153,24 @@ type ListActivitysReq struct {
 	Page     int    `form:"page" json:"page"`           // 页码，默认为1
 	PageSize int    `form:"page_size" json:"page_size"` // 每页大小，默认为10
 	Name     string `form:"name" json:"name"`           // 项目名称模糊查询
+	Status   *int   `form:"status" json:"status"`       // 活动状态筛选，草稿与定时发布只返回本人为成员的活动
 }
 
 // ListActivitys 获取项目列表（支持查询参数）
 func ListActivitys(c *gin.Context) {
+	userPayload, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	// 普通用户只能看到面向自己的活动
+	viewer, err := model.AudienceViewer(database.DB, userPayload.ID, userPayload.RoleID)
+	if err != nil {
+		log.Error("查询用户信息失败", "error", err, "user_id", userPayload.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+
 	// 绑定查询参数到结构体
 	var req ListActivitysReq
 	if err := c.ShouldBindQuery(&req); err != nil {
@@ -136,7 +188,13 @@ func ListActivitys(c *gin.Context) {
 	}
 
 	// 构建查询条件
-	query := database.DB.Model(&model.Activity{})
+	query := database.DB.Model(&model.Activity{}).
+		Scopes(model.ActivityAudienceScope(viewer), model.ActivityStatusScope(userPayload.StudentID))
+
+	// 根据活动状态筛选
+	if req.Status != nil {
+		query = query.Where("status = ?", *req.Status)
+	}
 
 	// 根据创建人学号筛选
 	if req.OwnerID != "" {
@@ -211,6 +269,12 @@ func UpdateActivity(c *gin.Context) {
 		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
 		return
 	}
+	if req.Timezone != nil {
+		if _, err := clock.LoadLocation(*req.Timezone); err != nil {
+			response.Fail(c, response.ErrInvalidRequest.WithTips("时区无效，应为 IANA 时区名，如 Asia/Shanghai"))
+			return
+		}
+	}
 
 	// 查询项目是否存在
 	var activity model.Activity
@@ -225,10 +289,12 @@ func UpdateActivity(c *gin.Context) {
 		return
 	}
 
-	// 权限检查
-	if activity.OwnerID != StudentID {
-		log.Warn("无权限更新项目", "id", id, "owner_id", activity.OwnerID, "student_id", StudentID)
-		response.Fail(c, response.ErrForbidden.WithTips("无权限更新该项目"))
+	// 权限检查：活动管理员及以上
+	if !permission.RequireRole(c, activity.ID, StudentID, model.MemberManager, "更新该活动") {
+		return
+	}
+	if activity.Status == model.ActivityArchived {
+		response.Fail(c, response.ErrForbidden.WithTips(model.ErrActivityArchived.Error()))
 		return
 	}
 
@@ -253,8 +319,46 @@ func UpdateActivity(c *gin.Context) {
 	if req.CompletionBonus != nil {
 		activity.CompletionBonus = *req.CompletionBonus
 	}
+	if req.JoinMode != nil {
+		activity.JoinMode = *req.JoinMode
+	}
+	if req.InviteCode != nil {
+		activity.InviteCode = *req.InviteCode
+	}
+	if activity.JoinMode == JoinModeInvite && activity.InviteCode == "" {
+		activity.InviteCode = tools.RandString(8)
+	}
+	if req.Capacity != nil {
+		activity.Capacity = *req.Capacity
+	}
+	if req.Timezone != nil {
+		activity.Timezone = *req.Timezone
+	}
+	if req.DayRollover != nil {
+		activity.DayRollover = *req.DayRollover
+	}
+	if req.AudienceColleges != nil {
+		activity.AudienceColleges = *req.AudienceColleges
+	}
+	if req.AudienceGrades != nil {
+		activity.AudienceGrades = *req.AudienceGrades
+	}
+	if req.AudienceMajors != nil {
+		activity.AudienceMajors = *req.AudienceMajors
+	}
+	activity.Audience.Normalize()
 
-	if err := database.DB.Save(&activity).Error; err != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&activity).Error; err != nil {
+			return err
+		}
+		// 人数上限调整后为候补用户补位
+		if req.Capacity != nil {
+			return fillFromWaitlist(tx, &activity)
+		}
+		return nil
+	})
+	if err != nil {
 		log.Error("更新项目失败", "error", err, "id", id)
 		response.Fail(c, response.ErrDatabase.WithOrigin(err))
 		return
@@ -303,9 +407,7 @@ func DeleteActivity(c *gin.Context) {
 		response.Fail(c, response.ErrDatabase.WithOrigin(err))
 		return
 	}
-	if activity.OwnerID != StudentID {
-		log.Warn("无权限删除活动", "id", id, "owner_id", activity.OwnerID, "student_id", StudentID)
-		response.Fail(c, response.ErrForbidden.WithTips("无权限删除该活动"))
+	if !permission.RequireRole(c, activity.ID, StudentID, model.MemberOwner, "删除该活动") {
 		return
 	}
 
@@ -391,9 +493,7 @@ func RestoreActivity(c *gin.Context) {
 		response.Fail(c, response.ErrDatabase.WithOrigin(err))
 		return
 	}
-	if activity.OwnerID != StudentID {
-		log.Warn("无权限还原活动", "id", id, "owner_id", activity.OwnerID, "student_id", StudentID)
-		response.Fail(c, response.ErrForbidden.WithTips("无权限还原该活动"))
+	if !permission.RequireRole(c, activity.ID, StudentID, model.MemberOwner, "还原该活动") {
 		return
 	}
 
@@ -476,11 +576,47 @@ func GetActivity(c *gin.Context) {
 		return
 	}
 
+	// 普通用户只能查看面向自己的活动及项目
+	userPayload, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	// 草稿与定时发布的活动只有成员可以查看
+	if !activity.Visible() {
+		role, err := model.MemberRole(database.DB, activity.ID, userPayload.StudentID)
+		if err != nil {
+			log.Error("查询活动成员失败", "error", err, "id", id)
+			response.Fail(c, response.ErrDatabase.WithOrigin(err))
+			return
+		}
+		if role == 0 {
+			response.Fail(c, response.ErrNotFound.WithTips("项目不存在"))
+			return
+		}
+	}
+	viewer, err := model.AudienceViewer(database.DB, userPayload.ID, userPayload.RoleID)
+	if err != nil {
+		log.Error("查询用户信息失败", "error", err, "user_id", userPayload.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	if visible, err := model.ActivityVisible(database.DB, &activity, viewer); err != nil {
+		log.Error("查询活动成员失败", "error", err, "id", id)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	} else if !visible {
+		response.Fail(c, response.ErrNotFound.WithTips("项目不存在"))
+		return
+	}
+
 	// 查询关联的所有项目信息
 	var projectsInActivity []ProjectInActivity
 	if err := database.DB.Model(&model.Project{}).
-		Select("ID, name, avatar, description").
-		Where("activity_id = ?", activity.ID).
+		Select("project.id, project.name, project.avatar, project.description").
+		Joins("JOIN activity ON activity.id = project.activity_id").
+		Scopes(model.ProjectAudienceScope(viewer)).
+		Where("project.activity_id = ?", activity.ID).
 		Find(&projectsInActivity).Error; err != nil {
 		log.Error("查询项目关联信息失败", "error", err, "activity_id", activity.ID)
 		response.Fail(c, response.ErrDatabase.WithOrigin(err))
@@ -522,8 +658,11 @@ func MineActivities(c *gin.Context) {
 		StartDate   int64  `gorm:"" json:"start_date"`                     // 活动开始时间
 		EndDate     int64  `gorm:"" json:"end_date"`                       // 活动结束时间
 		Avatar      string `gorm:"type:varchar(255);" json:"avatar"`       // 活动封面URL
+		Role        int    `gorm:"column:role" json:"role"`                // 本人在活动中的角色
 	}
-	if err := database.DB.Table("activity").Where("owner_id = ?", StudentID).
+	if err := database.DB.Table("activity").Select("activity.*, m.role").
+		Joins("JOIN activity_member m ON m.activity_id = activity.id").
+		Where("m.student_id = ?", StudentID).
 		Offset(offset).Limit(limit).
 		Find(&activities).Error; err != nil {
 		log.Error("查询管理员自己创建的所有活动失败", "error", err, "student_id", StudentID)
@@ -0,0 +1,207 @@
+package activity
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"activity-punch-system/tools"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ActivityCloneReq 定义克隆活动请求的结构体，StartDate 与 OffsetDays 二选一
+type ActivityCloneReq struct {
+	Name       *string `json:"name" binding:"omitempty,max=75"` // 新活动名称，可选，默认沿用原名称
+	StartDate  *int64  `json:"start_date"`                      // 新活动开始日期，所有日期按与原开始日期的差值平移
+	OffsetDays *int    `json:"offset_days"`                     // 所有日期平移的天数，可为负数
+}
+
+// CloneActivity 以已有活动为模板深度克隆活动及其下所有项目、栏目，日期整体平移，请求者成为所有者
+func CloneActivity(c *gin.Context) {
+	// 获取认证信息
+	payload, exists := c.Get("payload")
+	if !exists {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	userPayload, ok := payload.(*jwt.Claims)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	StudentID := userPayload.StudentID
+
+	id := c.Param("id")
+	if id == "" {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("活动ID不能为空"))
+		return
+	}
+	var req ActivityCloneReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Error("绑定克隆活动请求失败", "error", err)
+		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
+		return
+	}
+	if (req.StartDate == nil) == (req.OffsetDays == nil) {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("start_date 与 offset_days 需且仅需提供一个"))
+		return
+	}
+
+	var source model.Activity
+	if err := database.DB.First(&source, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Warn("活动不存在", "id", id)
+			response.Fail(c, response.ErrNotFound.WithTips("活动不存在"))
+			return
+		}
+		log.Error("查询活动失败", "error", err, "id", id)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+
+	offset := 0
+	if req.OffsetDays != nil {
+		offset = *req.OffsetDays
+	} else {
+		from, err1 := parseDate(source.StartDate)
+		to, err2 := parseDate(*req.StartDate)
+		if err1 != nil || err2 != nil {
+			response.Fail(c, response.ErrInvalidRequest.WithTips("开始日期格式应为 20060102"))
+			return
+		}
+		offset = int(to.Sub(from).Hours() / 24)
+	}
+
+	activity := source
+	activity.Model = model.Model{}
+	activity.User = model.User{}
+	activity.OwnerID = StudentID
+	// 克隆出的活动为草稿，确认后再发布
+	activity.Status = model.ActivityDraft
+	activity.PublishAt = nil
+	if activity.InviteCode != "" {
+		activity.InviteCode = tools.RandString(8)
+	}
+	if req.Name != nil {
+		activity.Name = *req.Name
+	}
+	if err := shiftDates(&activity.StartDate, &activity.EndDate, offset); err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithTips(err.Error()))
+		return
+	}
+
+	// 与创建活动一致，同名同开始日期的活动视为已存在
+	var count int64
+	if err := database.DB.Model(&model.Activity{}).
+		Where("name = ? AND start_date = ?", activity.Name, activity.StartDate).
+		Count(&count).Error; err != nil {
+		log.Error("数据库查询失败", "error", err, "name", activity.Name, "start_date", activity.StartDate)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	if count > 0 {
+		response.Fail(c, response.ErrAlreadyExists.WithTips("活动已存在，请修改名称或开始日期"))
+		return
+	}
+
+	var projectCount, columnCount int
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&activity).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&model.ActivityMember{ActivityID: activity.ID, StudentID: StudentID, Role: model.MemberOwner}).Error; err != nil {
+			return err
+		}
+		var projects []model.Project
+		if err := tx.Where("activity_id = ?", source.ID).Order("id ASC").Find(&projects).Error; err != nil {
+			return err
+		}
+		for _, p := range projects {
+			sourceProjectID := p.ID
+			p.Model = model.Model{}
+			p.Activity = model.Activity{}
+			p.User = model.User{}
+			p.ActivityID = activity.ID
+			p.OwnerID = StudentID
+			if err := shiftDates(&p.StartDate, &p.EndDate, offset); err != nil {
+				return err
+			}
+			if err := tx.Omit(clause.Associations).Create(&p).Error; err != nil {
+				return err
+			}
+			projectCount++
+
+			var columns []model.Column
+			if err := tx.Where("project_id = ?", sourceProjectID).Order("id ASC").Find(&columns).Error; err != nil {
+				return err
+			}
+			for _, col := range columns {
+				col.Model = model.Model{}
+				col.Project = model.Project{}
+				col.User = model.User{}
+				col.ProjectID = p.ID
+				col.OwnerID = StudentID
+				if err := shiftDates(&col.StartDate, &col.EndDate, offset); err != nil {
+					return err
+				}
+				if err := tx.Omit(clause.Associations).Create(&col).Error; err != nil {
+					return err
+				}
+				columnCount++
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errDateFormat) {
+		response.Fail(c, response.ErrInvalidRequest.WithTips(err.Error()))
+		return
+	}
+	if err != nil {
+		log.Error("克隆活动失败", "error", err, "id", id)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+
+	log.Info("活动克隆成功",
+		"source_id", source.ID,
+		"activity_id", activity.ID,
+		"owner_id", StudentID,
+		"offset_days", offset,
+	)
+
+	response.Success(c, gin.H{
+		"activity_id":   activity.ID,
+		"offset_days":   offset,
+		"project_count": projectCount,
+		"column_count":  columnCount,
+	})
+}
+
+// parseDate 解析 20060102 格式的日期
+func parseDate(date int64) (time.Time, error) {
+	return time.Parse("20060102", fmt.Sprintf("%08d", date))
+}
+
+var errDateFormat = errors.New("日期格式错误")
+
+// shiftDates 将 20060102 格式的开始、结束日期平移 days 天，未设置（为 0）的日期保持不变
+func shiftDates(start, end *int64, days int) error {
+	for _, d := range []*int64{start, end} {
+		if *d == 0 {
+			continue
+		}
+		t, err := parseDate(*d)
+		if err != nil {
+			return errors.Wrapf(errDateFormat, "日期 %d", *d)
+		}
+		t = t.AddDate(0, 0, days)
+		*d = int64(t.Year()*10000 + int(t.Month())*100 + t.Day())
+	}
+	return nil
+}
@@ -0,0 +1,476 @@
+package activity
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"activity-punch-system/tools"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 活动加入方式
+const (
+	JoinModeOpen     = iota // 自由加入
+	JoinModeApproval        // 需活动管理员审核
+	JoinModeInvite          // 凭邀请码加入
+)
+
+var (
+	errRemoved           = errors.New("已被移出该活动，无法再次加入")
+	errNotAudience       = errors.New("不在该活动的面向人群内，无法加入")
+	errInvalidInviteCode = errors.New("邀请码错误")
+	errNotPublished      = errors.New("活动未发布或已归档，无法加入")
+)
+
+// JoinActivityReq 定义加入活动请求的结构体
+type JoinActivityReq struct {
+	InviteCode string `json:"invite_code"` // 邀请码，加入方式为邀请码时必填
+}
+
+// ParticipantsReq 定义批量审核参与者请求的结构体
+type ParticipantsReq struct {
+	UserIDs []uint `json:"user_ids" binding:"required,min=1"` // 用户ID列表
+}
+
+// participantInfo 参与者列表与导出中的一行
+type participantInfo struct {
+	UserID    uint       `gorm:"column:user_id" json:"user_id" excel:"用户ID"`
+	StudentID string     `gorm:"column:student_id" json:"student_id" excel:"学号"`
+	Name      string     `gorm:"column:name" json:"name" excel:"姓名"`
+	College   string     `gorm:"column:college" json:"college" excel:"学院"`
+	Major     string     `gorm:"column:major" json:"major" excel:"专业"`
+	Grade     string     `gorm:"column:grade" json:"grade" excel:"年级"`
+	Status    int        `gorm:"column:status" json:"status" excel:"状态"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"created_at" excel:"申请时间"`
+	JoinedAt  *time.Time `gorm:"column:joined_at" json:"joined_at" excel:"加入时间"`
+}
+
+// JoinActivity 处理加入活动请求，满员时进入候补，需审核的活动进入待审核
+func JoinActivity(c *gin.Context) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	var req JoinActivityReq
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
+		return
+	}
+	id := c.Param("id")
+	viewer, err := model.AudienceViewer(database.DB, user.ID, user.RoleID)
+	if err != nil {
+		log.Error("查询用户信息失败", "error", err, "user_id", user.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+
+	var status int
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定活动行，保证人数上限判断与写入之间没有并发加入
+		var activity model.Activity
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&activity, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if activity.Status != model.ActivityPublished {
+			return errNotPublished
+		}
+		if visible, err := model.ActivityVisible(tx, &activity, viewer); err != nil {
+			return err
+		} else if !visible {
+			return errNotAudience
+		}
+		if activity.JoinMode == JoinModeInvite && req.InviteCode != activity.InviteCode {
+			return errInvalidInviteCode
+		}
+
+		var p model.Participant
+		err := tx.Where("activity_id = ? AND user_id = ?", activity.ID, user.ID).First(&p).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		switch {
+		case err == nil && p.Status == model.ParticipantRemoved:
+			return errRemoved
+		case err == nil && p.Status != model.ParticipantRejected:
+			// 已加入、候补或待审核时保持原状态
+			status = p.Status
+			return nil
+		}
+
+		p.ActivityID, p.UserID = activity.ID, user.ID
+		if activity.JoinMode == JoinModeApproval {
+			p.Status = model.ParticipantPending
+		} else if p.Status, err = admitStatus(tx, &activity, 1); err != nil {
+			return err
+		}
+		if p.Status == model.ParticipantJoined {
+			now := time.Now()
+			p.JoinedAt = &now
+		}
+		status = p.Status
+		return tx.Save(&p).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.Fail(c, response.ErrNotFound.WithTips("活动不存在"))
+	case errors.Is(err, errInvalidInviteCode):
+		response.Fail(c, response.ErrForbidden.WithTips("邀请码错误"))
+	case errors.Is(err, errRemoved), errors.Is(err, errNotAudience), errors.Is(err, errNotPublished):
+		response.Fail(c, response.ErrForbidden.WithTips(err.Error()))
+	case err != nil:
+		log.Error("加入活动失败", "error", err, "id", id, "user_id", user.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+	default:
+		log.Info("加入活动", "id", id, "user_id", user.ID, "status", status)
+		response.Success(c, gin.H{"status": status})
+	}
+}
+
+// LeaveActivity 处理退出活动请求，退出后可重新加入，空出的名额由候补补位
+func LeaveActivity(c *gin.Context) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	id := c.Param("id")
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var activity model.Activity
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&activity, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if activity.Status == model.ActivityArchived {
+			return model.ErrActivityArchived
+		}
+		r := tx.Where("activity_id = ? AND user_id = ? AND status <> ?", activity.ID, user.ID, model.ParticipantRemoved).
+			Delete(&model.Participant{})
+		if r.Error != nil {
+			return r.Error
+		}
+		if r.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return fillFromWaitlist(tx, &activity)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, response.ErrNotFound.WithTips("未加入该活动"))
+		return
+	}
+	if errors.Is(err, model.ErrActivityArchived) {
+		response.Fail(c, response.ErrForbidden.WithTips(err.Error()))
+		return
+	}
+	if err != nil {
+		log.Error("退出活动失败", "error", err, "id", id, "user_id", user.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	response.Success(c)
+}
+
+// MyEnrollment 获取当前用户在活动中的参与状态，未申请时 status 为 null
+func MyEnrollment(c *gin.Context) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	var p model.Participant
+	err := database.DB.Where("activity_id = ? AND user_id = ?", c.Param("id"), user.ID).First(&p).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Success(c, gin.H{"status": nil})
+		return
+	}
+	if err != nil {
+		log.Error("查询参与状态失败", "error", err, "id", c.Param("id"))
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	response.Success(c, p)
+}
+
+// ListParticipants 获取活动参与者列表，可按状态筛选，仅所有者可查看
+func ListParticipants(c *gin.Context) {
+	activity, ok := loadManagedActivity(c)
+	if !ok {
+		return
+	}
+	offset, limit := tools.GetPage(c)
+	query := participantQuery(activity.ID, c.Query("status"))
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Error("查询参与者总数失败", "error", err, "id", activity.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	var list []participantInfo
+	if err := query.Order("pa.id ASC").Offset(offset).Limit(limit).Scan(&list).Error; err != nil {
+		log.Error("查询参与者失败", "error", err, "id", activity.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	var joined int64
+	if err := database.DB.Model(&model.Participant{}).
+		Where("activity_id = ? AND status = ?", activity.ID, model.ParticipantJoined).
+		Count(&joined).Error; err != nil {
+		log.Error("查询参与者总数失败", "error", err, "id", activity.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	response.Success(c, gin.H{
+		"total":       total,
+		"list":        list,
+		"joined":      joined,
+		"capacity":    activity.Capacity,
+		"join_mode":   activity.JoinMode,
+		"invite_code": activity.InviteCode,
+	})
+}
+
+// ApproveParticipants 批量通过待审核或已拒绝的申请，满员时转为候补
+func ApproveParticipants(c *gin.Context) {
+	reviewParticipants(c, true)
+}
+
+// RejectParticipants 批量拒绝待审核或候补中的申请
+func RejectParticipants(c *gin.Context) {
+	reviewParticipants(c, false)
+}
+
+func reviewParticipants(c *gin.Context, approve bool) {
+	activity, ok := loadWritableManagedActivity(c)
+	if !ok {
+		return
+	}
+	var req ParticipantsReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
+		return
+	}
+
+	result := map[uint]int{}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(activity, activity.ID).Error; err != nil {
+			return err
+		}
+		from := []int{model.ParticipantPending, model.ParticipantRejected}
+		if !approve {
+			from = []int{model.ParticipantPending, model.ParticipantWaitlist}
+		}
+		var list []model.Participant
+		if err := tx.Where("activity_id = ? AND user_id IN ? AND status IN ?", activity.ID, req.UserIDs, from).
+			Order("id ASC").Find(&list).Error; err != nil {
+			return err
+		}
+		for _, p := range list {
+			updates := map[string]any{"status": model.ParticipantRejected}
+			if approve {
+				status, err := admitStatus(tx, activity, 1)
+				if err != nil {
+					return err
+				}
+				updates["status"] = status
+				if status == model.ParticipantJoined {
+					updates["joined_at"] = time.Now()
+				}
+			}
+			if err := tx.Model(&p).Updates(updates).Error; err != nil {
+				return err
+			}
+			result[p.UserID] = updates["status"].(int)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("审核参与者失败", "error", err, "id", activity.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	response.Success(c, gin.H{"result": result})
+}
+
+// RemoveParticipant 将用户移出活动，移出后不能再次加入，空出的名额由候补补位
+func RemoveParticipant(c *gin.Context) {
+	activity, ok := loadWritableManagedActivity(c)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("用户ID错误"))
+		return
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(activity, activity.ID).Error; err != nil {
+			return err
+		}
+		r := tx.Model(&model.Participant{}).
+			Where("activity_id = ? AND user_id = ? AND status <> ?", activity.ID, userID, model.ParticipantRemoved).
+			Update("status", model.ParticipantRemoved)
+		if r.Error != nil {
+			return r.Error
+		}
+		if r.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return fillFromWaitlist(tx, activity)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, response.ErrNotFound.WithTips("该用户不是活动参与者"))
+		return
+	}
+	if err != nil {
+		log.Error("移出参与者失败", "error", err, "id", activity.ID, "user_id", userID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	log.Info("移出参与者", "id", activity.ID, "user_id", userID)
+	response.Success(c)
+}
+
+// ExportParticipants 导出活动参与者，format 可选 xlsx、csv、jsonl，可按状态筛选
+func ExportParticipants(c *gin.Context) {
+	activity, ok := loadManagedActivity(c)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", tools.FormatExcel)
+	ext, contentType, err := tools.ExportFileType(format, false)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("format 仅支持 xlsx、csv、jsonl"))
+		return
+	}
+	rows, err := participantQuery(activity.ID, c.Query("status")).Order("pa.id ASC").Rows()
+	if err != nil {
+		log.Error("查询参与者失败", "error", err, "id", activity.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	defer rows.Close()
+
+	name := fmt.Sprintf("活动%d参与者", activity.ID)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.QueryEscape(name+ext))
+	tw, err := tools.NewTableWriter(format, c.Writer, false)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
+		return
+	}
+	table, err := tools.BeginTable(tw, name, participantInfo{})
+	if err == nil {
+		for rows.Next() {
+			var p participantInfo
+			if err = database.DB.ScanRows(rows, &p); err != nil {
+				break
+			}
+			if err = table.Write(&p); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err != nil {
+		// 响应头已发出，只能记录错误
+		log.Error("导出参与者失败", "error", err, "id", activity.ID)
+	}
+}
+
+func participantQuery(activityID uint, status string) *gorm.DB {
+	query := database.DB.Table("participant pa").
+		Select("pa.user_id, u.student_id, u.name, u.college, u.major, u.grade, pa.status, pa.created_at, pa.joined_at").
+		Joins("JOIN user u ON u.id = pa.user_id").
+		Where("pa.activity_id = ?", activityID)
+	if status != "" {
+		query = query.Where("pa.status = ?", status)
+	}
+	return query
+}
+
+// admitStatus 按人数上限决定新加入的 n 个名额是否可直接加入，满员时为候补，需在锁定活动行的事务中调用
+func admitStatus(tx *gorm.DB, activity *model.Activity, n int64) (int, error) {
+	if activity.Capacity == 0 {
+		return model.ParticipantJoined, nil
+	}
+	var joined int64
+	if err := tx.Model(&model.Participant{}).
+		Where("activity_id = ? AND status = ?", activity.ID, model.ParticipantJoined).
+		Count(&joined).Error; err != nil {
+		return 0, err
+	}
+	if joined+n > int64(activity.Capacity) {
+		return model.ParticipantWaitlist, nil
+	}
+	return model.ParticipantJoined, nil
+}
+
+// fillFromWaitlist 按申请顺序将候补用户转为已加入直至满员，需在锁定活动行的事务中调用
+func fillFromWaitlist(tx *gorm.DB, activity *model.Activity) error {
+	query := tx.Model(&model.Participant{}).
+		Where("activity_id = ? AND status = ?", activity.ID, model.ParticipantWaitlist).
+		Order("id ASC")
+	if activity.Capacity > 0 {
+		var joined int64
+		if err := tx.Model(&model.Participant{}).
+			Where("activity_id = ? AND status = ?", activity.ID, model.ParticipantJoined).
+			Count(&joined).Error; err != nil {
+			return err
+		}
+		free := int64(activity.Capacity) - joined
+		if free <= 0 {
+			return nil
+		}
+		query = query.Limit(int(free))
+	}
+	var ids []uint
+	if err := query.Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+		return err
+	}
+	return tx.Model(&model.Participant{}).Where("id IN ?", ids).
+		Updates(map[string]any{"status": model.ParticipantJoined, "joined_at": time.Now()}).Error
+}
+
+// loadManagedActivity 查询路径参数中的活动并校验请求者为活动管理员及以上
+func loadManagedActivity(c *gin.Context) (*model.Activity, bool) {
+	activity, _, ok := loadMemberActivity(c, model.MemberManager, "管理该活动的参与者")
+	return activity, ok
+}
+
+// loadWritableManagedActivity 同 loadManagedActivity，并要求活动未归档，用于修改参与者的接口
+func loadWritableManagedActivity(c *gin.Context) (*model.Activity, bool) {
+	activity, _, ok := loadWritableMemberActivity(c, model.MemberManager, "管理该活动的参与者")
+	return activity, ok
+}
+
+// backfillParticipants 参与关系上线前已在活动中打过卡的用户视为已加入，仅在参与表为空时执行一次
+func backfillParticipants() {
+	var count int64
+	if err := database.DB.Model(&model.Participant{}).Limit(1).Count(&count).Error; err != nil {
+		log.Error("查询参与者失败", "error", err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+	r := database.DB.Exec(`
+		INSERT IGNORE INTO participant (activity_id, user_id, status, joined_at, created_at, updated_at)
+		SELECT activity_id, user_id, ?, NOW(), NOW(), NOW() FROM continuity`, model.ParticipantJoined)
+	if r.Error != nil {
+		log.Error("回填参与者失败", "error", r.Error)
+		return
+	}
+	log.Info("回填参与者完成", "count", r.RowsAffected)
+}
@@ -0,0 +1,411 @@
+package activity
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/permission"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"activity-punch-system/tools"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 导入文件大小上限
+const maxImportSize = 10 << 20
+
+var (
+	// columnSheetPattern 匹配导出时生成的栏目工作表名，如 “项目12(晨读)下的栏目”，也可写作 “项目晨读下的栏目”
+	columnSheetPattern = regexp.MustCompile(`^项目(.+)下的栏目$`)
+	// projectRefPattern 匹配工作表名中以 ID 引用的项目，如 “12(晨读)”
+	projectRefPattern = regexp.MustCompile(`^(\d+)(\(.*\))?$`)
+)
+
+// 导入时可更新的字段，所有者与所属关系不随导入改变
+var (
+	projectImportFields = []string{"name", "description", "start_date", "end_date", "avatar", "completion_bonus", "exempt_from_limit",
+		"audience_colleges", "audience_grades", "audience_majors"}
+	columnImportFields = []string{"name", "description", "project_id", "start_date", "end_date", "avatar", "daily_punch_limit",
+		"point_earned", "start_time", "end_time", "optional", "min_word_limit", "max_word_limit"}
+)
+
+// importItem 导入预览中的一条记录
+type importItem struct {
+	Sheet  string `json:"sheet"`
+	Row    int    `json:"row"`
+	Action string `json:"action"` // create 或 update
+	ID     uint   `json:"id,omitempty"`
+	Name   string `json:"name"`
+}
+
+type importProject struct {
+	importItem
+	row model.Project
+}
+
+type importColumn struct {
+	importItem
+	row     model.Column
+	project *importProject // 所属项目在本次导入中时不为 nil，用于写入新建项目的 ID
+}
+
+// ImportActivity 从 Excel 批量导入活动下的项目与栏目，布局与活动导出一致：
+// 名为 “项目” 或以 “下的项目” 结尾的工作表为项目，名为 “项目<ID或名称>下的栏目” 的工作表为该项目的栏目，
+// id 列填写已有记录的 ID 时更新，留空时创建。全部行校验通过后才在同一事务中写入，dry_run=true 时只返回预览
+func ImportActivity(c *gin.Context) {
+	// 获取认证信息
+	payload, exists := c.Get("payload")
+	if !exists {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	userPayload, ok := payload.(*jwt.Claims)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	StudentID := userPayload.StudentID
+	dryRun := c.Query("dry_run") == "true"
+
+	id := c.Param("id")
+	var activity model.Activity
+	if err := database.DB.First(&activity, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrNotFound.WithTips("活动不存在"))
+			return
+		}
+		log.Error("查询活动失败", "error", err, "id", id)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	if !permission.RequireRole(c, activity.ID, StudentID, model.MemberManager, "导入该活动") {
+		return
+	}
+	if activity.Status == model.ActivityArchived {
+		response.Fail(c, response.ErrForbidden.WithTips(model.ErrActivityArchived.Error()))
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("请上传 Excel 文件"))
+		return
+	}
+	if fileHeader.Size > maxImportSize {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("文件不能超过 10MB"))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
+		return
+	}
+	defer file.Close()
+	f, err := excelize.OpenReader(file)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("无法读取 Excel 文件"))
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	projects, columns, rowErrs, err := readImport(f, &activity)
+	if err != nil {
+		log.Error("读取导入文件失败", "error", err, "id", id)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+
+	result := gin.H{
+		"dry_run":  dryRun,
+		"applied":  false,
+		"errors":   rowErrs,
+		"projects": projectItems(projects),
+		"columns":  columnItems(columns),
+	}
+	if len(rowErrs) > 0 || dryRun {
+		response.Success(c, result)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, p := range projects {
+			if p.Action == "create" {
+				p.row.ActivityID = activity.ID
+				p.row.OwnerID = StudentID
+				if err := tx.Omit(clause.Associations).Create(&p.row).Error; err != nil {
+					return err
+				}
+				p.importItem.ID = p.row.ID
+				continue
+			}
+			if err := tx.Model(&model.Project{Model: model.Model{ID: p.row.ID}}).
+				Select(projectImportFields).Updates(&p.row).Error; err != nil {
+				return err
+			}
+		}
+		for _, col := range columns {
+			if col.project != nil {
+				col.row.ProjectID = col.project.row.ID
+			}
+			if col.Action == "create" {
+				col.row.OwnerID = StudentID
+				if err := tx.Omit(clause.Associations).Create(&col.row).Error; err != nil {
+					return err
+				}
+				col.importItem.ID = col.row.ID
+				continue
+			}
+			if err := tx.Model(&model.Column{Model: model.Model{ID: col.row.ID}}).
+				Select(columnImportFields).Updates(&col.row).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("导入项目与栏目失败", "error", err, "id", id)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+
+	log.Info("项目与栏目导入成功",
+		"activity_id", activity.ID,
+		"projects", len(projects),
+		"columns", len(columns),
+		"student_id", StudentID,
+	)
+	result["applied"] = true
+	result["projects"] = projectItems(projects)
+	result["columns"] = columnItems(columns)
+	response.Success(c, result)
+}
+
+// readImport 读取并校验工作簿，返回待写入的项目与栏目，行级错误记入 rowErrs
+func readImport(f *excelize.File, activity *model.Activity) (projects []*importProject, columns []*importColumn, rowErrs []tools.RowError, err error) {
+	rowErrs = []tools.RowError{}
+
+	var existingProjects []model.Project
+	if err = database.DB.Where("activity_id = ?", activity.ID).Find(&existingProjects).Error; err != nil {
+		return
+	}
+	projectByID := map[uint]*model.Project{}
+	projectIDs := make([]uint, 0, len(existingProjects))
+	for i := range existingProjects {
+		projectByID[existingProjects[i].ID] = &existingProjects[i]
+		projectIDs = append(projectIDs, existingProjects[i].ID)
+	}
+	columnExists := map[uint]bool{}
+	if len(projectIDs) > 0 {
+		var ids []uint
+		if err = database.DB.Model(&model.Column{}).Where("project_id IN ?", projectIDs).Pluck("id", &ids).Error; err != nil {
+			return
+		}
+		for _, id := range ids {
+			columnExists[id] = true
+		}
+	}
+
+	var projectSheet string
+	var columnSheets []string
+	for _, sheet := range f.GetSheetList() {
+		switch {
+		case sheet == "项目" || strings.HasSuffix(sheet, "下的项目"):
+			if projectSheet != "" {
+				rowErrs = append(rowErrs, tools.RowError{Sheet: sheet, Message: "只能有一个项目工作表"})
+				continue
+			}
+			projectSheet = sheet
+		case columnSheetPattern.MatchString(sheet):
+			columnSheets = append(columnSheets, sheet)
+		}
+	}
+	if projectSheet == "" && len(columnSheets) == 0 {
+		rowErrs = append(rowErrs, tools.RowError{Message: "未找到项目或栏目工作表"})
+		return
+	}
+
+	// 项目
+	byName := map[string]*importProject{}
+	byID := map[uint]*importProject{}
+	if projectSheet != "" {
+		var rows []model.Project
+		var nums []int
+		var errs []tools.RowError
+		if nums, errs, err = tools.ReadExcelTable(f, projectSheet, &rows); err != nil {
+			return
+		}
+		rowErrs = append(rowErrs, errs...)
+		for i, p := range rows {
+			item := &importProject{importItem: importItem{Sheet: projectSheet, Row: nums[i], Action: "create", Name: p.Name}, row: p}
+			fail := func(msg string) {
+				rowErrs = append(rowErrs, tools.RowError{Sheet: projectSheet, Row: nums[i], Message: msg})
+			}
+			if p.ID != 0 {
+				if projectByID[p.ID] == nil {
+					fail(fmt.Sprintf("项目 %d 不属于该活动", p.ID))
+				} else if byID[p.ID] != nil {
+					fail(fmt.Sprintf("项目 %d 重复", p.ID))
+				}
+				item.Action, item.importItem.ID = "update", p.ID
+				byID[p.ID] = item
+			}
+			if byName[p.Name] != nil {
+				fail(fmt.Sprintf("项目名称 %q 重复", p.Name))
+			}
+			byName[p.Name] = item
+			for _, msg := range validateProject(&p, activity) {
+				fail(msg)
+			}
+			projects = append(projects, item)
+		}
+	}
+
+	// 栏目
+	seenColumns := map[uint]bool{}
+	for _, sheet := range columnSheets {
+		ref := columnSheetPattern.FindStringSubmatch(sheet)[1]
+		var owner *importProject
+		var project *model.Project
+		if m := projectRefPattern.FindStringSubmatch(ref); m != nil {
+			pid, _ := strconv.ParseUint(m[1], 10, 64)
+			if owner = byID[uint(pid)]; owner != nil {
+				project = &owner.row
+			} else {
+				project = projectByID[uint(pid)]
+			}
+		} else if owner = byName[ref]; owner != nil {
+			project = &owner.row
+		}
+		if project == nil {
+			rowErrs = append(rowErrs, tools.RowError{Sheet: sheet, Message: fmt.Sprintf("找不到项目 %q", ref)})
+			continue
+		}
+
+		var rows []model.Column
+		var nums []int
+		var errs []tools.RowError
+		if nums, errs, err = tools.ReadExcelTable(f, sheet, &rows); err != nil {
+			return
+		}
+		rowErrs = append(rowErrs, errs...)
+		for i, col := range rows {
+			col.ProjectID = project.ID
+			item := &importColumn{importItem: importItem{Sheet: sheet, Row: nums[i], Action: "create", Name: col.Name}, row: col, project: owner}
+			fail := func(msg string) {
+				rowErrs = append(rowErrs, tools.RowError{Sheet: sheet, Row: nums[i], Message: msg})
+			}
+			if col.ID != 0 {
+				if !columnExists[col.ID] {
+					fail(fmt.Sprintf("栏目 %d 不属于该活动", col.ID))
+				} else if seenColumns[col.ID] {
+					fail(fmt.Sprintf("栏目 %d 重复", col.ID))
+				}
+				seenColumns[col.ID] = true
+				item.Action, item.importItem.ID = "update", col.ID
+			}
+			for _, msg := range validateColumn(&col, project) {
+				fail(msg)
+			}
+			columns = append(columns, item)
+		}
+	}
+	return
+}
+
+// validateProject 与创建项目时的校验一致
+func validateProject(p *model.Project, activity *model.Activity) (msgs []string) {
+	p.Audience.Normalize()
+	if p.Name == "" {
+		msgs = append(msgs, "项目名称不能为空")
+	} else if utf8.RuneCountInString(p.Name) > 75 {
+		msgs = append(msgs, "项目名称不能超过 75 个字符")
+	}
+	if utf8.RuneCountInString(p.Description) > 200 {
+		msgs = append(msgs, "项目描述不能超过 200 个字符")
+	}
+	if !validDate(p.StartDate) || !validDate(p.EndDate) {
+		return append(msgs, "项目开始、结束时间格式应为 20060102")
+	}
+	if p.StartDate < activity.StartDate || p.EndDate > activity.EndDate {
+		msgs = append(msgs, "项目的开始和结束时间必须在活动时间范围内")
+	}
+	if p.StartDate >= p.EndDate {
+		msgs = append(msgs, "项目开始时间必须早于结束时间")
+	}
+	return
+}
+
+// validateColumn 与创建栏目时的校验一致
+func validateColumn(col *model.Column, project *model.Project) (msgs []string) {
+	if col.Name == "" {
+		msgs = append(msgs, "栏目名称不能为空")
+	} else if utf8.RuneCountInString(col.Name) > 75 {
+		msgs = append(msgs, "栏目名称不能超过 75 个字符")
+	}
+	if utf8.RuneCountInString(col.Description) > 200 {
+		msgs = append(msgs, "栏目描述不能超过 200 个字符")
+	}
+	if !validDate(col.StartDate) || !validDate(col.EndDate) {
+		msgs = append(msgs, "栏目开始、结束时间格式应为 20060102")
+	} else {
+		if col.StartDate < project.StartDate || col.EndDate > project.EndDate {
+			msgs = append(msgs, "栏目的开始和结束时间必须在项目时间范围内")
+		}
+		if col.StartDate > col.EndDate {
+			msgs = append(msgs, "栏目开始日期不能晚于结束日期")
+		}
+	}
+	if col.StartTime != "" && col.EndTime != "" {
+		startTime, err1 := time.Parse("15:04", col.StartTime)
+		endTime, err2 := time.Parse("15:04", col.EndTime)
+		if err1 != nil || err2 != nil {
+			msgs = append(msgs, "每日打卡时间格式错误，应为 HH:MM")
+		} else if col.StartDate == col.EndDate && !startTime.Before(endTime) {
+			msgs = append(msgs, "同一天时每日打卡开始时间必须早于结束时间")
+		}
+	} else if col.StartTime != "" || col.EndTime != "" {
+		msgs = append(msgs, "每日打卡开始时间和结束时间必须同时设置或同时留空")
+	}
+	if col.PointEarned <= 0 {
+		msgs = append(msgs, "积分必须大于0")
+	}
+	if col.DailyPunchLimit < 0 {
+		msgs = append(msgs, "每日可打卡次数不能为负数")
+	}
+	if col.MinWordLimit != nil && col.MaxWordLimit != nil && *col.MinWordLimit > *col.MaxWordLimit {
+		msgs = append(msgs, "最小字数限制不能大于最大字数限制")
+	}
+	return
+}
+
+// validDate 校验 20060102 格式的日期
+func validDate(date int64) bool {
+	_, err := parseDate(date)
+	return date > 0 && err == nil
+}
+
+func projectItems(projects []*importProject) []importItem {
+	items := make([]importItem, 0, len(projects))
+	for _, p := range projects {
+		items = append(items, p.importItem)
+	}
+	return items
+}
+
+func columnItems(columns []*importColumn) []importItem {
+	items := make([]importItem, 0, len(columns))
+	for _, c := range columns {
+		items = append(items, c.importItem)
+	}
+	return items
+}
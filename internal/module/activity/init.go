@@ -2,7 +2,9 @@ package activity
 
 import (
 	"activity-punch-system/internal/global/logger"
+	"activity-punch-system/internal/global/schedule"
 	"log/slog"
+	"time"
 )
 
 var log *slog.Logger
@@ -15,6 +17,9 @@ func (p *ModuleActivity) GetName() string {
 
 func (u *ModuleActivity) Init() {
 	log = logger.New("Activity")
+	backfillMembers()
+	backfillParticipants()
+	schedule.Every("activity:publish", time.Minute, publishScheduled)
 }
 
 func selfInit() {
@@ -0,0 +1,100 @@
+package activity
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// statusTransitions 活动状态允许的转换，定时发布可以重新设置发布时间
+var statusTransitions = map[int][]int{
+	model.ActivityDraft:     {model.ActivityScheduled, model.ActivityPublished},
+	model.ActivityScheduled: {model.ActivityDraft, model.ActivityScheduled, model.ActivityPublished},
+	model.ActivityPublished: {model.ActivityArchived},
+	model.ActivityArchived:  {model.ActivityPublished},
+}
+
+var errStatusTransition = errors.New("不支持的活动状态转换")
+
+// ActivityStatusReq 定义切换活动状态请求的结构体
+type ActivityStatusReq struct {
+	Status    *int   `json:"status" binding:"required,oneof=0 1 2 3"` // 目标状态：0 已发布 1 草稿 2 定时发布 3 已归档
+	PublishAt *int64 `json:"publish_at"`                              // 定时发布时间（Unix 秒），目标状态为定时发布时必填
+}
+
+// UpdateActivityStatus 切换活动状态（发布、定时发布、撤回为草稿、归档与取消归档），需活动管理员及以上
+func UpdateActivityStatus(c *gin.Context) {
+	activity, user, ok := loadMemberActivity(c, model.MemberManager, "修改该活动的状态")
+	if !ok {
+		return
+	}
+	var req ActivityStatusReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
+		return
+	}
+
+	var publishAt *time.Time
+	if *req.Status == model.ActivityScheduled {
+		if req.PublishAt == nil {
+			response.Fail(c, response.ErrInvalidRequest.WithTips("定时发布需要设置发布时间"))
+			return
+		}
+		t := time.Unix(*req.PublishAt, 0)
+		if !t.After(time.Now()) {
+			response.Fail(c, response.ErrInvalidRequest.WithTips("发布时间需晚于当前时间"))
+			return
+		}
+		publishAt = &t
+	}
+
+	from := activity.Status
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(activity, activity.ID).Error; err != nil {
+			return err
+		}
+		from = activity.Status
+		if !slices.Contains(statusTransitions[from], *req.Status) {
+			return errStatusTransition
+		}
+		if err := tx.Model(activity).Updates(map[string]any{"status": *req.Status, "publish_at": publishAt}).Error; err != nil {
+			return err
+		}
+		activity.Status, activity.PublishAt = *req.Status, publishAt
+		return nil
+	})
+	switch {
+	case errors.Is(err, errStatusTransition):
+		response.Fail(c, response.ErrInvalidRequest.WithTips(err.Error()))
+	case err != nil:
+		log.Error("修改活动状态失败", "error", err, "id", activity.ID, "status", *req.Status)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+	default:
+		log.Info("修改活动状态", "id", activity.ID, "from", from, "to", *req.Status, "operator", user.StudentID)
+		response.Success(c, gin.H{
+			"status":     activity.Status,
+			"publish_at": activity.PublishAt,
+		})
+	}
+}
+
+// publishScheduled 将到达发布时间的定时发布活动转为已发布
+func publishScheduled() {
+	r := database.DB.Model(&model.Activity{}).
+		Where("status = ? AND publish_at <= ?", model.ActivityScheduled, time.Now()).
+		Update("status", model.ActivityPublished)
+	if r.Error != nil {
+		log.Error("定时发布活动失败", "error", r.Error)
+		return
+	}
+	if r.RowsAffected > 0 {
+		log.Info("定时发布活动", "count", r.RowsAffected)
+	}
+}
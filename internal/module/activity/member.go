@@ -0,0 +1,301 @@
+package activity
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/permission"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errOwnerMember = errors.New("不能修改或移除活动所有者，请使用转让")
+	errSelfMember  = errors.New("不能转让给自己")
+)
+
+// MemberInviteReq 定义邀请活动成员请求的结构体
+type MemberInviteReq struct {
+	StudentID string `json:"student_id" binding:"required"`     // 被邀请人学号
+	Role      int    `json:"role" binding:"required,oneof=1 2"` // 角色：1 审核员 2 管理员
+}
+
+// MemberRoleReq 定义修改成员角色请求的结构体
+type MemberRoleReq struct {
+	Role int `json:"role" binding:"required,oneof=1 2"` // 角色：1 审核员 2 管理员
+}
+
+// TransferReq 定义转让活动所有权请求的结构体
+type TransferReq struct {
+	StudentID string `json:"student_id" binding:"required"` // 新所有者学号
+}
+
+// memberInfo 成员列表中的一行
+type memberInfo struct {
+	StudentID string    `gorm:"column:student_id" json:"student_id"`
+	Name      string    `gorm:"column:name" json:"name"`
+	NickName  string    `gorm:"column:nick_name" json:"nick_name"`
+	Role      int       `gorm:"column:role" json:"role"`
+	InvitedBy string    `gorm:"column:invited_by" json:"invited_by"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+// loadMemberActivity 查询路径参数中的活动并校验请求者至少拥有 minRole 角色
+func loadMemberActivity(c *gin.Context, minRole int, action string) (*model.Activity, *jwt.Claims, bool) {
+	return loadActivityWithRole(c, minRole, action, permission.RequireRole)
+}
+
+// loadWritableMemberActivity 同 loadMemberActivity，并要求活动未归档
+func loadWritableMemberActivity(c *gin.Context, minRole int, action string) (*model.Activity, *jwt.Claims, bool) {
+	return loadActivityWithRole(c, minRole, action, permission.RequireWritableRole)
+}
+
+func loadActivityWithRole(c *gin.Context, minRole int, action string,
+	require func(c *gin.Context, activityID uint, studentID string, minRole int, action string) bool) (*model.Activity, *jwt.Claims, bool) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return nil, nil, false
+	}
+	id := c.Param("id")
+	var activity model.Activity
+	if err := database.DB.First(&activity, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrNotFound.WithTips("活动不存在"))
+			return nil, nil, false
+		}
+		log.Error("查询活动失败", "error", err, "id", id)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return nil, nil, false
+	}
+	if !require(c, activity.ID, user.StudentID, minRole, action) {
+		return nil, nil, false
+	}
+	return &activity, user, true
+}
+
+// ListMembers 查询活动的管理成员，活动成员均可查看
+func ListMembers(c *gin.Context) {
+	activity, _, ok := loadMemberActivity(c, model.MemberReviewer, "查看该活动的成员")
+	if !ok {
+		return
+	}
+	var list []memberInfo
+	if err := database.DB.Table("activity_member m").
+		Select("m.student_id, u.name, u.nick_name, m.role, m.invited_by, m.created_at").
+		Joins("LEFT JOIN user u ON u.student_id = m.student_id").
+		Where("m.activity_id = ?", activity.ID).
+		Order("m.role DESC, m.id ASC").
+		Scan(&list).Error; err != nil {
+		log.Error("查询活动成员失败", "error", err, "id", activity.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	response.Success(c, list)
+}
+
+// InviteMember 邀请用户成为活动的审核员或管理员，需活动管理员及以上
+func InviteMember(c *gin.Context) {
+	activity, user, ok := loadMemberActivity(c, model.MemberManager, "管理该活动的成员")
+	if !ok {
+		return
+	}
+	var req MemberInviteReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
+		return
+	}
+
+	var invitee model.User
+	if err := database.DB.Select("id").First(&invitee, "student_id = ?", req.StudentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrNotFound.WithTips("被邀请的用户不存在"))
+			return
+		}
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+
+	member := model.ActivityMember{
+		ActivityID: activity.ID,
+		StudentID:  req.StudentID,
+		Role:       req.Role,
+		InvitedBy:  user.StudentID,
+	}
+	r := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&member)
+	if r.Error != nil {
+		log.Error("邀请活动成员失败", "error", r.Error, "id", activity.ID, "student_id", req.StudentID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(r.Error))
+		return
+	}
+	if r.RowsAffected == 0 {
+		response.Fail(c, response.ErrAlreadyExists.WithTips("该用户已是活动成员"))
+		return
+	}
+	log.Info("邀请活动成员", "id", activity.ID, "student_id", req.StudentID, "role", req.Role, "invited_by", user.StudentID)
+	response.Success(c, member)
+}
+
+// UpdateMemberRole 修改成员角色，需活动管理员及以上，所有者只能通过转让变更
+func UpdateMemberRole(c *gin.Context) {
+	activity, user, ok := loadMemberActivity(c, model.MemberManager, "管理该活动的成员")
+	if !ok {
+		return
+	}
+	var req MemberRoleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
+		return
+	}
+	studentID := c.Param("student_id")
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var member model.ActivityMember
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&member, "activity_id = ? AND student_id = ?", activity.ID, studentID).Error; err != nil {
+			return err
+		}
+		if member.Role == model.MemberOwner {
+			return errOwnerMember
+		}
+		return tx.Model(&member).Update("role", req.Role).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.Fail(c, response.ErrNotFound.WithTips("该用户不是活动成员"))
+	case errors.Is(err, errOwnerMember):
+		response.Fail(c, response.ErrForbidden.WithTips(err.Error()))
+	case err != nil:
+		log.Error("修改成员角色失败", "error", err, "id", activity.ID, "student_id", studentID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+	default:
+		log.Info("修改成员角色", "id", activity.ID, "student_id", studentID, "role", req.Role, "operator", user.StudentID)
+		response.Success(c)
+	}
+}
+
+// RemoveMember 移除活动成员，需活动管理员及以上，成员也可以移除自己，所有者不能被移除
+func RemoveMember(c *gin.Context) {
+	studentID := c.Param("student_id")
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	minRole := model.MemberManager
+	if studentID == user.StudentID {
+		minRole = model.MemberReviewer
+	}
+	activity, _, ok := loadMemberActivity(c, minRole, "管理该活动的成员")
+	if !ok {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var member model.ActivityMember
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&member, "activity_id = ? AND student_id = ?", activity.ID, studentID).Error; err != nil {
+			return err
+		}
+		if member.Role == model.MemberOwner {
+			return errOwnerMember
+		}
+		return tx.Delete(&member).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.Fail(c, response.ErrNotFound.WithTips("该用户不是活动成员"))
+	case errors.Is(err, errOwnerMember):
+		response.Fail(c, response.ErrForbidden.WithTips(err.Error()))
+	case err != nil:
+		log.Error("移除活动成员失败", "error", err, "id", activity.ID, "student_id", studentID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+	default:
+		log.Info("移除活动成员", "id", activity.ID, "student_id", studentID, "operator", user.StudentID)
+		response.Success(c)
+	}
+}
+
+// TransferActivity 将活动所有权转让给其他用户，仅所有者可操作，原所有者转为管理员
+func TransferActivity(c *gin.Context) {
+	activity, user, ok := loadMemberActivity(c, model.MemberOwner, "转让该活动")
+	if !ok {
+		return
+	}
+	var req TransferReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
+		return
+	}
+
+	var target model.User
+	if err := database.DB.Select("id").First(&target, "student_id = ?", req.StudentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrNotFound.WithTips("新所有者不存在"))
+			return
+		}
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定活动行，避免并发转让
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(activity, activity.ID).Error; err != nil {
+			return err
+		}
+		if activity.OwnerID == req.StudentID {
+			return errSelfMember
+		}
+		if err := tx.Model(&model.ActivityMember{}).
+			Where("activity_id = ? AND role = ?", activity.ID, model.MemberOwner).
+			Update("role", model.MemberManager).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			DoUpdates: clause.Assignments(map[string]any{"role": model.MemberOwner, "updated_at": time.Now()}),
+		}).Create(&model.ActivityMember{
+			ActivityID: activity.ID,
+			StudentID:  req.StudentID,
+			Role:       model.MemberOwner,
+			InvitedBy:  user.StudentID,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(activity).Update("owner_id", req.StudentID).Error
+	})
+	switch {
+	case errors.Is(err, errSelfMember):
+		response.Fail(c, response.ErrInvalidRequest.WithTips(err.Error()))
+	case err != nil:
+		log.Error("转让活动失败", "error", err, "id", activity.ID, "student_id", req.StudentID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+	default:
+		log.Info("转让活动", "id", activity.ID, "from", user.StudentID, "to", req.StudentID)
+		response.Success(c)
+	}
+}
+
+// backfillMembers 成员角色上线前的活动以 OwnerID 作为所有者成员，仅在成员表为空时执行一次
+func backfillMembers() {
+	var count int64
+	if err := database.DB.Model(&model.ActivityMember{}).Limit(1).Count(&count).Error; err != nil {
+		log.Error("查询活动成员失败", "error", err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+	r := database.DB.Exec(`
+		INSERT IGNORE INTO activity_member (activity_id, student_id, role, invited_by, created_at, updated_at)
+		SELECT id, owner_id, ?, '', NOW(), NOW() FROM activity`, model.MemberOwner)
+	if r.Error != nil {
+		log.Error("回填活动成员失败", "error", r.Error)
+		return
+	}
+	log.Info("回填活动成员完成", "count", r.RowsAffected)
+}
@@ -17,21 +17,50 @@ func (p *ModuleActivity) InitRouter(r *gin.RouterGroup) {
 
 		// 注册获取单个项目端点
 		activityGroup.GET("/get/:id", GetActivity)
-	}
 
-	adminGroup.Use(middleware.Auth(1))
-	{
-		// 注册创建项目端点
-		adminGroup.POST("/create", CreateActivity)
+		// 加入、退出活动及查询本人参与状态
+		activityGroup.POST("/join/:id", JoinActivity)
+		activityGroup.POST("/leave/:id", LeaveActivity)
+		activityGroup.GET("/enrollment/:id", MyEnrollment)
 
+		// 以下端点由活动成员角色校验权限，普通用户被邀请为成员后同样可以管理
 		// 注册更新项目端点
-		adminGroup.PUT("/update/:id", UpdateActivity)
+		activityGroup.PUT("/update/:id", UpdateActivity)
 
 		// 注册删除项目端点
-		adminGroup.DELETE("/delete/:id", DeleteActivity)
+		activityGroup.DELETE("/delete/:id", DeleteActivity)
 
 		// 还原删除项目端点
-		adminGroup.PUT("/restore/:id", RestoreActivity)
-		adminGroup.GET("/mine", MineActivities)
+		activityGroup.PUT("/restore/:id", RestoreActivity)
+		activityGroup.GET("/mine", MineActivities)
+
+		// 从 Excel 批量导入活动下的项目与栏目
+		activityGroup.POST("/import/:id", ImportActivity)
+
+		// 参与者管理
+		activityGroup.GET("/participants/:id", ListParticipants)
+		activityGroup.PUT("/participants/:id/approve", ApproveParticipants)
+		activityGroup.PUT("/participants/:id/reject", RejectParticipants)
+		activityGroup.DELETE("/participants/:id/:user_id", RemoveParticipant)
+		activityGroup.GET("/participants/:id/export", ExportParticipants)
+
+		// 活动成员（所有者、管理员、审核员）管理
+		activityGroup.GET("/members/:id", ListMembers)
+		activityGroup.POST("/members/:id", InviteMember)
+		activityGroup.PUT("/members/:id/:student_id", UpdateMemberRole)
+		activityGroup.DELETE("/members/:id/:student_id", RemoveMember)
+		activityGroup.PUT("/transfer/:id", TransferActivity)
+
+		// 切换活动状态：发布、定时发布、撤回为草稿、归档
+		activityGroup.PUT("/status/:id", UpdateActivityStatus)
+	}
+
+	adminGroup.Use(middleware.Auth(1))
+	{
+		// 注册创建项目端点
+		adminGroup.POST("/create", CreateActivity)
+
+		// 以已有活动为模板克隆活动
+		adminGroup.POST("/clone/:id", CloneActivity)
 	}
 }
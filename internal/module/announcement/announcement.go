@@ -0,0 +1,379 @@
+package announcement
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/permission"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"activity-punch-system/tools"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AnnouncementCreateReq 定义发布公告请求的结构体
+type AnnouncementCreateReq struct {
+	ActivityID uint   `json:"activity_id" binding:"required"`   // 所属活动ID
+	ProjectID  uint   `json:"project_id"`                       // 所属项目ID，可选，为空表示面向整个活动
+	Title      string `json:"title" binding:"required,max=100"` // 标题
+	Body       string `json:"body" binding:"required,max=5000"` // 正文
+	Pinned     bool   `json:"pinned"`                           // 是否置顶，可选
+	PublishAt  *int64 `json:"publish_at"`                       // 发布时间（Unix 秒），可选，为空表示立即发布
+	ExpiresAt  *int64 `json:"expires_at"`                       // 过期时间（Unix 秒），可选，为空表示不过期
+}
+
+// AnnouncementUpdateReq 定义更新公告请求的结构体，使用指针类型支持部分更新
+type AnnouncementUpdateReq struct {
+	Title     *string `json:"title" binding:"omitempty,max=100"` // 标题，可选
+	Body      *string `json:"body" binding:"omitempty,max=5000"` // 正文，可选
+	Pinned    *bool   `json:"pinned"`                            // 是否置顶，可选
+	PublishAt *int64  `json:"publish_at"`                        // 发布时间（Unix 秒），可选
+	ExpiresAt *int64  `json:"expires_at"`                        // 过期时间（Unix 秒），可选，0 表示取消过期时间
+}
+
+// announcementItem 公告列表中的一条，附带本人是否已读
+type announcementItem struct {
+	model.Announcement
+	Read bool `gorm:"column:is_read" json:"read"`
+}
+
+// CreateAnnouncement 发布公告，需活动管理员及以上
+func CreateAnnouncement(c *gin.Context) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	var req AnnouncementCreateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Error("绑定发布公告请求失败", "error", err)
+		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
+		return
+	}
+	if !permission.RequireWritableRole(c, req.ActivityID, user.StudentID, model.MemberManager, "管理该活动的公告") {
+		return
+	}
+	if req.ProjectID != 0 {
+		var count int64
+		if err := database.DB.Model(&model.Project{}).
+			Where("id = ? AND activity_id = ?", req.ProjectID, req.ActivityID).
+			Count(&count).Error; err != nil {
+			response.Fail(c, response.ErrDatabase.WithOrigin(err))
+			return
+		}
+		if count == 0 {
+			response.Fail(c, response.ErrNotFound.WithTips("项目不存在或不属于该活动"))
+			return
+		}
+	}
+
+	announcement := model.Announcement{
+		ActivityID: req.ActivityID,
+		ProjectID:  req.ProjectID,
+		Title:      req.Title,
+		Body:       req.Body,
+		Pinned:     req.Pinned,
+		PublishAt:  time.Now(),
+		AuthorID:   user.StudentID,
+	}
+	if req.PublishAt != nil {
+		announcement.PublishAt = time.Unix(*req.PublishAt, 0)
+	}
+	if req.ExpiresAt != nil {
+		t := time.Unix(*req.ExpiresAt, 0)
+		announcement.ExpiresAt = &t
+	}
+	if announcement.ExpiresAt != nil && !announcement.ExpiresAt.After(announcement.PublishAt) {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("过期时间必须晚于发布时间"))
+		return
+	}
+
+	if err := database.DB.Create(&announcement).Error; err != nil {
+		log.Error("发布公告失败", "error", err, "activity_id", req.ActivityID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	log.Info("发布公告", "id", announcement.ID, "activity_id", announcement.ActivityID, "author_id", user.StudentID)
+	response.Success(c, announcement)
+}
+
+// UpdateAnnouncement 更新公告，需活动管理员及以上
+func UpdateAnnouncement(c *gin.Context) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	var req AnnouncementUpdateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Error("绑定更新公告请求失败", "error", err)
+		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
+		return
+	}
+	announcement, ok := loadAnnouncement(c)
+	if !ok || !permission.RequireWritableRole(c, announcement.ActivityID, user.StudentID, model.MemberManager, "管理该活动的公告") {
+		return
+	}
+
+	if req.Title != nil {
+		announcement.Title = *req.Title
+	}
+	if req.Body != nil {
+		announcement.Body = *req.Body
+	}
+	if req.Pinned != nil {
+		announcement.Pinned = *req.Pinned
+	}
+	if req.PublishAt != nil {
+		announcement.PublishAt = time.Unix(*req.PublishAt, 0)
+	}
+	if req.ExpiresAt != nil {
+		if *req.ExpiresAt == 0 {
+			announcement.ExpiresAt = nil
+		} else {
+			t := time.Unix(*req.ExpiresAt, 0)
+			announcement.ExpiresAt = &t
+		}
+	}
+	if announcement.ExpiresAt != nil && !announcement.ExpiresAt.After(announcement.PublishAt) {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("过期时间必须晚于发布时间"))
+		return
+	}
+
+	if err := database.DB.Save(announcement).Error; err != nil {
+		log.Error("更新公告失败", "error", err, "id", announcement.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	log.Info("更新公告", "id", announcement.ID, "operator", user.StudentID)
+	response.Success(c, announcement)
+}
+
+// DeleteAnnouncement 删除公告，需活动管理员及以上
+func DeleteAnnouncement(c *gin.Context) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	announcement, ok := loadAnnouncement(c)
+	if !ok || !permission.RequireWritableRole(c, announcement.ActivityID, user.StudentID, model.MemberManager, "管理该活动的公告") {
+		return
+	}
+	if err := database.DB.Delete(announcement).Error; err != nil {
+		log.Error("删除公告失败", "error", err, "id", announcement.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	log.Info("删除公告", "id", announcement.ID, "operator", user.StudentID)
+	response.Success(c)
+}
+
+// ListAnnouncements 查询活动的公告，置顶在前，其余按发布时间倒序；
+// 可按 project_id 筛选项目公告，管理员传 all=true 时同时返回未到发布时间与已过期的公告
+func ListAnnouncements(c *gin.Context) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	activityID, err := strconv.ParseUint(c.Query("activity_id"), 10, 64)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("活动ID错误"))
+		return
+	}
+	role, ok := requireVisible(c, uint(activityID), user)
+	if !ok {
+		return
+	}
+
+	query := database.DB.Model(&model.Announcement{}).Where("announcement.activity_id = ?", activityID)
+	if projectID := c.Query("project_id"); projectID != "" {
+		query = query.Where("announcement.project_id = ?", projectID)
+	}
+	if c.Query("all") != "true" || role < model.MemberManager {
+		query = query.Scopes(model.AnnouncementActiveScope(time.Now()))
+	}
+	if role == 0 {
+		viewer, err := model.AudienceViewer(database.DB, user.ID, user.RoleID)
+		if err != nil {
+			log.Error("查询用户信息失败", "error", err, "user_id", user.ID)
+			response.Fail(c, response.ErrDatabase.WithOrigin(err))
+			return
+		}
+		query = query.Scopes(model.AnnouncementAudienceScope(viewer))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Error("查询公告总数失败", "error", err, "activity_id", activityID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	offset, limit := tools.GetPage(c)
+	var list []announcementItem
+	if err := query.Select("announcement.*, r.id IS NOT NULL AS is_read").
+		Joins("LEFT JOIN announcement_read r ON r.announcement_id = announcement.id AND r.user_id = ?", user.ID).
+		Order("announcement.pinned DESC, announcement.publish_at DESC").
+		Offset(offset).Limit(limit).
+		Scan(&list).Error; err != nil {
+		log.Error("查询公告失败", "error", err, "activity_id", activityID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	response.Success(c, gin.H{
+		"total": total,
+		"list":  list,
+	})
+}
+
+// GetAnnouncement 查询公告详情，并标记为已读
+func GetAnnouncement(c *gin.Context) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	announcement, ok := loadAnnouncement(c)
+	if !ok {
+		return
+	}
+	role, ok := requireVisible(c, announcement.ActivityID, user)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	active := !announcement.PublishAt.After(now) && (announcement.ExpiresAt == nil || announcement.ExpiresAt.After(now))
+	if !active && role < model.MemberManager {
+		response.Fail(c, response.ErrNotFound.WithTips("公告不存在"))
+		return
+	}
+	if role == 0 && announcement.ProjectID != 0 {
+		viewer, err := model.AudienceViewer(database.DB, user.ID, user.RoleID)
+		if err != nil {
+			log.Error("查询用户信息失败", "error", err, "user_id", user.ID)
+			response.Fail(c, response.ErrDatabase.WithOrigin(err))
+			return
+		}
+		var visible int64
+		if err := database.DB.Model(&model.Announcement{}).Where("announcement.id = ?", announcement.ID).
+			Scopes(model.AnnouncementAudienceScope(viewer)).Count(&visible).Error; err != nil {
+			log.Error("查询公告失败", "error", err, "id", announcement.ID)
+			response.Fail(c, response.ErrDatabase.WithOrigin(err))
+			return
+		}
+		if visible == 0 {
+			response.Fail(c, response.ErrNotFound.WithTips("公告不存在"))
+			return
+		}
+	}
+
+	if active {
+		if err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.AnnouncementRead{
+			AnnouncementID: announcement.ID,
+			UserID:         user.ID,
+			ReadAt:         now,
+		}).Error; err != nil {
+			log.Error("标记公告已读失败", "error", err, "id", announcement.ID, "user_id", user.ID)
+			response.Fail(c, response.ErrDatabase.WithOrigin(err))
+			return
+		}
+	}
+	response.Success(c, announcementItem{Announcement: *announcement, Read: active})
+}
+
+// ReadAll 将活动（不传 activity_id 时为本人参与或管理的全部活动）中发布期内的公告全部标记为已读
+func ReadAll(c *gin.Context) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	query, ok := unreadQuery(c, user)
+	if !ok {
+		return
+	}
+	r := database.DB.Exec(`
+		INSERT IGNORE INTO announcement_read (announcement_id, user_id, read_at)
+		SELECT id, ?, ? FROM (?) AS unread`, user.ID, time.Now(), query.Select("announcement.id"))
+	if r.Error != nil {
+		log.Error("标记公告已读失败", "error", r.Error, "user_id", user.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(r.Error))
+		return
+	}
+	response.Success(c, gin.H{"count": r.RowsAffected})
+}
+
+// UnreadCount 查询未读公告数，传 activity_id 时只统计该活动，否则统计本人参与或管理的全部活动
+func UnreadCount(c *gin.Context) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	query, ok := unreadQuery(c, user)
+	if !ok {
+		return
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		log.Error("查询未读公告数失败", "error", err, "user_id", user.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	response.Success(c, gin.H{"count": count})
+}
+
+// unreadQuery 构造用户发布期内未读公告的查询，不满足所属项目面向人群规则的项目公告不计入
+func unreadQuery(c *gin.Context, user *jwt.Claims) (*gorm.DB, bool) {
+	viewer, err := model.AudienceViewer(database.DB, user.ID, user.RoleID)
+	if err != nil {
+		log.Error("查询用户信息失败", "error", err, "user_id", user.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return nil, false
+	}
+	query := database.DB.Model(&model.Announcement{}).
+		Joins("JOIN activity ON activity.id = announcement.activity_id AND activity.deleted_at IS NULL").
+		Scopes(model.AnnouncementActiveScope(time.Now()), model.ActivityStatusScope(user.StudentID),
+			model.AnnouncementAudienceScope(viewer)).
+		Where("NOT EXISTS (SELECT 1 FROM announcement_read r WHERE r.announcement_id = announcement.id AND r.user_id = ?)", user.ID)
+
+	if activityIDStr := c.Query("activity_id"); activityIDStr != "" {
+		activityID, err := strconv.ParseUint(activityIDStr, 10, 64)
+		if err != nil {
+			response.Fail(c, response.ErrInvalidRequest.WithTips("活动ID错误"))
+			return nil, false
+		}
+		if _, ok := requireVisible(c, uint(activityID), user); !ok {
+			return nil, false
+		}
+		return query.Where("announcement.activity_id = ?", activityID), true
+	}
+
+	joined := database.DB.Model(&model.Participant{}).Select("activity_id").
+		Where("user_id = ? AND status = ?", user.ID, model.ParticipantJoined)
+	managed := database.DB.Model(&model.ActivityMember{}).Select("activity_id").
+		Where("student_id = ?", user.StudentID)
+	return query.Where("(announcement.activity_id IN (?) OR announcement.activity_id IN (?))", joined, managed), true
+}
+
+// loadAnnouncement 查询路径参数中的公告
+func loadAnnouncement(c *gin.Context) (*model.Announcement, bool) {
+	id := c.Param("id")
+	var announcement model.Announcement
+	if err := database.DB.First(&announcement, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrNotFound.WithTips("公告不存在"))
+			return nil, false
+		}
+		log.Error("查询公告失败", "error", err, "id", id)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return nil, false
+	}
+	return &announcement, true
+}
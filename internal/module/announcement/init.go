@@ -0,0 +1,18 @@
+package announcement
+
+import (
+	"activity-punch-system/internal/global/logger"
+	"log/slog"
+)
+
+var log *slog.Logger
+
+type ModuleAnnouncement struct{}
+
+func (*ModuleAnnouncement) GetName() string {
+	return "Announcement"
+}
+
+func (*ModuleAnnouncement) Init() {
+	log = logger.New("Announcement")
+}
@@ -0,0 +1,50 @@
+package announcement
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// requireVisible 校验用户可以查看活动：活动成员，或活动已发布（含已归档）且满足面向人群规则，返回用户在活动中的角色
+func requireVisible(c *gin.Context, activityID uint, user *jwt.Claims) (int, bool) {
+	var activity model.Activity
+	if err := database.DB.First(&activity, "id = ?", activityID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrNotFound.WithTips("活动不存在"))
+			return 0, false
+		}
+		log.Error("查询活动失败", "error", err, "activity_id", activityID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return 0, false
+	}
+	role, err := model.MemberRole(database.DB, activity.ID, user.StudentID)
+	if err != nil {
+		log.Error("查询活动成员失败", "error", err, "activity_id", activityID, "student_id", user.StudentID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return 0, false
+	}
+	if role > 0 {
+		return role, true
+	}
+	if !activity.Visible() {
+		response.Fail(c, response.ErrNotFound.WithTips("活动不存在"))
+		return 0, false
+	}
+	viewer, err := model.AudienceViewer(database.DB, user.ID, user.RoleID)
+	if err != nil {
+		log.Error("查询用户信息失败", "error", err, "user_id", user.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return 0, false
+	}
+	if viewer != nil && !activity.Audience.Allows(viewer) {
+		response.Fail(c, response.ErrNotFound.WithTips("活动不存在"))
+		return 0, false
+	}
+	return 0, true
+}
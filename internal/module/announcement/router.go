@@ -0,0 +1,27 @@
+package announcement
+
+import (
+	"activity-punch-system/internal/global/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (*ModuleAnnouncement) InitRouter(r *gin.RouterGroup) {
+	// 定义公告模块的路由组，所有公告相关端点以 /announcement 为前缀
+	announcementGroup := r.Group("/announcement")
+	announcementGroup.Use(middleware.Auth(0))
+	{
+		// 查询活动公告列表、公告详情（查看即标记为已读）
+		announcementGroup.GET("/list", ListAnnouncements)
+		announcementGroup.GET("/get/:id", GetAnnouncement)
+
+		// 未读数与全部标记为已读
+		announcementGroup.GET("/unread-count", UnreadCount)
+		announcementGroup.POST("/read-all", ReadAll)
+
+		// 以下端点由活动成员角色（管理员及以上）校验权限
+		announcementGroup.POST("/create", CreateAnnouncement)
+		announcementGroup.PUT("/update/:id", UpdateAnnouncement)
+		announcementGroup.DELETE("/delete/:id", DeleteAnnouncement)
+	}
+}
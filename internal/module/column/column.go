@@ -3,6 +3,7 @@ package column
 import (
 	"activity-punch-system/internal/global/database"
 	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/permission"
 	"activity-punch-system/internal/global/response"
 	"activity-punch-system/internal/model"
 	"time"
@@ -11,15 +12,6 @@ import (
 	"gorm.io/gorm"
 )
 
-// 北京时区
-var beijingLocation = time.FixedZone("CST", 8*60*60)
-
-// getTodayStart 获取北京时间今日零点
-func getTodayStart() time.Time {
-	now := time.Now().In(beijingLocation)
-	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, beijingLocation)
-}
-
 type Column struct {
 	Name        string `json:"name" binding:"required,max=75"` // 栏目名称
 	Description string `json:"description" binding:"max=200"`  // 栏目描述
@@ -32,57 +24,60 @@ type Column struct {
 
 // ColumnCreateReq 定义创建栏目请求的结构体
 type ColumnCreateReq struct {
-	Name            string `json:"name" binding:"required,max=75"` // 栏目名称
-	Description     string `json:"description" binding:"max=200"`  // 栏目描述
-	ProjectID       uint   `json:"project_id" binding:"required"`  // 关联的项目ID
-	StartDate       int64  `json:"start_date" binding:"required"`  // 栏目开始日期
-	EndDate         int64  `json:"end_date" binding:"required"`    // 栏目结束日期
-	Avatar          string `json:"avatar"`                         // 栏目封面URL
-	DailyPunchLimit int    `json:"daily_punch_limit"`              // 每日可打卡次数，0表示不限次数
-	PointEarned     int    `json:"point_earned"`                   // 每次打卡可获得的积分
-	StartTime       string `json:"start_time"`                     // 每日打卡开始时间，格式为 "HH:MM"
-	EndTime         string `json:"end_time"`                       // 每日打卡结束时间，格式为 "HH:MM"
-	Optional        bool   `json:"optional"`                       // 特殊栏目，不计入完成所有栏目的判断
-	MinWordLimit    *uint  `json:"min_word_limit"`                 // 最小字数限制，可选，null表示不限制
-	MaxWordLimit    *uint  `json:"max_word_limit"`                 // 最大字数限制，可选，null表示不限制
+	Name            string                `json:"name" binding:"required,max=75"` // 栏目名称
+	Description     string                `json:"description" binding:"max=200"`  // 栏目描述
+	ProjectID       uint                  `json:"project_id" binding:"required"`  // 关联的项目ID
+	StartDate       int64                 `json:"start_date" binding:"required"`  // 栏目开始日期
+	EndDate         int64                 `json:"end_date" binding:"required"`    // 栏目结束日期
+	Avatar          string                `json:"avatar"`                         // 栏目封面URL
+	DailyPunchLimit int                   `json:"daily_punch_limit"`              // 每日可打卡次数，0表示不限次数
+	PointEarned     int                   `json:"point_earned"`                   // 每次打卡可获得的积分
+	StartTime       string                `json:"start_time"`                     // 每日打卡开始时间，格式为 "HH:MM"
+	EndTime         string                `json:"end_time"`                       // 每日打卡结束时间，格式为 "HH:MM"
+	Optional        bool                  `json:"optional"`                       // 特殊栏目，不计入完成所有栏目的判断
+	MinWordLimit    *uint                 `json:"min_word_limit"`                 // 最小字数限制，可选，null表示不限制
+	MaxWordLimit    *uint                 `json:"max_word_limit"`                 // 最大字数限制，可选，null表示不限制
+	Attachments     model.AttachmentRules `json:"attachments"`                    // 接受的附件类型及限制，为空时只接受最多 9 张图片
 }
 
 // ColumnUpdateReq 定义更新栏目请求的结构体，使用指针类型支持部分更新
 type ColumnUpdateReq struct {
-	Name            *string `json:"name" binding:"omitempty,max=75"`         // 栏目名称，可选
-	Description     *string `json:"description" binding:"omitempty,max=200"` // 栏目描述，可选
-	ProjectID       *uint   `json:"project_id"`                              // 关联的项目ID，可选
-	StartDate       *int64  `json:"start_date"`                              // 栏目开始日期，可选
-	EndDate         *int64  `json:"end_date"`                                // 栏目结束日期，可选
-	Avatar          *string `json:"avatar"`                                  // 栏目封面URL，可选
-	DailyPunchLimit *int    `json:"daily_punch_limit"`                       // 每日可打卡次数，0表示不限次数
-	PointEarned     *int    `json:"point_earned"`                            // 每次打卡可获得的积分
-	StartTime       *string `json:"start_time"`                              // 每日打卡开始时间，格式为 "HH:MM"
-	EndTime         *string `json:"end_time"`                                // 每日打卡结束时间，格式为 "HH:MM"
-	Optional        *bool   `json:"optional"`                                // 特殊栏目，不计入完成所有栏目的判断
-	MinWordLimit    *uint   `json:"min_word_limit"`                          // 最小字数限制，可选，null表示不限制
-	MaxWordLimit    *uint   `json:"max_word_limit"`                          // 最大字数限制，可选，null表示不限制
+	Name            *string                `json:"name" binding:"omitempty,max=75"`         // 栏目名称，可选
+	Description     *string                `json:"description" binding:"omitempty,max=200"` // 栏目描述，可选
+	ProjectID       *uint                  `json:"project_id"`                              // 关联的项目ID，可选
+	StartDate       *int64                 `json:"start_date"`                              // 栏目开始日期，可选
+	EndDate         *int64                 `json:"end_date"`                                // 栏目结束日期，可选
+	Avatar          *string                `json:"avatar"`                                  // 栏目封面URL，可选
+	DailyPunchLimit *int                   `json:"daily_punch_limit"`                       // 每日可打卡次数，0表示不限次数
+	PointEarned     *int                   `json:"point_earned"`                            // 每次打卡可获得的积分
+	StartTime       *string                `json:"start_time"`                              // 每日打卡开始时间，格式为 "HH:MM"
+	EndTime         *string                `json:"end_time"`                                // 每日打卡结束时间，格式为 "HH:MM"
+	Optional        *bool                  `json:"optional"`                                // 特殊栏目，不计入完成所有栏目的判断
+	MinWordLimit    *uint                  `json:"min_word_limit"`                          // 最小字数限制，可选，null表示不限制
+	MaxWordLimit    *uint                  `json:"max_word_limit"`                          // 最大字数限制，可选，null表示不限制
+	Attachments     *model.AttachmentRules `json:"attachments"`                             // 接受的附件类型及限制，可选，传空对象恢复为只接受图片
 }
 
 // ColumnResponse 定义栏目响应结构体（不包含空的Project字段）
 type ColumnResponse struct {
-	ID              uint   `json:"id"`
-	Name            string `json:"name"`
-	Description     string `json:"description"`
-	OwnerID         string `json:"owner_id"`
-	ProjectID       uint   `json:"project_id"`
-	StartDate       int64  `json:"start_date"`
-	EndDate         int64  `json:"end_date"`
-	Avatar          string `json:"avatar"`
-	DailyPunchLimit int    `json:"daily_punch_limit"`
-	PointEarned     uint   `json:"point_earned"`
-	StartTime       string `json:"start_time"`
-	EndTime         string `json:"end_time"`
-	Optional        bool   `json:"optional"`
-	MinWordLimit    *uint  `json:"min_word_limit"`
-	MaxWordLimit    *uint  `json:"max_word_limit"`
-	CreatedAt       int64  `json:"created_at"`
-	UpdatedAt       int64  `json:"updated_at"`
+	ID              uint                  `json:"id"`
+	Name            string                `json:"name"`
+	Description     string                `json:"description"`
+	OwnerID         string                `json:"owner_id"`
+	ProjectID       uint                  `json:"project_id"`
+	StartDate       int64                 `json:"start_date"`
+	EndDate         int64                 `json:"end_date"`
+	Avatar          string                `json:"avatar"`
+	DailyPunchLimit int                   `json:"daily_punch_limit"`
+	PointEarned     uint                  `json:"point_earned"`
+	StartTime       string                `json:"start_time"`
+	EndTime         string                `json:"end_time"`
+	Optional        bool                  `json:"optional"`
+	MinWordLimit    *uint                 `json:"min_word_limit"`
+	MaxWordLimit    *uint                 `json:"max_word_limit"`
+	Attachments     model.AttachmentRules `json:"attachments"`
+	CreatedAt       int64                 `json:"created_at"`
+	UpdatedAt       int64                 `json:"updated_at"`
 }
 
 // CreateColumn 处理创建栏目请求
@@ -115,6 +110,9 @@ func CreateColumn(c *gin.Context) {
 		response.Fail(c, response.ErrNotFound.WithTips("关联的项目不存在或已被删除"))
 		return
 	}
+	if !permission.RequireWritableRole(c, project.ActivityID, StudentID, model.MemberManager, "在该项目下创建栏目") {
+		return
+	}
 
 	// 验证栏目的时间范围是否在项目时间范围内
 	if req.StartDate < project.StartDate || req.EndDate > project.EndDate {
@@ -159,6 +157,10 @@ func CreateColumn(c *gin.Context) {
 		response.Fail(c, response.ErrInvalidRequest.WithTips("积分必须大于0!"))
 		return
 	}
+	if err := req.Attachments.Validate(); err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithTips(err.Error()))
+		return
+	}
 	// 创建新的栏目模型
 	column := model.Column{
 		Name:            req.Name,
@@ -175,6 +177,7 @@ func CreateColumn(c *gin.Context) {
 		Optional:        req.Optional,
 		MinWordLimit:    req.MinWordLimit,
 		MaxWordLimit:    req.MaxWordLimit,
+		Attachments:     req.Attachments,
 	}
 
 	if err := database.DB.Create(&column).Error; err != nil {
@@ -210,9 +213,12 @@ func UpdateColumn(c *gin.Context) {
 	}
 
 	var column model.Column
-	if err := database.DB.Where("id = ? AND owner_id = ?", c.Param("id"), StudentID).First(&column).Error; err != nil {
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&column).Error; err != nil {
 		log.Error("查询栏目失败", "error", err)
-		response.Fail(c, response.ErrNotFound.WithTips("栏目不存在或无权限"))
+		response.Fail(c, response.ErrNotFound.WithTips("栏目不存在"))
+		return
+	}
+	if !requireProjectRole(c, column.ProjectID, StudentID, model.MemberManager, "更新该栏目") {
 		return
 	}
 
@@ -230,6 +236,10 @@ func UpdateColumn(c *gin.Context) {
 			response.Fail(c, response.ErrNotFound.WithTips("关联的项目不存在或已被删除"))
 			return
 		}
+		// 移动到其他项目时，需同时是目标项目所属活动的管理员
+		if project.ID != column.ProjectID && !permission.RequireWritableRole(c, project.ActivityID, StudentID, model.MemberManager, "将栏目移动到该项目") {
+			return
+		}
 
 		// 计算更新后的时间范围
 		startDate := column.StartDate
@@ -343,6 +353,13 @@ func UpdateColumn(c *gin.Context) {
 	if req.MaxWordLimit != nil {
 		column.MaxWordLimit = req.MaxWordLimit
 	}
+	if req.Attachments != nil {
+		if err := req.Attachments.Validate(); err != nil {
+			response.Fail(c, response.ErrInvalidRequest.WithTips(err.Error()))
+			return
+		}
+		column.Attachments = *req.Attachments
+	}
 
 	if err := database.DB.Save(&column).Error; err != nil {
 		log.Error("更新栏目失败", "error", err)
@@ -378,9 +395,12 @@ func DeleteColumn(c *gin.Context) {
 
 	// 查询栏目是否存在
 	var column model.Column
-	if err := database.DB.First(&column, "id = ? AND owner_id = ?", id, StudentID).Error; err != nil {
+	if err := database.DB.First(&column, "id = ?", id).Error; err != nil {
 		log.Error("查询栏目失败", "error", err)
-		response.Fail(c, response.ErrNotFound.WithTips("栏目不存在或无权限"))
+		response.Fail(c, response.ErrNotFound.WithTips("栏目不存在"))
+		return
+	}
+	if !requireProjectRole(c, column.ProjectID, StudentID, model.MemberManager, "删除该栏目") {
 		return
 	}
 
@@ -406,10 +426,19 @@ func GetColumn(c *gin.Context) {
 	// 获取认证信息
 	payload, exists := c.Get("payload")
 	var userID uint
+	var studentID string
+	var viewer *model.User
 	if exists {
 		userPayload, ok := payload.(*jwt.Claims)
 		if ok {
-			userID = userPayload.ID
+			userID, studentID = userPayload.ID, userPayload.StudentID
+			// 普通用户只能查看面向自己的栏目
+			var err error
+			if viewer, err = model.AudienceViewer(database.DB, userPayload.ID, userPayload.RoleID); err != nil {
+				log.Error("查询用户信息失败", "error", err, "user_id", userPayload.ID)
+				response.Fail(c, response.ErrDatabase.WithOrigin(err))
+				return
+			}
 		}
 	}
 
@@ -417,6 +446,7 @@ func GetColumn(c *gin.Context) {
 	// 查询栏目详情，确保关联的项目和活动都未被删除
 	if err := database.DB.Joins("JOIN project ON project.id = column.project_id AND project.deleted_at IS NULL").
 		Joins("JOIN activity ON activity.id = project.activity_id AND activity.deleted_at IS NULL").
+		Scopes(model.ProjectAudienceScope(viewer), model.ActivityStatusScope(studentID)).
 		Preload("Project").Preload("User").
 		First(&column, "column.id = ?", id).Error; err != nil {
 		log.Error("查询栏目失败", "error", err)
@@ -441,6 +471,7 @@ func GetColumn(c *gin.Context) {
 		"optional":          column.Optional,
 		"min_word_limit":    column.MinWordLimit,
 		"max_word_limit":    column.MaxWordLimit,
+		"attachments":       column.Attachments,
 		"created_at":        column.CreatedAt.Unix(),
 		"updated_at":        column.UpdatedAt.Unix(),
 		"project":           column.Project,
@@ -449,9 +480,13 @@ func GetColumn(c *gin.Context) {
 		"today_punch_count": 0,
 	}
 
-	// 如果用户已登录，查询今日打卡状态（使用北京时间）
+	// 如果用户已登录，查询今日打卡状态（按活动的时区与切换时刻）
 	if userID > 0 {
-		today := getTodayStart()
+		day, err := model.ActivityDay(database.DB, column.Project.ActivityID)
+		if err != nil {
+			log.Error("查询活动日界规则失败", "error", err, "column_id", id)
+		}
+		today := day.Today()
 		var todayPunchCount int64
 		database.DB.Model(&model.Punch{}).Where("column_id = ? AND user_id = ? AND created_at >= ?", id, userID, today).Count(&todayPunchCount)
 
@@ -464,11 +499,24 @@ func GetColumn(c *gin.Context) {
 
 // ListColumns 处理获取栏目列表请求
 func ListColumns(c *gin.Context) {
+	userPayload, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	// 普通用户只能看到面向自己的活动及项目下的栏目
+	viewer, err := model.AudienceViewer(database.DB, userPayload.ID, userPayload.RoleID)
+	if err != nil {
+		log.Error("查询用户信息失败", "error", err, "user_id", userPayload.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
 
 	var columns []model.Column
 	// 查询栏目，确保关联的项目和活动未被删除
 	if err := database.DB.Joins("JOIN project ON project.id = column.project_id AND project.deleted_at IS NULL").
 		Joins("JOIN activity ON activity.id = project.activity_id AND activity.deleted_at IS NULL").
+		Scopes(model.ProjectAudienceScope(viewer), model.ActivityStatusScope(userPayload.StudentID)).
 		Preload("Project").Preload("User").
 		Find(&columns).Error; err != nil {
 		log.Error("查询栏目列表失败", "error", err)
@@ -494,6 +542,7 @@ func ListColumns(c *gin.Context) {
 			Optional:        p.Optional,
 			MinWordLimit:    p.MinWordLimit,
 			MaxWordLimit:    p.MaxWordLimit,
+			Attachments:     p.Attachments,
 			CreatedAt:       p.CreatedAt.Unix(),
 			UpdatedAt:       p.UpdatedAt.Unix(),
 		})
@@ -526,9 +575,12 @@ func RestoreColumn(c *gin.Context) {
 	}
 
 	var column model.Column
-	if err := database.DB.Unscoped().Where("id = ? AND owner_id = ?", id, StudentID).First(&column).Error; err != nil {
+	if err := database.DB.Unscoped().Where("id = ?", id).First(&column).Error; err != nil {
 		log.Error("查询栏目失败", "error", err)
-		response.Fail(c, response.ErrNotFound.WithTips("栏目不存在或无权限"))
+		response.Fail(c, response.ErrNotFound.WithTips("栏目不存在"))
+		return
+	}
+	if !requireProjectRole(c, column.ProjectID, StudentID, model.MemberManager, "还原该栏目") {
 		return
 	}
 
@@ -0,0 +1,26 @@
+package column
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/permission"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requireProjectRole 校验用户在项目所属活动中至少拥有 minRole 角色，不满足时写入失败响应
+func requireProjectRole(c *gin.Context, projectID uint, studentID string, minRole int, action string) bool {
+	var activityIDs []uint
+	if err := database.DB.Unscoped().Model(&model.Project{}).Where("id = ?", projectID).
+		Limit(1).Pluck("activity_id", &activityIDs).Error; err != nil {
+		log.Error("查询项目失败", "error", err, "project_id", projectID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return false
+	}
+	if len(activityIDs) == 0 {
+		response.Fail(c, response.ErrNotFound.WithTips("关联的项目不存在"))
+		return false
+	}
+	return permission.RequireWritableRole(c, activityIDs[0], studentID, minRole, action)
+}
@@ -9,7 +9,6 @@ import (
 func (c *ModuleColumn) InitRouter(r *gin.RouterGroup) {
 	// 定义栏目模块的路由组，所有栏目相关端点以 /column 为前缀
 	columnGroup := r.Group("/column")
-	adminGroup := r.Group("/column")
 
 	columnGroup.Use(middleware.Auth(0))
 	{
@@ -18,19 +17,18 @@ func (c *ModuleColumn) InitRouter(r *gin.RouterGroup) {
 
 		// 注册获取单个栏目端点
 		columnGroup.GET("/get/:id", GetColumn)
-	}
-	adminGroup.Use(middleware.Auth(1))
-	{
+
+		// 以下端点由活动成员角色校验权限
 		// 注册创建栏目端点
-		adminGroup.POST("/create", CreateColumn)
+		columnGroup.POST("/create", CreateColumn)
 
 		// 注册更新栏目端点
-		adminGroup.PUT("/update/:id", UpdateColumn)
+		columnGroup.PUT("/update/:id", UpdateColumn)
 
 		// 注册删除栏目端点
-		adminGroup.DELETE("/delete/:id", DeleteColumn)
+		columnGroup.DELETE("/delete/:id", DeleteColumn)
 
 		// 还原删除栏目端点
-		adminGroup.PUT("/restore/:id", RestoreColumn)
+		columnGroup.PUT("/restore/:id", RestoreColumn)
 	}
 }
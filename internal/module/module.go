@@ -2,12 +2,14 @@ package module
 
 import (
 	"activity-punch-system/internal/module/activity"
+	"activity-punch-system/internal/module/announcement"
 	"activity-punch-system/internal/module/column"
 	"activity-punch-system/internal/module/ping"
 	"activity-punch-system/internal/module/project"
 	"activity-punch-system/internal/module/punch"
 	"activity-punch-system/internal/module/star"
 	"activity-punch-system/internal/module/stats"
+	"activity-punch-system/internal/module/storage"
 	"activity-punch-system/internal/module/user"
 
 	"github.com/gin-gonic/gin"
@@ -33,8 +35,10 @@ func init() {
 		&activity.ModuleActivity{},
 		&project.ModuleProject{},
 		&column.ModuleColumn{},
+		&announcement.ModuleAnnouncement{},
 		&stats.ModuleStats{},
 		&star.ModuleStar{},
 		&punch.ModulePunch{},
+		&storage.ModuleStorage{},
 	})
 }
@@ -3,6 +3,7 @@ package project
 import (
 	"activity-punch-system/internal/global/database"
 	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/permission"
 	"activity-punch-system/internal/global/response"
 	"activity-punch-system/internal/model"
 
@@ -30,18 +31,22 @@ type ProjectCreateReq struct {
 	Avatar          string `json:"avatar"`                         // 项目封面URL
 	CompletionBonus uint   `json:"completion_bonus"`               // 完成项目所有栏目后的额外奖励积分，可选，默认0
 	ExemptFromLimit bool   `json:"exempt_from_limit"`              // 该项目的积分是否不计入活动每日积分上限，可选，默认false
+	model.Audience         // 面向人群规则，可选，逗号分隔，在活动规则基础上进一步限定
 }
 
 // ProjectUpdateReq 定义更新项目请求的结构体，使用指针类型支持部分更新
 type ProjectUpdateReq struct {
-	Name            *string `json:"name" binding:"omitempty,max=75"`         // 项目名称，可选
-	Description     *string `json:"description" binding:"omitempty,max=200"` // 项目描述，可选
-	ActivityID      *uint   `json:"activity_id"`                             // 关联的活动ID，可选
-	StartDate       *int64  `json:"start_date"`                              // 项目开始日期，可选
-	EndDate         *int64  `json:"end_date"`                                // 项目结束日期，可选
-	Avatar          *string `json:"avatar"`                                  // 项目封面URL，可选
-	CompletionBonus *uint   `json:"completion_bonus"`                        // 完成项目所有栏目后的额外奖励积分，可选
-	ExemptFromLimit *bool   `json:"exempt_from_limit"`                       // 该项目的积分是否不计入活动每日积分上限，可选
+	Name             *string `json:"name" binding:"omitempty,max=75"`         // 项目名称，可选
+	Description      *string `json:"description" binding:"omitempty,max=200"` // 项目描述，可选
+	ActivityID       *uint   `json:"activity_id"`                             // 关联的活动ID，可选
+	StartDate        *int64  `json:"start_date"`                              // 项目开始日期，可选
+	EndDate          *int64  `json:"end_date"`                                // 项目结束日期，可选
+	Avatar           *string `json:"avatar"`                                  // 项目封面URL，可选
+	CompletionBonus  *uint   `json:"completion_bonus"`                        // 完成项目所有栏目后的额外奖励积分，可选
+	ExemptFromLimit  *bool   `json:"exempt_from_limit"`                       // 该项目的积分是否不计入活动每日积分上限，可选
+	AudienceColleges *string `json:"audience_colleges"`                       // 限定学院，可选，逗号分隔，空字符串表示不限制
+	AudienceGrades   *string `json:"audience_grades"`                         // 限定年级，可选
+	AudienceMajors   *string `json:"audience_majors"`                         // 限定专业，可选
 }
 
 // ProjectResponse 定义项目响应结构体（不包含空的Activity字段）
@@ -56,6 +61,7 @@ type ProjectResponse struct {
 	Avatar          string `json:"avatar"`
 	CompletionBonus uint   `json:"completion_bonus"`
 	ExemptFromLimit bool   `json:"exempt_from_limit"`
+	model.Audience
 }
 
 // CreateProject 处理创建项目请求
@@ -88,6 +94,9 @@ func CreateProject(c *gin.Context) {
 		response.Fail(c, response.ErrNotFound.WithTips("关联的活动不存在或已被删除"))
 		return
 	}
+	if !permission.RequireWritableRole(c, activity.ID, StudentID, model.MemberManager, "在该活动下创建项目") {
+		return
+	}
 
 	// 验证项目的时间范围是否在活动时间范围内
 	if req.StartDate < activity.StartDate || req.EndDate > activity.EndDate {
@@ -124,7 +133,9 @@ func CreateProject(c *gin.Context) {
 		OwnerID:         StudentID,
 		CompletionBonus: req.CompletionBonus,
 		ExemptFromLimit: req.ExemptFromLimit,
+		Audience:        req.Audience,
 	}
+	project.Audience.Normalize()
 
 	// 保存到数据库
 	if err := database.DB.Create(&project).Error; err != nil {
@@ -163,11 +174,14 @@ func UpdateProject(c *gin.Context) {
 	}
 
 	var project model.Project
-	if err := database.DB.Where("owner_id = ? AND id = ?", StudentID, c.Param("id")).First(&project).Error; err != nil {
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&project).Error; err != nil {
 		log.Error("查询项目失败", "error", err)
 		response.Fail(c, response.ErrNotFound.WithTips("项目未找到"))
 		return
 	}
+	if !permission.RequireWritableRole(c, project.ActivityID, StudentID, model.MemberManager, "更新该项目") {
+		return
+	}
 
 	// 如果要更新ActivityID或时间字段，需要验证
 	if req.ActivityID != nil || req.StartDate != nil || req.EndDate != nil {
@@ -183,6 +197,10 @@ func UpdateProject(c *gin.Context) {
 			response.Fail(c, response.ErrNotFound.WithTips("关联的活动不存在或已被删除"))
 			return
 		}
+		// 移动到其他活动时，需同时是目标活动的管理员
+		if activity.ID != project.ActivityID && !permission.RequireWritableRole(c, activity.ID, StudentID, model.MemberManager, "将项目移动到该活动") {
+			return
+		}
 
 		// 计算更新后的时间范围
 		startDate := project.StartDate
@@ -235,6 +253,16 @@ func UpdateProject(c *gin.Context) {
 	if req.ExemptFromLimit != nil {
 		project.ExemptFromLimit = *req.ExemptFromLimit
 	}
+	if req.AudienceColleges != nil {
+		project.AudienceColleges = *req.AudienceColleges
+	}
+	if req.AudienceGrades != nil {
+		project.AudienceGrades = *req.AudienceGrades
+	}
+	if req.AudienceMajors != nil {
+		project.AudienceMajors = *req.AudienceMajors
+	}
+	project.Audience.Normalize()
 
 	if err := database.DB.Save(&project).Error; err != nil {
 		log.Error("更新项目失败", "error", err)
@@ -271,11 +299,14 @@ func DeleteProject(c *gin.Context) {
 
 	// 查询项目是否存在
 	var project model.Project
-	if err := database.DB.First(&project, "id = ? AND owner_id = ?", id, StudentID).Error; err != nil {
+	if err := database.DB.First(&project, "id = ?", id).Error; err != nil {
 		log.Error("查询项目失败", "error", err)
 		response.Fail(c, response.ErrNotFound.WithTips("项目未找到"))
 		return
 	}
+	if !permission.RequireWritableRole(c, project.ActivityID, StudentID, model.MemberManager, "删除该项目") {
+		return
+	}
 
 	// 使用事务进行级联删除
 	err := database.DB.Transaction(func(tx *gorm.DB) error {
@@ -328,11 +359,14 @@ func RestoreProject(c *gin.Context) {
 	}
 
 	var project model.Project
-	if err := database.DB.Unscoped().Where("id = ? AND owner_id = ?", id, StudentID).First(&project).Error; err != nil {
+	if err := database.DB.Unscoped().Where("id = ?", id).First(&project).Error; err != nil {
 		log.Error("查询项目失败", "error", err)
 		response.Fail(c, response.ErrNotFound.WithTips("项目未找到"))
 		return
 	}
+	if !permission.RequireWritableRole(c, project.ActivityID, StudentID, model.MemberManager, "还原该项目") {
+		return
+	}
 
 	// 使用事务进行级联恢复
 	err := database.DB.Transaction(func(tx *gorm.DB) error {
@@ -377,9 +411,18 @@ func ListProjects(c *gin.Context) {
 	}
 	StudentID := userPayload.StudentID
 
+	// 普通用户只能看到面向自己的项目
+	viewer, err := model.AudienceViewer(database.DB, userPayload.ID, userPayload.RoleID)
+	if err != nil {
+		log.Error("查询用户信息失败", "error", err, "user_id", userPayload.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+
 	var projects []model.Project
 	// 查询项目时，同时确保关联的活动未被删除
 	if err := database.DB.Joins("JOIN activity ON activity.id = project.activity_id AND activity.deleted_at IS NULL").
+		Scopes(model.ProjectAudienceScope(viewer), model.ActivityStatusScope(StudentID)).
 		Find(&projects).Error; err != nil {
 		log.Error("查询项目列表失败", "error", err)
 		response.Fail(c, response.ErrDatabase.WithOrigin(err))
@@ -400,6 +443,7 @@ func ListProjects(c *gin.Context) {
 			Avatar:          p.Avatar,
 			CompletionBonus: p.CompletionBonus,
 			ExemptFromLimit: p.ExemptFromLimit,
+			Audience:        p.Audience,
 		})
 	}
 
@@ -409,34 +453,41 @@ func ListProjects(c *gin.Context) {
 
 // ColumnInProject 栏目信息结构体（用于项目详情返回）
 type ColumnInProject struct {
-	ID              uint   `json:"id"`
-	Name            string `json:"name"`
-	Avatar          string `json:"avatar"`
-	Description     string `json:"description"`       // 栏目描述
-	StartDate       int64  `json:"start_date"`        // 栏目开始日期
-	EndDate         int64  `json:"end_date"`          // 栏目结束日期
-	DailyPunchLimit int    `json:"daily_punch_limit"` // 每日可打卡次数，0表示不限次数
-	PointEarned     uint   `json:"point_earned"`      // 每次打卡可获得的积分
-	StartTime       string `json:"start_time"`        // 每日打卡开始时间，格式为 "HH:MM"
-	EndTime         string `json:"end_time"`          // 每日打卡结束时间，格式为 "HH:MM"
-	Optional        bool   `json:"optional"`          // 特殊栏目，不计入完成所有栏目的判断
-	MinWordLimit    *uint  `json:"min_word_limit"`    // 最小字数限制，可选，null表示不限制
-	MaxWordLimit    *uint  `json:"max_word_limit"`    // 最大字数限制，可选，null表示不限制
+	ID              uint                  `json:"id"`
+	Name            string                `json:"name"`
+	Avatar          string                `json:"avatar"`
+	Description     string                `json:"description"`       // 栏目描述
+	StartDate       int64                 `json:"start_date"`        // 栏目开始日期
+	EndDate         int64                 `json:"end_date"`          // 栏目结束日期
+	DailyPunchLimit int                   `json:"daily_punch_limit"` // 每日可打卡次数，0表示不限次数
+	PointEarned     uint                  `json:"point_earned"`      // 每次打卡可获得的积分
+	StartTime       string                `json:"start_time"`        // 每日打卡开始时间，格式为 "HH:MM"
+	EndTime         string                `json:"end_time"`          // 每日打卡结束时间，格式为 "HH:MM"
+	Optional        bool                  `json:"optional"`          // 特殊栏目，不计入完成所有栏目的判断
+	MinWordLimit    *uint                 `json:"min_word_limit"`    // 最小字数限制，可选，null表示不限制
+	MaxWordLimit    *uint                 `json:"max_word_limit"`    // 最大字数限制，可选，null表示不限制
+	Attachments     model.AttachmentRules `json:"attachments"`       // 接受的附件类型及限制，为空时只接受图片
 }
 
 type GetProjectResponse struct {
-	ID              uint              `json:"id"`
-	Name            string            `json:"name"`
-	Avatar          string            `json:"avatar"`
-	Description     string            `json:"description"`
-	StartDate       int64             `json:"start_date"`
-	EndDate         int64             `json:"end_date"`
-	CompletionBonus uint              `json:"completion_bonus"`
-	ExemptFromLimit bool              `json:"exempt_from_limit"`
-	Columns         []ColumnInProject `json:"columns"`
+	ID              uint   `json:"id"`
+	Name            string `json:"name"`
+	Avatar          string `json:"avatar"`
+	Description     string `json:"description"`
+	StartDate       int64  `json:"start_date"`
+	EndDate         int64  `json:"end_date"`
+	CompletionBonus uint   `json:"completion_bonus"`
+	ExemptFromLimit bool   `json:"exempt_from_limit"`
+	model.Audience
+	Columns []ColumnInProject `json:"columns"`
 }
 
 func GetProject(c *gin.Context) {
+	userPayload, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
 
 	// 获取项目ID
 	id := c.Param("id")
@@ -447,12 +498,41 @@ func GetProject(c *gin.Context) {
 	}
 
 	var project model.Project
-	if err := database.DB.Where("id = ?", id).First(&project).Error; err != nil {
+	if err := database.DB.Preload("Activity").Where("id = ?", id).First(&project).Error; err != nil {
 		log.Error("查询项目失败", "error", err)
 		response.Fail(c, response.ErrNotFound.WithTips("项目未找到"))
 		return
 	}
 
+	// 普通用户只能查看面向自己的项目
+	viewer, err := model.AudienceViewer(database.DB, userPayload.ID, userPayload.RoleID)
+	if err != nil {
+		log.Error("查询用户信息失败", "error", err, "user_id", userPayload.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	if visible, err := model.ProjectVisible(database.DB, &project.Activity, &project, viewer); err != nil {
+		log.Error("查询活动成员失败", "error", err, "id", id)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	} else if !visible {
+		response.Fail(c, response.ErrNotFound.WithTips("项目未找到"))
+		return
+	}
+	// 草稿与定时发布活动下的项目只有活动成员可以查看
+	if !project.Activity.Visible() {
+		role, err := model.MemberRole(database.DB, project.ActivityID, userPayload.StudentID)
+		if err != nil {
+			log.Error("查询活动成员失败", "error", err, "id", id)
+			response.Fail(c, response.ErrDatabase.WithOrigin(err))
+			return
+		}
+		if role == 0 {
+			response.Fail(c, response.ErrNotFound.WithTips("项目未找到"))
+			return
+		}
+	}
+
 	// 查询该项目下的所有栏目
 	var columns []model.Column
 	if err := database.DB.Where("project_id = ?", project.ID).Find(&columns).Error; err != nil {
@@ -478,6 +558,7 @@ func GetProject(c *gin.Context) {
 			Optional:        col.Optional,
 			MinWordLimit:    col.MinWordLimit,
 			MaxWordLimit:    col.MaxWordLimit,
+			Attachments:     col.Attachments,
 		})
 	}
 
@@ -491,6 +572,7 @@ func GetProject(c *gin.Context) {
 		EndDate:         project.EndDate,
 		CompletionBonus: project.CompletionBonus,
 		ExemptFromLimit: project.ExemptFromLimit,
+		Audience:        project.Audience,
 		Columns:         columnResponses,
 	}
 
@@ -0,0 +1,254 @@
+package punch
+
+import (
+	"activity-punch-system/internal/global/clock"
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/pictureBed"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DraftSaveRequest 自动保存草稿的请求体，每次保存整体覆盖
+type DraftSaveRequest struct {
+	Content string   `json:"content" binding:"max=10000"`
+	Images  []string `json:"images" binding:"omitempty,max=9"`
+}
+
+// DraftWithColumn 草稿及其栏目名称
+type DraftWithColumn struct {
+	model.PunchDraft
+	ColumnName string `json:"column_name"`
+}
+
+// draftColumnID 解析路径中的栏目 ID
+func draftColumnID(c *gin.Context) (int, bool) {
+	columnID, err := strconv.Atoi(c.Param("column_id"))
+	if err != nil || columnID <= 0 {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("栏目ID无效"))
+		return 0, false
+	}
+	return columnID, true
+}
+
+// SaveDraft 自动保存打卡草稿，同一栏目的草稿被覆盖。
+// 图片须为本人上传的对象，类型、大小与数量等打卡规则在提交时校验
+func SaveDraft(c *gin.Context) {
+	userPayload, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	columnID, ok := draftColumnID(c)
+	if !ok {
+		return
+	}
+	var req DraftSaveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
+		return
+	}
+
+	var column model.Column
+	if err := database.DB.First(&column, "id = ?", columnID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrNotFound.WithTips("栏目不存在"))
+			return
+		}
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	if err := checkColumnWritable(uint(columnID)); errors.Is(err, model.ErrActivityArchived) {
+		response.Fail(c, response.ErrForbidden.WithTips(err.Error()))
+		return
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+
+	images := make([]string, 0, len(req.Images))
+	if len(req.Images) > 0 {
+		store, err := pictureBed.New(c.Request.Context())
+		if err != nil {
+			log.Error("初始化对象存储失败", "error", err)
+			response.Fail(c, response.ErrServerInternal.WithTips("初始化存储服务失败"))
+			return
+		}
+		keys := make([]string, 0, len(req.Images))
+		for _, u := range req.Images {
+			key, ok := pictureBed.ResolveKey(store, u)
+			if !ok {
+				response.Fail(c, response.ErrInvalidRequest.WithTips("图片地址无效"))
+				return
+			}
+			keys = append(keys, key)
+			images = append(images, pictureBed.StoredRef(store, key, u))
+		}
+		var owned int64
+		if err := database.DB.Model(&model.UploadKey{}).Where("object_key IN ? AND user_id = ?", keys, userPayload.ID).
+			Distinct("object_key").Count(&owned).Error; err != nil {
+			response.Fail(c, response.ErrDatabase.WithOrigin(err))
+			return
+		}
+		if int(owned) != len(uniqueStrings(keys)) {
+			response.Fail(c, response.ErrInvalidRequest.WithTips("图片不是由你上传的"))
+			return
+		}
+	}
+
+	draft := model.PunchDraft{
+		UserID:   userPayload.ID,
+		ColumnID: columnID,
+		Content:  req.Content,
+		Images:   images,
+	}
+	if err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "column_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content", "images", "updated_at"}),
+	}).Create(&draft).Error; err != nil {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	response.Success(c, gin.H{"column_id": columnID, "updated_at": draft.UpdatedAt})
+}
+
+// uniqueStrings 去除重复元素
+func uniqueStrings(items []string) []string {
+	seen := make(map[string]struct{}, len(items))
+	result := make([]string, 0, len(items))
+	for _, s := range items {
+		if _, ok := seen[s]; !ok {
+			seen[s] = struct{}{}
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// ListDrafts 查询自己的全部草稿，按最近保存时间倒序
+func ListDrafts(c *gin.Context) {
+	userPayload, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	var drafts []DraftWithColumn
+	if err := database.DB.Table("punch_draft").
+		Select("punch_draft.*, `column`.name AS column_name").
+		Joins("JOIN `column` ON `column`.id = punch_draft.column_id AND `column`.deleted_at IS NULL").
+		Where("punch_draft.user_id = ?", userPayload.ID).
+		Order("punch_draft.updated_at DESC").
+		Find(&drafts).Error; err != nil {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	store := linkStore(c.Request.Context())
+	for i := range drafts {
+		drafts[i].Images = pictureBed.ReadURLs(c.Request.Context(), store, drafts[i].Images)
+	}
+	response.Success(c, drafts)
+}
+
+// GetDraft 查询自己在某栏目的草稿，用于恢复编辑
+func GetDraft(c *gin.Context) {
+	userPayload, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	columnID, ok := draftColumnID(c)
+	if !ok {
+		return
+	}
+	var draft model.PunchDraft
+	if err := database.DB.First(&draft, "user_id = ? AND column_id = ?", userPayload.ID, columnID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrNotFound.WithTips("草稿不存在"))
+			return
+		}
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	draft.Images = pictureBed.ReadURLs(c.Request.Context(), linkStore(c.Request.Context()), draft.Images)
+	response.Success(c, draft)
+}
+
+// DeleteDraft 删除自己在某栏目的草稿
+func DeleteDraft(c *gin.Context) {
+	userPayload, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	columnID, ok := draftColumnID(c)
+	if !ok {
+		return
+	}
+	if err := database.DB.Where("user_id = ? AND column_id = ?", userPayload.ID, columnID).
+		Delete(&model.PunchDraft{}).Error; err != nil {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	response.Success(c)
+}
+
+// SubmitDraft 将草稿提交为正式打卡，与直接打卡经过相同的校验，成功后草稿被删除
+func SubmitDraft(c *gin.Context) {
+	userPayload, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	columnID, ok := draftColumnID(c)
+	if !ok {
+		return
+	}
+	var draft model.PunchDraft
+	if err := database.DB.First(&draft, "user_id = ? AND column_id = ?", userPayload.ID, columnID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrNotFound.WithTips("草稿不存在"))
+			return
+		}
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	if strings.TrimSpace(draft.Content) == "" {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("打卡内容不能为空"))
+		return
+	}
+
+	punch, err := createPunch(c.Request.Context(), userPayload, &PunchInsertRequest{
+		ColumnID: draft.ColumnID,
+		Content:  draft.Content,
+		Images:   draft.Images,
+	})
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, punch)
+}
+
+// cleanStaleDrafts 删除栏目已结束或已删除的草稿，各活动的时区与切换时刻不同，结束一天后再清理
+func cleanStaleDrafts() {
+	yesterday := clock.Default().Int(time.Now().AddDate(0, 0, -1))
+	ended := database.DB.Table("`column`").Select("id").
+		Where("deleted_at IS NOT NULL OR (end_date > 0 AND end_date < ?)", yesterday)
+	live := database.DB.Table("`column`").Select("id")
+	r := database.DB.Where("column_id IN (?) OR column_id NOT IN (?)", ended, live).Delete(&model.PunchDraft{})
+	if r.Error != nil {
+		log.Error("清理过期草稿失败", "error", r.Error)
+		return
+	}
+	if r.RowsAffected > 0 {
+		log.Info("清理过期草稿", "count", r.RowsAffected)
+	}
+}
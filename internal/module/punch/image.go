@@ -0,0 +1,112 @@
+package punch
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/pictureBed"
+	"activity-punch-system/internal/model"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	imageTimeout    = time.Minute     // 单张图片的处理超时
+	imageRetryDelay = 5 * time.Minute // 提交后超过该时长仍未处理的图片由定时任务补处理
+	imageBatchSize  = 50              // 定时任务每次处理的图片数
+	imageWorkers    = 2               // 同时处理的图片数，解码大图占用内存较多
+	imageMaxAttempt = 5               // 处理失败达到该次数后标记为失败，不再重试
+)
+
+// imageSem 限制同时处理的图片数
+var imageSem = make(chan struct{}, imageWorkers)
+
+// processImages 在后台处理新提交的打卡图片，失败的图片恢复为待处理，由定时任务重试至 imageMaxAttempt 次
+func processImages(imgs []model.PunchImg) {
+	if len(imgs) == 0 {
+		return
+	}
+	go func() {
+		for i := range imgs {
+			processImage(&imgs[i])
+		}
+	}()
+}
+
+// processImage 去除图片元数据并生成缩略图与中图，记录到 punch_img
+func processImage(img *model.PunchImg) {
+	imageSem <- struct{}{}
+	defer func() { <-imageSem }()
+
+	// 先领取再处理，多实例部署或提交时的后台处理与定时任务重叠时同一张图片只处理一次
+	if claimed, err := claimImage(img.ID); err != nil {
+		log.Error("领取打卡图片失败", "id", img.ID, "error", err)
+		return
+	} else if !claimed {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), imageTimeout)
+	defer cancel()
+	store, err := pictureBed.New(ctx)
+	if err != nil {
+		log.Error("初始化对象存储失败", "error", err)
+		return
+	}
+
+	updates := map[string]any{"process": model.PunchImgSkipped}
+	if key, ok := pictureBed.ResolveKey(store, img.ImgURL); ok {
+		res, err := pictureBed.ProcessImage(ctx, store, key)
+		switch {
+		case errors.Is(err, pictureBed.ErrUnsupportedImage), errors.Is(err, pictureBed.ErrObjectNotFound):
+			log.Warn("打卡图片无法处理，使用原图", "id", img.ID, "key", key, "error", err)
+		case err != nil:
+			log.Error("处理打卡图片失败", "id", img.ID, "key", key, "error", err, "attempts", img.Attempts+1)
+			updates = map[string]any{"process": model.PunchImgPending, "attempts": gorm.Expr("attempts + 1")}
+			if img.Attempts+1 >= imageMaxAttempt {
+				updates["process"] = model.PunchImgFailed
+			}
+		default:
+			thumb, medium := res.ThumbURL, res.MediumURL
+			if img.ImgURL == key { // 原图以 key 保存（私有模式）时各尺寸同样只保存 key
+				thumb, medium = res.ThumbKey, res.MediumKey
+			}
+			updates = map[string]any{
+				"process":    model.PunchImgProcessed,
+				"thumb_url":  thumb,
+				"medium_url": medium,
+			}
+		}
+	}
+	if err := database.DB.Model(&model.PunchImg{}).Where("id = ?", img.ID).Updates(updates).Error; err != nil {
+		log.Error("更新打卡图片处理状态失败", "id", img.ID, "error", err)
+	}
+}
+
+// claimImage 将待处理或领取后超过 imageRetryDelay 仍未完成（实例中途退出）的图片标记为处理中，返回是否领取成功
+func claimImage(id uint) (bool, error) {
+	r := database.DB.Model(&model.PunchImg{}).
+		Where("id = ? AND (process = ? OR (process = ? AND updated_at < ?))",
+			id, model.PunchImgPending, model.PunchImgProcessing, time.Now().Add(-imageRetryDelay)).
+		Update("process", model.PunchImgProcessing)
+	return r.RowsAffected == 1, r.Error
+}
+
+// processPendingImages 补处理提交时未能处理的图片，包括功能上线前的历史图片
+func processPendingImages() {
+	var imgs []model.PunchImg
+	before := time.Now().Add(-imageRetryDelay)
+	if err := database.DB.Where("(process = ? AND created_at < ?) OR (process = ? AND updated_at < ?)",
+		model.PunchImgPending, before, model.PunchImgProcessing, before).
+		Order("id").Limit(imageBatchSize).Find(&imgs).Error; err != nil {
+		log.Error("查询待处理打卡图片失败", "error", err)
+		return
+	}
+	for i := range imgs {
+		processImage(&imgs[i])
+	}
+	if len(imgs) > 0 {
+		log.Info("补处理打卡图片", "count", len(imgs))
+	}
+}
@@ -2,7 +2,9 @@ package punch
 
 import (
 	"activity-punch-system/internal/global/logger"
+	"activity-punch-system/internal/global/schedule"
 	"log/slog"
+	"time"
 )
 
 var log *slog.Logger
@@ -15,6 +17,8 @@ func (p *ModulePunch) GetName() string {
 
 func (u *ModulePunch) Init() {
 	log = logger.New("Punch")
+	schedule.Every("punch:image", 10*time.Minute, processPendingImages)
+	schedule.Daily("punch:draft", 4, 0, cleanStaleDrafts)
 }
 
 func selfInit() {
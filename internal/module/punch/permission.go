@@ -0,0 +1,32 @@
+package punch
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// columnActivityID 查询栏目所属的活动，栏目或项目不存在时返回 gorm.ErrRecordNotFound
+func columnActivityID(columnID uint) (uint, error) {
+	var activityIDs []uint
+	if err := database.DB.Table("`column`").
+		Joins("JOIN project ON project.id = `column`.project_id").
+		Where("`column`.id = ?", columnID).
+		Limit(1).Pluck("project.activity_id", &activityIDs).Error; err != nil {
+		return 0, err
+	}
+	if len(activityIDs) == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return activityIDs[0], nil
+}
+
+// checkColumnWritable 栏目所属活动已归档时返回 model.ErrActivityArchived
+func checkColumnWritable(columnID uint) error {
+	activityID, err := columnActivityID(columnID)
+	if err != nil {
+		return err
+	}
+	return model.CheckActivityWritable(database.DB, activityID)
+}
@@ -1,7 +1,7 @@
 package punch
 
 import (
-	"activity-punch-system/config"
+	"activity-punch-system/internal/global/clock"
 	"activity-punch-system/internal/global/database"
 	"activity-punch-system/internal/global/jwt"
 	"activity-punch-system/internal/global/pictureBed"
@@ -17,30 +17,18 @@ import (
 	"errors"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/gin-gonic/gin"
 )
 
-// 北京时区
-var beijingLocation = time.FixedZone("CST", 8*60*60)
-
-// getTodayStart 获取北京时间今日零点
-func getTodayStart() time.Time {
-	now := time.Now().In(beijingLocation)
-	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, beijingLocation)
-}
-
-// getDayStart 获取指定时间在北京时区对应那一天的零点
-func getDayStart(t time.Time) time.Time {
-	inBeijing := t.In(beijingLocation)
-	return time.Date(inBeijing.Year(), inBeijing.Month(), inBeijing.Day(), 0, 0, 0, 0, beijingLocation)
-}
-
 // PunchInsertRequest 定义插入打卡记录的请求体结构
 type PunchInsertRequest struct {
 	ColumnID int      `json:"column_id" binding:"required"`
-	Content  string   `json:"content" binding:"required"` // 字数限制由栏目的 min_word_limit 和 max_word_limit 控制
+	Content  string   `json:"content" binding:"required,max=10000"` // 与草稿的上限一致，栏目内的字数限制由 min_word_limit 和 max_word_limit 控制
 	Images   []string `json:"images" binding:"omitempty,max=9"`
+	// 音频、视频与文档附件，可接受的类型与数量由栏目的 attachments 控制
+	Attachments []AttachmentReq `json:"attachments" binding:"omitempty,max=20,dive"`
 }
 
 type PunchWithImgs struct {
@@ -70,48 +58,45 @@ func InsertPunch(c *gin.Context) {
 		return
 	}
 
-	// 验证栏目ID不能为空或小于等于0
-	if req.ColumnID <= 0 {
-		response.Fail(c, response.ErrInvalidRequest.WithTips("栏目ID不能为空"))
-		return
-	}
-	today := getTodayStart()
-	count := int64(0)
-	// 统计今日打卡次数：包含未删除的所有记录 + 已删除但审核不通过的记录（防止删除后重新打卡绕过限制）
-	if err := database.DB.Table("punch").
-		Where("user_id = ? AND column_id = ? AND created_at >= ?", userPayload.ID, req.ColumnID, today).
-		Where("deleted_at IS NULL OR status = 2").
-		Count(&count).Error; err != nil {
-		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+	punch, err := createPunch(c.Request.Context(), userPayload, &req)
+	if err != nil {
+		response.Fail(c, err)
 		return
 	}
+	response.Success(c, punch)
+}
 
-	// 查询栏目每日打卡限制
-	var columnLimit int64
-	if err := database.DB.Model(&model.Column{}).Select("daily_punch_limit").Where("id = ?", req.ColumnID).Scan(&columnLimit).Error; err != nil {
-		response.Fail(c, response.ErrDatabase.WithOrigin(err))
-		return
-	}
+// errDailyLimitReached 今日打卡次数已达栏目上限
+var errDailyLimitReached = errors.New("今日已达到打卡次数上限")
 
-	// columnLimit > 0 表示有设置每日打卡次数限制，0 表示不限制
-	if columnLimit > 0 && count >= columnLimit {
-		response.Fail(c, response.ErrInvalidRequest.WithTips("今日已达到打卡次数上限，无法继续打卡"))
-		return
+// createPunch 校验打卡请求并保存打卡记录、图片、附件与第 1 版快照，成功后删除该栏目的草稿。
+// 草稿提交也经过此处，返回的错误可直接交给 response.Fail
+func createPunch(ctx context.Context, userPayload *jwt.Claims, req *PunchInsertRequest) (*model.Punch, error) {
+	// 验证栏目ID不能为空或小于等于0
+	if req.ColumnID <= 0 {
+		return nil, response.ErrInvalidRequest.WithTips("栏目ID不能为空")
+	}
+	// 按栏目所属活动的时区与每日切换时刻划分“今天”
+	day, err := model.ColumnDay(database.DB, uint(req.ColumnID))
+	if err != nil {
+		return nil, response.ErrDatabase.WithOrigin(err)
 	}
-
 	// 获取栏目时间范围，判断是否允许打卡
 	var column model.Column
 	if err := database.DB.Preload("Project").Preload("Project.Activity").First(&column, "id = ?", req.ColumnID).Error; err != nil {
-		response.Fail(c, response.ErrNotFound.WithTips("栏目不存在"))
-		return
+		return nil, response.ErrNotFound.WithTips("栏目不存在")
 	}
-	// 解析栏目的日期和时间范围（使用本地时区）
+
+	if err := checkPunchAllowed(userPayload, &column); err != nil {
+		return nil, err
+	}
+	// 解析栏目的日期和时间范围（使用活动的时区）
 	startDateStr := strconv.FormatInt(column.StartDate, 10)
 	endDateStr := strconv.FormatInt(column.EndDate, 10)
-	loc := time.Local // 使用本地时区
-	startDate, _ := time.ParseInLocation("20060102", startDateStr, loc)
-	endDate, _ := time.ParseInLocation("20060102", endDateStr, loc)
-	currentTime := time.Now()
+	loc := day.Location
+	startDate, _ := day.ParseDate("20060102", startDateStr)
+	endDate, _ := day.ParseDate("20060102", endDateStr)
+	currentTime := day.Now()
 
 	// 构建完整的开始和结束时间点
 	var punchStartTime, punchEndTime time.Time
@@ -120,35 +105,31 @@ func InsertPunch(c *gin.Context) {
 		// 如果设置了每日开始时间，使用 StartDate + StartTime
 		parsedTime, err := time.Parse("15:04", column.StartTime)
 		if err != nil {
-			response.Fail(c, response.ErrInvalidRequest.WithTips("每日开始时间格式错误"))
-			return
+			return nil, response.ErrInvalidRequest.WithTips("每日开始时间格式错误")
 		}
 		punchStartTime = time.Date(startDate.Year(), startDate.Month(), startDate.Day(),
 			parsedTime.Hour(), parsedTime.Minute(), 0, 0, loc)
 	} else {
-		// 没有设置开始时间，默认为 StartDate 00:00:00
-		punchStartTime = startDate
+		// 没有设置开始时间，默认为 StartDate 当天的切换时刻（默认 00:00）
+		punchStartTime = day.StartOf(startDate)
 	}
 
 	if column.EndTime != "" {
 		// 如果设置了每日结束时间，使用 EndDate + EndTime
 		parsedTime, err := time.Parse("15:04", column.EndTime)
 		if err != nil {
-			response.Fail(c, response.ErrInvalidRequest.WithTips("每日结束时间格式错误"))
-			return
+			return nil, response.ErrInvalidRequest.WithTips("每日结束时间格式错误")
 		}
 		punchEndTime = time.Date(endDate.Year(), endDate.Month(), endDate.Day(),
 			parsedTime.Hour(), parsedTime.Minute(), 59, 0, loc)
 	} else {
-		// 没有设置结束时间，默认为 EndDate 23:59:59
-		punchEndTime = time.Date(endDate.Year(), endDate.Month(), endDate.Day(),
-			23, 59, 59, 0, loc)
+		// 没有设置结束时间，默认到 EndDate 这一天结束（次日切换时刻前一秒，默认 23:59:59）
+		punchEndTime = day.StartOf(endDate.AddDate(0, 0, 1)).Add(-time.Second)
 	}
 
 	// 判断当前时间是否在允许的打卡时间范围内
 	if currentTime.Before(punchStartTime) || currentTime.After(punchEndTime) {
-		response.Fail(c, response.ErrInvalidRequest.WithTips("当前时间不在栏目时间范围内，无法打卡"))
-		return
+		return nil, response.ErrInvalidRequest.WithTips("当前时间不在栏目时间范围内，无法打卡")
 	}
 
 	// 如果栏目跨多天且设置了每日打卡时间段，还需要检查当天的时间段
@@ -162,14 +143,12 @@ func InsertPunch(c *gin.Context) {
 		if endTime.Before(startTime) {
 			// 跨天情况：当前时间在开始时间之后或结束时间之前
 			if currentParsed.Before(startTime) && currentParsed.After(endTime) {
-				response.Fail(c, response.ErrInvalidRequest.WithTips("当前时间不在每日打卡时间范围内，无法打卡"))
-				return
+				return nil, response.ErrInvalidRequest.WithTips("当前时间不在每日打卡时间范围内，无法打卡")
 			}
 		} else {
 			// 不跨天情况：当前时间必须在开始和结束时间之间
 			if currentParsed.Before(startTime) || currentParsed.After(endTime) {
-				response.Fail(c, response.ErrInvalidRequest.WithTips("当前时间不在每日打卡时间范围内，无法打卡"))
-				return
+				return nil, response.ErrInvalidRequest.WithTips("当前时间不在每日打卡时间范围内，无法打卡")
 			}
 		}
 	}
@@ -177,12 +156,20 @@ func InsertPunch(c *gin.Context) {
 	// 验证打卡内容字数限制
 	contentLength := uint(len([]rune(req.Content))) // 使用 rune 计算中文字符数
 	if column.MinWordLimit != nil && contentLength < *column.MinWordLimit {
-		response.Fail(c, response.ErrInvalidRequest.WithTips(fmt.Sprintf("打卡内容字数不能少于 %d 字", *column.MinWordLimit)))
-		return
+		return nil, response.ErrInvalidRequest.WithTips(fmt.Sprintf("打卡内容字数不能少于 %d 字", *column.MinWordLimit))
 	}
 	if column.MaxWordLimit != nil && contentLength > *column.MaxWordLimit {
-		response.Fail(c, response.ErrInvalidRequest.WithTips(fmt.Sprintf("打卡内容字数不能超过 %d 字", *column.MaxWordLimit)))
-		return
+		return nil, response.ErrInvalidRequest.WithTips(fmt.Sprintf("打卡内容字数不能超过 %d 字", *column.MaxWordLimit))
+	}
+
+	// 校验图片与附件均为本人上传且已上传成功，类型与数量符合栏目要求
+	images, err := verifyImages(ctx, userPayload.ID, 0, req.Images, column.Attachments)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := verifyAttachments(ctx, userPayload.ID, 0, req.Attachments, column.Attachments)
+	if err != nil {
+		return nil, err
 	}
 
 	punch := &model.Punch{
@@ -191,32 +178,106 @@ func InsertPunch(c *gin.Context) {
 		Content:  req.Content,
 		Status:   0, // 默认待审核
 	}
-	tx := database.DB.WithContext(context.WithValue(context.Background(), "fk_user_activity", &model.FkUserActivity{
+	// 打卡、图片、附件与第 1 版记录在同一事务中写入
+	var created []model.PunchImg
+	db := database.DB.WithContext(context.WithValue(context.Background(), "fk_user_activity", &model.FkUserActivity{
 		ActivityID: column.Project.Activity.ID,
 		UserID:     userPayload.ID,
 	}))
-	if err := tx.Create(punch).Error; err != nil {
-		log.Error("插入打卡记录失败", "error", err)
-		response.Fail(c, response.ErrDatabase.WithOrigin(err))
-		return
-	}
-
-	// 处理图片URL保存到punch_img表
-	if len(req.Images) > 0 {
-		for _, imgUrl := range req.Images {
-			punchImg := &model.PunchImg{
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 锁定参与记录，同一用户在该活动中的打卡依次写入，每日次数上限不会被并发请求突破
+		var participant model.Participant
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("activity_id = ? AND user_id = ? AND status = ?", column.Project.ActivityID, userPayload.ID, model.ParticipantJoined).
+			First(&participant).Error; err != nil {
+			return err
+		}
+		// DailyPunchLimit > 0 表示有设置每日打卡次数限制，0 表示不限制
+		if column.DailyPunchLimit > 0 {
+			// 统计今日打卡次数：包含未删除的所有记录 + 已删除但审核不通过的记录（防止删除后重新打卡绕过限制）
+			var count int64
+			if err := tx.Table("punch").
+				Where("user_id = ? AND column_id = ? AND created_at >= ?", userPayload.ID, req.ColumnID, day.Today()).
+				Where("deleted_at IS NULL OR status = 2").
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count >= int64(column.DailyPunchLimit) {
+				return errDailyLimitReached
+			}
+		}
+		if err := tx.Create(punch).Error; err != nil {
+			return err
+		}
+		// 处理图片URL保存到punch_img表
+		for _, imgUrl := range images {
+			punchImg := model.PunchImg{
 				PunchID:  punch.ID,
 				ColumnID: req.ColumnID,
 				ImgURL:   imgUrl,
 			}
-			if err := database.DB.Create(punchImg).Error; err != nil {
-				log.Error("插入打卡图片记录失败", "error", err)
-				continue
+			if err := tx.Create(&punchImg).Error; err != nil {
+				return err
+			}
+			created = append(created, punchImg)
+		}
+		for i := range attachments {
+			attachments[i].PunchID, attachments[i].ColumnID = punch.ID, req.ColumnID
+			if err := tx.Create(&attachments[i]).Error; err != nil {
+				return err
 			}
 		}
+		return saveRevision(tx, punch)
+	})
+	if errors.Is(err, errDailyLimitReached) {
+		return nil, response.ErrInvalidRequest.WithTips("今日已达到打卡次数上限，无法继续打卡")
 	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, response.ErrForbidden.WithTips("尚未加入该活动，无法打卡")
+	}
+	if err != nil {
+		log.Error("插入打卡记录失败", "error", err)
+		return nil, response.ErrDatabase.WithOrigin(err)
+	}
+	// 后台去除元数据并生成缩略图
+	processImages(created)
+	// 打卡成功后该栏目的草稿不再需要
+	if err := database.DB.Where("user_id = ? AND column_id = ?", userPayload.ID, req.ColumnID).
+		Delete(&model.PunchDraft{}).Error; err != nil {
+		log.Warn("删除打卡草稿失败", "error", err, "user_id", userPayload.ID, "column_id", req.ColumnID)
+	}
+	return punch, nil
+}
 
-	response.Success(c, punch)
+// checkPunchAllowed 校验用户可以在栏目中打卡：活动已发布、用户已加入活动且在面向人群内。
+// column 需预加载 Project.Activity，返回的错误可直接交给 response.Fail
+func checkPunchAllowed(userPayload *jwt.Claims, column *model.Column) error {
+	// 只有已发布的活动可以打卡，草稿、定时发布与已归档的活动均不能打卡
+	if column.Project.Activity.Status != model.ActivityPublished {
+		return response.ErrForbidden.WithTips("活动未发布或已归档，无法打卡")
+	}
+
+	// 只有已加入活动的用户可以打卡
+	var joined int64
+	if err := database.DB.Model(&model.Participant{}).
+		Where("activity_id = ? AND user_id = ? AND status = ?", column.Project.ActivityID, userPayload.ID, model.ParticipantJoined).
+		Count(&joined).Error; err != nil {
+		return response.ErrDatabase.WithOrigin(err)
+	}
+	if joined == 0 {
+		return response.ErrForbidden.WithTips("尚未加入该活动，无法打卡")
+	}
+	// 加入后活动或项目的面向人群规则可能调整，打卡时按当前规则再次校验
+	viewer, err := model.AudienceViewer(database.DB, userPayload.ID, userPayload.RoleID)
+	if err != nil {
+		return response.ErrDatabase.WithOrigin(err)
+	}
+	if visible, err := model.ProjectVisible(database.DB, &column.Project.Activity, &column.Project, viewer); err != nil {
+		return response.ErrDatabase.WithOrigin(err)
+	} else if !visible {
+		return response.ErrForbidden.WithTips("不在该活动的面向人群内，无法打卡")
+	}
+	return nil
 }
 
 type ReviewReq struct {
@@ -241,7 +302,7 @@ type reviewRes struct {
 
 // getDayPointsForActivity 获取用户在指定日期在活动中已获得的积分（排除不计入上限的项目和特殊栏目）
 func getDayPointsForActivity(db *gorm.DB, userID uint, activityID uint, dayStart time.Time) (uint, error) {
-	dayEnd := dayStart.Add(24 * time.Hour)
+	dayEnd := dayStart.AddDate(0, 0, 1)
 	var totalPoints uint
 
 	// 查询指定日期获得的积分，排除 exempt_from_limit = true 的项目和 optional = true 的特殊栏目
@@ -258,7 +319,7 @@ func getDayPointsForActivity(db *gorm.DB, userID uint, activityID uint, dayStart
 
 // checkProjectCompletion 检查用户在指定日期是否完成了项目下所有必需栏目的打卡（排除特殊栏目）
 func checkProjectCompletion(db *gorm.DB, userID uint, projectID uint, dayStart time.Time) (bool, error) {
-	dayEnd := dayStart.Add(24 * time.Hour)
+	dayEnd := dayStart.AddDate(0, 0, 1)
 
 	// 获取项目下所有必需栏目数量（排除 optional = true 的特殊栏目）
 	var totalColumns int64
@@ -286,7 +347,7 @@ func checkProjectCompletion(db *gorm.DB, userID uint, projectID uint, dayStart t
 
 // checkActivityCompletion 检查用户在指定日期是否完成了活动下所有必需栏目的打卡（排除特殊栏目）
 func checkActivityCompletion(db *gorm.DB, userID uint, activityID uint, dayStart time.Time) (bool, error) {
-	dayEnd := dayStart.Add(24 * time.Hour)
+	dayEnd := dayStart.AddDate(0, 0, 1)
 
 	// 获取活动下所有必需栏目数量（通过项目关联，排除 optional = true 的特殊栏目）
 	var totalColumns int64
@@ -319,7 +380,7 @@ func checkActivityCompletion(db *gorm.DB, userID uint, activityID uint, dayStart
 // hasReceivedProjectCompletionBonus 检查用户在指定日期是否已领取过项目完成奖励
 // 使用 punch_date 字段判断，该字段记录的是打卡日期而非积分记录创建日期
 func hasReceivedProjectCompletionBonus(db *gorm.DB, userID uint, projectID uint, dayStart time.Time) (bool, error) {
-	dayEnd := dayStart.Add(24 * time.Hour)
+	dayEnd := dayStart.AddDate(0, 0, 1)
 	var count int64
 
 	err := db.Model(&model.Score{}).
@@ -333,7 +394,7 @@ func hasReceivedProjectCompletionBonus(db *gorm.DB, userID uint, projectID uint,
 // hasReceivedActivityCompletionBonus 检查用户在指定日期是否已领取过活动完成奖励
 // 使用 punch_date 字段判断，该字段记录的是打卡日期而非积分记录创建日期
 func hasReceivedActivityCompletionBonus(db *gorm.DB, userID uint, activityID uint, dayStart time.Time) (bool, error) {
-	dayEnd := dayStart.Add(24 * time.Hour)
+	dayEnd := dayStart.AddDate(0, 0, 1)
 	var count int64
 
 	err := db.Model(&model.Score{}).
@@ -358,12 +419,6 @@ func ReviewPunch(c *gin.Context) {
 		return
 	}
 
-	// 只允许管理员或有权限的用户审核
-	if userPayload.RoleID < 1 { // 假设1为审核权限
-		response.Fail(c, response.ErrForbidden)
-		return
-	}
-
 	// 获取打卡ID和审核状态
 	var req ReviewReq
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -372,6 +427,40 @@ func ReviewPunch(c *gin.Context) {
 		return
 	}
 
+	// 只允许打卡所属活动的审核员及以上成员审核
+	var target model.Punch
+	if err := database.DB.Select("column_id").First(&target, req.PunchID).Error; err != nil {
+		response.Fail(c, response.ErrNotFound.WithTips("打卡记录不存在"))
+		return
+	}
+	activityID, err := columnActivityID(uint(target.ColumnID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, response.ErrNotFound.WithTips("栏目不存在"))
+		return
+	}
+	if err != nil {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	role, err := model.MemberRole(database.DB, activityID, userPayload.StudentID)
+	if err != nil {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	if role < model.MemberReviewer {
+		response.Fail(c, response.ErrForbidden.WithTips("无权限审核该活动的打卡"))
+		return
+	}
+	// 已归档的活动不能再修改审核结果
+	if err := model.CheckActivityWritable(database.DB, activityID); err != nil {
+		if errors.Is(err, model.ErrActivityArchived) {
+			response.Fail(c, response.ErrForbidden.WithTips(err.Error()))
+			return
+		}
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+
 	// 验证status值是否有效
 	if req.Status < 0 || req.Status > 2 {
 		response.Fail(c, response.ErrInvalidRequest.WithTips("状态值无效，只能为0(待审核)、1(通过)、2(拒绝)"))
@@ -386,19 +475,30 @@ func ReviewPunch(c *gin.Context) {
 	reviewTxnErr := errors.New("review_txn_failed")
 	var reviewErrMsg string
 
-	err := database.DB.Transaction(func(txBase *gorm.DB) error {
-		// 查找打卡记录
+	err = database.DB.Transaction(func(txBase *gorm.DB) error {
+		// 查找并锁定打卡记录，与 UpdatePunch 的修改依次执行
 		var punch model.Punch
-		if err := txBase.First(&punch, req.PunchID).Error; err != nil {
+		if err := txBase.Clauses(clause.Locking{Strength: "UPDATE"}).First(&punch, req.PunchID).Error; err != nil {
 			return err
 		}
+		// 权限按原栏目校验，期间打卡被移到其他栏目时需重新审核
+		if punch.ColumnID != target.ColumnID {
+			reviewErrMsg = "审核失败 打卡记录已被修改，请刷新后重试"
+			return reviewTxnErr
+		}
 
 		// 记录原状态，用于判断是否需要扣分
 		originalStatus := punch.Status
 
-		// 更新审核状态
+		// 只更新审核状态与审核时间，不覆盖打卡内容
 		punch.Status = req.Status
-		if err := txBase.Save(&punch).Error; err != nil {
+		if req.Status == 0 {
+			punch.ReviewedAt = nil
+		} else {
+			reviewedAt := time.Now()
+			punch.ReviewedAt = &reviewedAt
+		}
+		if err := txBase.Model(&punch).Select("status", "reviewed_at").Updates(&punch).Error; err != nil {
 			return err
 		}
 
@@ -437,8 +537,8 @@ func ReviewPunch(c *gin.Context) {
 			UserID:     punch.UserID, // 使用打卡者的ID，而非审核者的ID
 		}))
 
-		// 获取打卡当天的零点时间（基于打卡创建时间，而非审核时间）
-		punchDayStart := getDayStart(punch.CreatedAt)
+		// 获取打卡所属那一天的开始时刻（基于打卡创建时间，而非审核时间，按活动的日界规则划分）
+		punchDayStart := activity.Day().Start(punch.CreatedAt)
 
 		// 辅助函数：检查每日积分上限并发放积分
 		awardScore := func(scoreToAward int, cause string) (bool, string) {
@@ -707,8 +807,14 @@ func GetPunchesByColumn(c *gin.Context) {
 		return
 	}
 	todayPunchCount := 0
-	// 今日是否已打卡（使用北京时间）
-	today := getTodayStart()
+	// 今日是否已打卡（按活动的时区与切换时刻）
+	columnID, _ := strconv.ParseUint(columnIDStr, 10, 0)
+	day, err := model.ColumnDay(database.DB, uint(columnID))
+	if err != nil {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	today := day.Today()
 	hasPunchedToday := false
 	for _, punch := range punches {
 		if punch.CreatedAt.After(today) || punch.CreatedAt.Equal(today) {
@@ -719,13 +825,14 @@ func GetPunchesByColumn(c *gin.Context) {
 
 	// 查询每条打卡记录的图片
 
+	store := linkStore(c.Request.Context())
 	var result []PunchWithImgs
 	for _, punch := range punches {
 		var imgs []model.PunchImg
 		database.DB.Where("punch_id = ?", punch.ID).Find(&imgs)
 		imgUrls := make([]string, 0, len(imgs))
 		for _, img := range imgs {
-			imgUrls = append(imgUrls, img.ImgURL)
+			imgUrls = append(imgUrls, pictureBed.ReadURL(c.Request.Context(), store, img.Thumb())) // 列表返回缩略图
 		}
 		result = append(result, PunchWithImgs{
 			Punch: punch,
@@ -733,11 +840,10 @@ func GetPunchesByColumn(c *gin.Context) {
 		})
 	}
 
-	loc, _ := time.LoadLocation("Asia/Shanghai")
-	now := time.Now().In(loc)
+	now := day.Now()
 	// 查询该栏目下不同 user_id 数量
 	var userCount int64
-	err := database.DB.Model(&model.Punch{}).
+	err = database.DB.Model(&model.Punch{}).
 		Where("column_id = ?", columnIDStr).
 		Where("created_at >= ? AND created_at <= ?", today, now).
 		Distinct("user_id").
@@ -790,29 +896,39 @@ func DeletePunch(c *gin.Context) {
 		return
 	}
 
+	// 已归档活动中的打卡为只读
+	if err := checkColumnWritable(uint(punch.ColumnID)); errors.Is(err, model.ErrActivityArchived) {
+		response.Fail(c, response.ErrForbidden.WithTips(err.Error()))
+		return
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+
 	var column model.Column
 	if err := database.DB.First(&column, "id = ?", punch.ColumnID).Error; err != nil {
 		response.Fail(c, response.ErrNotFound.WithTips("栏目不存在"))
 		return
 	}
 
-	// 判断打卡时间是否在栏目时间范围内（使用本地时区）
-	startDateStr := strconv.FormatInt(column.StartDate, 10)
-	endDateStr := strconv.FormatInt(column.EndDate, 10)
-	loc := time.Local
-	startDate, _ := time.ParseInLocation("20060102", startDateStr, loc)
-	endDate, _ := time.ParseInLocation("20060102", endDateStr, loc)
+	// 判断打卡时间是否在栏目时间范围内（使用活动的时区与切换时刻）
+	day, err := model.ColumnDay(database.DB, uint(punch.ColumnID))
+	if err != nil {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	startDate, _ := day.ParseDate("20060102", strconv.FormatInt(column.StartDate, 10))
+	endDate, _ := day.ParseDate("20060102", strconv.FormatInt(column.EndDate, 10))
 	// 构建完整的结束时间点
 	var punchEndTime time.Time
 	if column.EndTime != "" {
 		parsedTime, _ := time.Parse("15:04", column.EndTime)
 		punchEndTime = time.Date(endDate.Year(), endDate.Month(), endDate.Day(),
-			parsedTime.Hour(), parsedTime.Minute(), 59, 0, loc)
+			parsedTime.Hour(), parsedTime.Minute(), 59, 0, day.Location)
 	} else {
-		punchEndTime = time.Date(endDate.Year(), endDate.Month(), endDate.Day(),
-			23, 59, 59, 0, loc)
+		punchEndTime = day.StartOf(endDate.AddDate(0, 0, 1)).Add(-time.Second)
 	}
-	if punch.CreatedAt.Before(startDate) || punch.CreatedAt.After(punchEndTime) {
+	if punch.CreatedAt.Before(day.StartOf(startDate)) || punch.CreatedAt.After(punchEndTime) {
 		response.Fail(c, response.ErrInvalidRequest.WithTips("打卡时间不在栏目时间范围内，无法删除"))
 		return
 	}
@@ -830,8 +946,13 @@ type PunchUpdateRequest struct {
 	ColumnID int      `json:"column_id" binding:"required"`
 	Content  string   `json:"content" binding:"required,max=500"`
 	Images   []string `json:"images" binding:"omitempty,max=9"`
+	// 音频、视频与文档附件，传入时整体替换原附件，传空数组表示删除全部附件
+	Attachments []AttachmentReq `json:"attachments" binding:"omitempty,max=20,dive"`
 }
 
+// errPunchReviewed 修改过程中打卡已被审核
+var errPunchReviewed = errors.New("已审核的打卡记录不允许修改")
+
 // UpdatePunch 修改打卡记录
 func UpdatePunch(c *gin.Context) {
 	idStr := c.Param("id")
@@ -867,28 +988,48 @@ func UpdatePunch(c *gin.Context) {
 		return
 	}
 
-	// 只允许在打卡创建当天修改，跨天则拒绝
-	now := time.Now().In(beijingLocation)
-	if getDayStart(now) != getDayStart(punch.CreatedAt) {
+	// 已归档活动中的打卡为只读
+	if err := checkColumnWritable(uint(punch.ColumnID)); errors.Is(err, model.ErrActivityArchived) {
+		response.Fail(c, response.ErrForbidden.WithTips(err.Error()))
+		return
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+
+	// 只允许在打卡所属的当天修改，跨天则拒绝（按活动的时区与切换时刻）
+	day, err := model.ColumnDay(database.DB, uint(punch.ColumnID))
+	if err != nil {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	now := day.Now()
+	if !day.Start(now).Equal(day.Start(punch.CreatedAt)) {
 		response.Fail(c, response.ErrInvalidRequest.WithTips("只能在打卡当天修改，已超过可修改时间"))
 		return
 	}
 
 	var column model.Column
-	if err := database.DB.First(&column, "id = ?", req.ColumnID).Error; err != nil {
+	if err := database.DB.Preload("Project").Preload("Project.Activity").First(&column, "id = ?", req.ColumnID).Error; err != nil {
 		response.Fail(c, response.ErrNotFound.WithTips("栏目不存在"))
 		return
 	}
+	// 目标栏目与新打卡一样校验活动状态、参与资格与面向人群，避免将打卡移入未加入或不可见的活动
+	if err := checkPunchAllowed(userPayload, &column); err != nil {
+		response.Fail(c, err)
+		return
+	}
 
-	// 修改打卡视同正常打卡，检查当前时间是否在栏目日期范围内
-	startDateStr := strconv.FormatInt(column.StartDate, 10)
-	endDateStr := strconv.FormatInt(column.EndDate, 10)
-	startDate, _ := time.ParseInLocation("20060102", startDateStr, beijingLocation)
-	endDate, _ := time.ParseInLocation("20060102", endDateStr, beijingLocation)
-	// endDate 需要加一天再减一秒，表示当天的最后一刻
-	endDate = endDate.Add(24*time.Hour - time.Second)
-
-	if now.Before(startDate) || now.After(endDate) {
+	// 修改打卡视同正常打卡，检查当前时间是否在目标栏目的日期范围内
+	if req.ColumnID != punch.ColumnID {
+		if day, err = model.ColumnDay(database.DB, uint(req.ColumnID)); err != nil {
+			response.Fail(c, response.ErrDatabase.WithOrigin(err))
+			return
+		}
+		now = day.Now()
+	}
+	startDate, endDate, valid := day.DateRange(column.StartDate, column.EndDate)
+	if !valid || now.Before(startDate) || !now.Before(endDate) {
 		response.Fail(c, response.ErrInvalidRequest.WithTips("当前时间不在栏目日期范围内，无法修改打卡"))
 		return
 	}
@@ -920,35 +1061,104 @@ func UpdatePunch(c *gin.Context) {
 		}
 	}
 
-	// 修改打卡内容，并更新打卡时间为当前时间
-	punch.Content = req.Content
-	punch.ColumnID = req.ColumnID
-	punch.CreatedAt = now // 修改打卡视同重新打卡，更新打卡时间
-	if err := database.DB.Save(&punch).Error; err != nil {
-		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+	// 新增的图片与附件须为本人上传且已上传成功，原有的保持不变
+	images, err := verifyImages(c.Request.Context(), userPayload.ID, punch.ID, req.Images, column.Attachments)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	attachments, err := verifyAttachments(c.Request.Context(), userPayload.ID, punch.ID, req.Attachments, column.Attachments)
+	if err != nil {
+		response.Fail(c, err)
 		return
 	}
 
-	// 可选：处理图片（如需覆盖原图片，可先删除原图片再插入新图片）
-	if len(req.Images) > 0 {
-		// 删除原图片
-		database.DB.Where("punch_id = ?", punch.ID).Delete(&model.PunchImg{})
-		for _, imgUrl := range req.Images {
-			punchImg := &model.PunchImg{
-				PunchID:  punch.ID,
-				ColumnID: req.ColumnID,
-				ImgURL:   imgUrl,
+	// 修改、图片与附件的替换及新版本记录在同一事务中完成，锁定打卡行使并发修改依次执行，版本号不会冲突
+	var created []model.PunchImg
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&punch, "id = ?", punch.ID).Error; err != nil {
+			return err
+		}
+		if punch.Status != 0 {
+			return errPunchReviewed
+		}
+		// 修改前保留原内容，历史打卡在此补记第 1 版
+		if err := ensureBaseRevision(tx, &punch); err != nil {
+			return err
+		}
+
+		// 修改打卡内容，并更新打卡时间为当前时间
+		punch.Content = req.Content
+		punch.ColumnID = req.ColumnID
+		punch.CreatedAt = now // 修改打卡视同重新打卡，更新打卡时间
+		if err := tx.Save(&punch).Error; err != nil {
+			return err
+		}
+
+		// 可选：处理图片（如需覆盖原图片，可先删除原图片再插入新图片）
+		if len(req.Images) > 0 {
+			// 保留的原图片沿用已生成的缩略图，避免重复压缩
+			var oldImgs []model.PunchImg
+			if err := tx.Where("punch_id = ?", punch.ID).Find(&oldImgs).Error; err != nil {
+				return err
+			}
+			processed := make(map[string]model.PunchImg, len(oldImgs))
+			for _, img := range oldImgs {
+				processed[img.ImgURL] = img
+			}
+			// 删除原图片
+			if err := tx.Where("punch_id = ?", punch.ID).Delete(&model.PunchImg{}).Error; err != nil {
+				return err
+			}
+			for _, imgUrl := range images {
+				punchImg := model.PunchImg{
+					PunchID:  punch.ID,
+					ColumnID: req.ColumnID,
+					ImgURL:   imgUrl,
+				}
+				// 正在处理的原图片按新图片重新处理，旧记录删除后处理结果无法写回
+				if old, ok := processed[imgUrl]; ok && old.Process != model.PunchImgProcessing {
+					punchImg.ThumbURL, punchImg.MediumURL, punchImg.Process = old.ThumbURL, old.MediumURL, old.Process
+				}
+				if err := tx.Create(&punchImg).Error; err != nil {
+					return err
+				}
+				if punchImg.Process == model.PunchImgPending {
+					created = append(created, punchImg)
+				}
+			}
+		}
+		if req.Attachments != nil {
+			if err := tx.Where("punch_id = ?", punch.ID).Delete(&model.PunchAttachment{}).Error; err != nil {
+				return err
+			}
+			for i := range attachments {
+				attachments[i].PunchID, attachments[i].ColumnID = punch.ID, req.ColumnID
+				if err := tx.Create(&attachments[i]).Error; err != nil {
+					return err
+				}
 			}
-			database.DB.Create(punchImg)
 		}
+		return saveRevision(tx, &punch)
+	})
+	if errors.Is(err, errPunchReviewed) {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("已审核的打卡记录不允许修改"))
+		return
+	}
+	if err != nil {
+		log.Error("修改打卡失败", "error", err, "punch_id", punch.ID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
 	}
+	processImages(created)
 
 	// 查询图片数组
 	var imgs []model.PunchImg
 	database.DB.Where("punch_id = ?", punch.ID).Find(&imgs)
+	store := linkStore(c.Request.Context())
 	imgUrls := make([]string, 0, len(imgs))
 	for _, img := range imgs {
-		imgUrls = append(imgUrls, img.ImgURL)
+		imgUrls = append(imgUrls, pictureBed.ReadURL(c.Request.Context(), store, img.ImgURL))
 	}
 
 	response.Success(c, struct {
@@ -962,10 +1172,11 @@ func UpdatePunch(c *gin.Context) {
 
 // 获取待审核打卡列表
 type PunchWithImgsAndUser struct {
-	Punch    model.Punch `json:"punch"`
-	Imgs     []string    `json:"imgs"`
-	NickName string      `json:"nick_name"`
-	Stared   bool        `json:"stared"`
+	Punch       model.Punch             `json:"punch"`
+	Imgs        []string                `json:"imgs"`
+	Attachments []model.PunchAttachment `json:"attachments"` // 附件，带预签名下载链接
+	NickName    string                  `json:"nick_name"`
+	Stared      bool                    `json:"stared"`
 }
 
 func GetPendingPunchList(c *gin.Context) {
@@ -980,15 +1191,11 @@ func GetPendingPunchList(c *gin.Context) {
 		response.Fail(c, response.ErrUnauthorized)
 		return
 	}
-	// 只允许管理员或有权限的用户查看
-	if userPayload.RoleID < 1 { // 假设1为审核权限
-		response.Fail(c, response.ErrForbidden)
-		return
-	}
-
 	columnIDStr := c.Query("column_id")
 	var punches []model.Punch
-	query := database.DB.Where("status = 0")
+	// 只返回本人担任审核员及以上的活动中的打卡
+	query := database.DB.Where("status = 0").
+		Where("column_id IN (?)", model.MemberColumnIDs(database.DB, userPayload.StudentID, model.MemberReviewer))
 	if columnIDStr != "" {
 		query = query.Where("column_id = ?", columnIDStr)
 	}
@@ -997,13 +1204,15 @@ func GetPendingPunchList(c *gin.Context) {
 		return
 	}
 
+	// 审核人员通过预签名链接下载附件
+	store := linkStore(c.Request.Context())
 	var result []PunchWithImgsAndUser
 	for _, punch := range punches {
 		var imgs []model.PunchImg
 		database.DB.Where("punch_id = ?", punch.ID).Find(&imgs)
 		imgUrls := make([]string, 0, len(imgs))
 		for _, img := range imgs {
-			imgUrls = append(imgUrls, img.ImgURL)
+			imgUrls = append(imgUrls, pictureBed.ReadURL(c.Request.Context(), store, img.Thumb())) // 列表返回缩略图
 		}
 
 		var user model.User
@@ -1015,10 +1224,11 @@ func GetPendingPunchList(c *gin.Context) {
 		stared := starCount > 0
 
 		result = append(result, PunchWithImgsAndUser{
-			Punch:    punch,
-			Imgs:     imgUrls,
-			NickName: user.NickName,
-			Stared:   stared,
+			Punch:       punch,
+			Imgs:        imgUrls,
+			Attachments: loadAttachments(c.Request.Context(), store, punch.ID),
+			NickName:    user.NickName,
+			Stared:      stared,
 		})
 	}
 	response.Success(c, struct {
@@ -1049,20 +1259,22 @@ func GetMyPunchList(c *gin.Context) {
 	}
 
 	type MyPunchWithInfo struct {
-		Punch        model.Punch `json:"punch"`
-		Imgs         []string    `json:"imgs"`
-		ColumnName   string      `json:"column_name"`
-		ProjectName  string      `json:"project_name"`
-		ActivityName string      `json:"activity_name"`
+		Punch        model.Punch             `json:"punch"`
+		Imgs         []string                `json:"imgs"`
+		Attachments  []model.PunchAttachment `json:"attachments"`
+		ColumnName   string                  `json:"column_name"`
+		ProjectName  string                  `json:"project_name"`
+		ActivityName string                  `json:"activity_name"`
 	}
 
+	store := linkStore(c.Request.Context())
 	var result []MyPunchWithInfo
 	for _, punch := range punches {
 		var imgs []model.PunchImg
 		database.DB.Where("punch_id = ?", punch.ID).Find(&imgs)
 		imgUrls := make([]string, 0, len(imgs))
 		for _, img := range imgs {
-			imgUrls = append(imgUrls, img.ImgURL)
+			imgUrls = append(imgUrls, pictureBed.ReadURL(c.Request.Context(), store, img.Thumb())) // 列表返回缩略图
 		}
 		var col model.Column
 		var colName, projName, actName string
@@ -1084,6 +1296,7 @@ func GetMyPunchList(c *gin.Context) {
 		result = append(result, MyPunchWithInfo{
 			Punch:        punch,
 			Imgs:         imgUrls,
+			Attachments:  loadAttachments(c.Request.Context(), store, punch.ID),
 			ColumnName:   colName,
 			ProjectName:  projName,
 			ActivityName: actName,
@@ -1115,13 +1328,14 @@ func GetRecentParticipation(c *gin.Context) {
 	var recentActivities []model.Activity
 	var punchResults []PunchWithImgs
 
+	store := linkStore(c.Request.Context())
 	for _, punch := range punches {
 		// 查图片
 		var imgs []model.PunchImg
 		database.DB.Where("punch_id = ?", punch.ID).Find(&imgs)
 		imgUrls := make([]string, 0, len(imgs))
 		for _, img := range imgs {
-			imgUrls = append(imgUrls, img.ImgURL)
+			imgUrls = append(imgUrls, pictureBed.ReadURL(c.Request.Context(), store, img.Thumb())) // 列表返回缩略图
 		}
 		punchResults = append(punchResults, PunchWithImgs{
 			Punch: punch,
@@ -1167,8 +1381,14 @@ func GetTodayPunchCount(c *gin.Context) {
 		response.Fail(c, response.ErrInvalidRequest.WithTips("栏目ID不能为空"))
 		return
 	}
+	columnID, _ := strconv.ParseUint(columnId, 10, 0)
+	day, err := model.ColumnDay(database.DB, uint(columnID))
+	if err != nil {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
 	var count int64
-	today := getTodayStart() // 北京时间今日零点
+	today := day.Today() // 按活动的时区与切换时刻划分的今天
 	if err := database.DB.Model(&model.Punch{}).Where("column_id = ? AND created_at >= ?", columnId, today).Count(&count).Error; err != nil {
 		response.Fail(c, response.ErrDatabase.WithOrigin(err))
 		return
@@ -1178,8 +1398,8 @@ func GetTodayPunchCount(c *gin.Context) {
 
 type PunchWithColumn struct {
 	model.Punch
-	JoinColumnID  sql.NullInt64 `gorm:"column:join_column_id"`  // 用于判断栏目是否存在
-	ColumnOwnerID sql.NullInt64 `gorm:"column:column_owner_id"` // 用于权限判断
+	JoinColumnID sql.NullInt64 `gorm:"column:join_column_id"` // 用于判断栏目是否存在
+	ActivityID   sql.NullInt64 `gorm:"column:activity_id"`    // 栏目所属活动，用于权限判断
 }
 
 func GetPunchDetail(c *gin.Context) {
@@ -1204,8 +1424,9 @@ func GetPunchDetail(c *gin.Context) {
 	var pc PunchWithColumn
 	err := database.DB.
 		Table("punch AS p").
-		Select("p.*, c.id AS join_column_id, c.owner_id AS column_owner_id").
+		Select("p.*, c.id AS join_column_id, pr.activity_id AS activity_id").
 		Joins("LEFT JOIN `column` c ON c.id = p.column_id").
+		Joins("LEFT JOIN project pr ON pr.id = c.project_id").
 		Where("p.id = ?", punchID).
 		Take(&pc).Error
 
@@ -1225,21 +1446,25 @@ func GetPunchDetail(c *gin.Context) {
 		return
 	}
 
-	// 权限判断：本人、管理员(RoleID>=1)、栏目所有者 三者之一即可
-	isOwner := pc.UserID == studentID
-	isAdmin := userPayload.RoleID >= 1
-	isColumnOwner := pc.ColumnOwnerID.Valid && uint(pc.ColumnOwnerID.Int64) == studentID
-
-	if !(isOwner || isAdmin || isColumnOwner) {
+	// 权限判断：本人或栏目所属活动的成员（审核员及以上）
+	viewable, err := model.CanViewPunch(database.DB, uint(pc.ActivityID.Int64), pc.UserID, studentID, userPayload.StudentID)
+	if err != nil {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	if !viewable {
 		response.Fail(c, response.ErrForbidden)
 		return
 	}
 
 	var imgs []model.PunchImg
 	database.DB.Where("punch_id = ?", punchID).Find(&imgs)
+	store := linkStore(c.Request.Context())
 	imgUrls := make([]string, 0, len(imgs))
+	mediumUrls := make([]string, 0, len(imgs))
 	for _, img := range imgs {
-		imgUrls = append(imgUrls, img.ImgURL)
+		imgUrls = append(imgUrls, pictureBed.ReadURL(c.Request.Context(), store, img.ImgURL))
+		mediumUrls = append(mediumUrls, pictureBed.ReadURL(c.Request.Context(), store, img.Medium()))
 	}
 
 	var stars []model.Star
@@ -1250,10 +1475,20 @@ func GetPunchDetail(c *gin.Context) {
 		stared = true
 	}
 
+	// 最新版本相对上一版本的修改，供审核比对
+	diff, version, err := latestDiff(c.Request.Context(), store, pc.Punch.ID)
+	if err != nil {
+		log.Error("查询打卡版本失败", "error", err, "punch_id", pc.Punch.ID)
+	}
+
 	response.Success(c, gin.H{
-		"punch":  pc.Punch,
-		"stared": stared,
-		"imgs":   imgUrls,
+		"punch":       pc.Punch,
+		"stared":      stared,
+		"imgs":        imgUrls,    // 原图
+		"medium_imgs": mediumUrls, // 中图，与 imgs 一一对应
+		"attachments": loadAttachments(c.Request.Context(), store, pc.Punch.ID),
+		"version":     version, // 当前版本号，0 表示没有版本记录
+		"diff":        diff,    // 相对上一版本的差异，未修改过时为 null
 	})
 }
 
@@ -1270,18 +1505,15 @@ func GetReviewedPunchList(c *gin.Context) {
 		response.Fail(c, response.ErrUnauthorized)
 		return
 	}
-	// 只允许管理员或有权限的用户查看
-	if userPayload.RoleID < 1 {
-		response.Fail(c, response.ErrForbidden)
-		return
-	}
 
 	// 获取查询参数
 	columnIDStr := c.Query("column_id")
 	statusStr := c.Query("status") // 可选参数：1-通过, 2-拒绝
 
 	// 构建查询
-	query := database.DB.Where("status != 0") // 排除待审核
+	// 排除待审核，只返回本人担任审核员及以上的活动中的打卡
+	query := database.DB.Where("status != 0").
+		Where("column_id IN (?)", model.MemberColumnIDs(database.DB, userPayload.StudentID, model.MemberReviewer))
 	if columnIDStr != "" {
 		query = query.Where("column_id = ?", columnIDStr)
 	}
@@ -1293,9 +1525,16 @@ func GetReviewedPunchList(c *gin.Context) {
 	}
 	dateStr := c.Query("date")
 	if dateStr != "" {
-		date, err := time.ParseInLocation("2006-01-02", dateStr, beijingLocation)
+		// 指定栏目时按其活动的日界规则划分日期，否则使用默认规则
+		day := clock.Default()
+		if columnID, err := strconv.ParseUint(columnIDStr, 10, 0); err == nil {
+			if d, err := model.ColumnDay(database.DB, uint(columnID)); err == nil {
+				day = d
+			}
+		}
+		date, err := day.ParseDate("2006-01-02", dateStr)
 		if err == nil {
-			query = query.Where("created_at >= ? AND created_at < ?", date, date.AddDate(0, 0, 1))
+			query = query.Where("created_at >= ? AND created_at < ?", day.StartOf(date), day.StartOf(date.AddDate(0, 0, 1)))
 		}
 	}
 	// 查询打卡记录
@@ -1306,6 +1545,8 @@ func GetReviewedPunchList(c *gin.Context) {
 	}
 
 	// 组装返回数据
+	// 审核人员通过预签名链接下载附件
+	store := linkStore(c.Request.Context())
 	var result []PunchWithImgsAndUser
 	for _, punch := range punches {
 		// 查询打卡图片
@@ -1313,7 +1554,7 @@ func GetReviewedPunchList(c *gin.Context) {
 		database.DB.Where("punch_id = ?", punch.ID).Find(&imgs)
 		imgUrls := make([]string, 0, len(imgs))
 		for _, img := range imgs {
-			imgUrls = append(imgUrls, img.ImgURL)
+			imgUrls = append(imgUrls, pictureBed.ReadURL(c.Request.Context(), store, img.Thumb())) // 列表返回缩略图
 		}
 
 		// 查询用户昵称
@@ -1329,10 +1570,11 @@ func GetReviewedPunchList(c *gin.Context) {
 			return
 		}
 		result = append(result, PunchWithImgsAndUser{
-			Punch:    punch,
-			Imgs:     imgUrls,
-			NickName: user.NickName,
-			Stared:   exist,
+			Punch:       punch,
+			Imgs:        imgUrls,
+			Attachments: loadAttachments(c.Request.Context(), store, punch.ID),
+			NickName:    user.NickName,
+			Stared:      exist,
 		})
 	}
 
@@ -1360,7 +1602,7 @@ func GetPresignedUploadURL(c *gin.Context) {
 		response.Fail(c, response.ErrUnauthorized)
 		return
 	}
-	_, ok := payload.(*jwt.Claims)
+	userPayload, ok := payload.(*jwt.Claims)
 	if !ok {
 		response.Fail(c, response.ErrUnauthorized)
 		return
@@ -1373,13 +1615,17 @@ func GetPresignedUploadURL(c *gin.Context) {
 		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
 		return
 	}
+	// 只签发允许的图片与附件类型，签名中固定 Content-Type
+	contentType := normalizeContentType(req.ContentType, req.Filename)
+	if attachmentKind(contentType) == "" {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("不支持的文件格式"))
+		return
+	}
 
-	// 创建图片床实例
-	pb := pictureBed.NewPictureBed(config.Get().S3.Endpoint, "")
-
-	// 初始化 S3 客户端
-	if err := pb.InitS3(c.Request.Context()); err != nil {
-		log.Error("初始化 S3 客户端失败", "error", err)
+	// 创建对象存储实例
+	store, err := pictureBed.New(c.Request.Context())
+	if err != nil {
+		log.Error("初始化对象存储失败", "error", err)
 		response.Fail(c, response.ErrServerInternal.WithTips("初始化存储服务失败"))
 		return
 	}
@@ -1387,16 +1633,28 @@ func GetPresignedUploadURL(c *gin.Context) {
 	// 生成预签名上传 URL
 	presignedReq := pictureBed.PresignedUploadRequest{
 		Filename:    req.Filename,
-		ContentType: req.ContentType,
+		ContentType: contentType,
 		ExpiresIn:   120, // 2 分钟
 	}
 
-	presignedResp, err := pb.GeneratePresignedUploadURL(c.Request.Context(), presignedReq)
+	presignedResp, err := store.GeneratePresignedUploadURL(c.Request.Context(), presignedReq)
 	if err != nil {
 		log.Error("生成预签名上传 URL 失败", "error", err)
 		response.Fail(c, response.ErrServerInternal.WithTips("生成上传链接失败"))
 		return
 	}
 
+	// 记录签发的 key，提交打卡时校验图片归属
+	if err := database.DB.Create(&model.UploadKey{
+		ObjectKey:   presignedResp.FileKey,
+		UserID:      userPayload.ID,
+		ContentType: contentType,
+		ExpiresAt:   presignedResp.ExpiresAt,
+	}).Error; err != nil {
+		log.Error("记录上传 key 失败", "error", err, "key", presignedResp.FileKey)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+
 	response.Success(c, presignedResp)
 }
@@ -0,0 +1,261 @@
+package punch
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/pictureBed"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxDiffCells 内容逐字比对的规模上限（去除相同首尾后两版本字数之积），超过时整段视为删除后插入。
+// 比对表每格 4 字节，单次比对最多占用约 4MB
+const maxDiffCells = 1 << 20
+
+// 内容差异片段的类型
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// DiffSegment 内容差异片段
+type DiffSegment struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// RevisionDiff 打卡相邻两个版本的差异，图片与附件按引用比对
+type RevisionDiff struct {
+	From               int           `json:"from"` // 旧版本号
+	To                 int           `json:"to"`   // 新版本号
+	ColumnChanged      bool          `json:"column_changed"`
+	FromColumnID       int           `json:"from_column_id"`
+	ToColumnID         int           `json:"to_column_id"`
+	ContentChanged     bool          `json:"content_changed"`
+	Content            []DiffSegment `json:"content"` // 旧内容到新内容的逐字差异
+	AddedImages        []string      `json:"added_images"`
+	RemovedImages      []string      `json:"removed_images"`
+	AddedAttachments   []string      `json:"added_attachments"`
+	RemovedAttachments []string      `json:"removed_attachments"`
+	FromPunchedAt      time.Time     `json:"from_punched_at"`
+	ToPunchedAt        time.Time     `json:"to_punched_at"`
+}
+
+// saveRevision 以打卡当前的栏目、内容、图片与附件保存一个新版本
+func saveRevision(db *gorm.DB, punch *model.Punch) error {
+	rev := model.PunchRevision{
+		PunchID:   punch.ID,
+		ColumnID:  punch.ColumnID,
+		Content:   punch.Content,
+		PunchedAt: punch.CreatedAt,
+	}
+	if err := db.Model(&model.PunchImg{}).Where("punch_id = ?", punch.ID).Order("id").
+		Pluck("img_url", &rev.Images).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&model.PunchAttachment{}).Where("punch_id = ?", punch.ID).Order("id").
+		Pluck("url", &rev.Attachments).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&model.PunchRevision{}).Where("punch_id = ?", punch.ID).
+		Select("COALESCE(MAX(version), 0)").Scan(&rev.Version).Error; err != nil {
+		return err
+	}
+	rev.Version++
+	return db.Create(&rev).Error
+}
+
+// ensureBaseRevision 功能上线前提交的打卡没有版本记录，修改前先将原内容保存为第 1 版
+func ensureBaseRevision(db *gorm.DB, punch *model.Punch) error {
+	var count int64
+	if err := db.Model(&model.PunchRevision{}).Where("punch_id = ?", punch.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return saveRevision(db, punch)
+}
+
+// latestDiff 最新版本与上一版本的差异，版本不足两个时返回 nil
+func latestDiff(ctx context.Context, store pictureBed.Storage, punchID uint) (*RevisionDiff, int, error) {
+	var revs []model.PunchRevision
+	if err := database.DB.Where("punch_id = ?", punchID).Order("version DESC").Limit(2).Find(&revs).Error; err != nil {
+		return nil, 0, err
+	}
+	switch len(revs) {
+	case 0:
+		return nil, 0, nil
+	case 1:
+		return nil, revs[0].Version, nil
+	}
+	return diffRevisions(ctx, store, &revs[1], &revs[0]), revs[0].Version, nil
+}
+
+// diffRevisions 比对两个版本，图片与附件转换为可访问的地址
+func diffRevisions(ctx context.Context, store pictureBed.Storage, from, to *model.PunchRevision) *RevisionDiff {
+	diff := &RevisionDiff{
+		From:           from.Version,
+		To:             to.Version,
+		ColumnChanged:  from.ColumnID != to.ColumnID,
+		FromColumnID:   from.ColumnID,
+		ToColumnID:     to.ColumnID,
+		ContentChanged: from.Content != to.Content,
+		Content:        diffText(from.Content, to.Content),
+		FromPunchedAt:  from.PunchedAt,
+		ToPunchedAt:    to.PunchedAt,
+	}
+	diff.AddedImages = pictureBed.ReadURLs(ctx, store, subtract(to.Images, from.Images))
+	diff.RemovedImages = pictureBed.ReadURLs(ctx, store, subtract(from.Images, to.Images))
+	diff.AddedAttachments = pictureBed.ReadURLs(ctx, store, subtract(to.Attachments, from.Attachments))
+	diff.RemovedAttachments = pictureBed.ReadURLs(ctx, store, subtract(from.Attachments, to.Attachments))
+	return diff
+}
+
+// subtract 返回 a 中不在 b 里的元素
+func subtract(a, b []string) []string {
+	result := make([]string, 0)
+	for _, s := range a {
+		if !slices.Contains(b, s) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// diffText 按字符计算最长公共子序列，得到旧内容到新内容的差异片段，相同的首尾不参与比对
+func diffText(a, b string) []DiffSegment {
+	ra, rb := []rune(a), []rune(b)
+	segments := make([]DiffSegment, 0)
+	push := func(op string, r rune) {
+		if n := len(segments); n > 0 && segments[n-1].Op == op {
+			segments[n-1].Text += string(r)
+			return
+		}
+		segments = append(segments, DiffSegment{Op: op, Text: string(r)})
+	}
+	prefix := 0
+	for prefix < len(ra) && prefix < len(rb) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(ra)-prefix && suffix < len(rb)-prefix && ra[len(ra)-1-suffix] == rb[len(rb)-1-suffix] {
+		suffix++
+	}
+	if prefix > 0 {
+		segments = append(segments, DiffSegment{Op: DiffEqual, Text: string(ra[:prefix])})
+	}
+	tail := ra[len(ra)-suffix:]
+	ra, rb = ra[prefix:len(ra)-suffix], rb[prefix:len(rb)-suffix]
+
+	if len(ra)*len(rb) > maxDiffCells {
+		if len(ra) > 0 {
+			segments = append(segments, DiffSegment{Op: DiffDelete, Text: string(ra)})
+		}
+		if len(rb) > 0 {
+			segments = append(segments, DiffSegment{Op: DiffInsert, Text: string(rb)})
+		}
+	} else {
+		// lcs[i*w+j] 为 ra[i:] 与 rb[j:] 的最长公共子序列长度
+		w := len(rb) + 1
+		lcs := make([]int32, (len(ra)+1)*w)
+		for i := len(ra) - 1; i >= 0; i-- {
+			for j := len(rb) - 1; j >= 0; j-- {
+				if ra[i] == rb[j] {
+					lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+				} else {
+					lcs[i*w+j] = max(lcs[(i+1)*w+j], lcs[i*w+j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(ra) && j < len(rb) {
+			switch {
+			case ra[i] == rb[j]:
+				push(DiffEqual, ra[i])
+				i, j = i+1, j+1
+			case lcs[(i+1)*w+j] >= lcs[i*w+j+1]:
+				push(DiffDelete, ra[i])
+				i++
+			default:
+				push(DiffInsert, rb[j])
+				j++
+			}
+		}
+		for ; i < len(ra); i++ {
+			push(DiffDelete, ra[i])
+		}
+		for ; j < len(rb); j++ {
+			push(DiffInsert, rb[j])
+		}
+	}
+	if len(tail) > 0 {
+		if n := len(segments); n > 0 && segments[n-1].Op == DiffEqual {
+			segments[n-1].Text += string(tail)
+		} else {
+			segments = append(segments, DiffSegment{Op: DiffEqual, Text: string(tail)})
+		}
+	}
+	return segments
+}
+
+// GetPunchRevisions 查询打卡的全部历史版本，仅本人与打卡所属活动的审核员及以上可查看
+func GetPunchRevisions(c *gin.Context) {
+	userPayload, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	var punch model.Punch
+	if err := database.DB.First(&punch, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrNotFound.WithTips("打卡记录不存在"))
+			return
+		}
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	activityID, err := columnActivityID(uint(punch.ColumnID))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	viewable, err := model.CanViewPunch(database.DB, activityID, punch.UserID, userPayload.ID, userPayload.StudentID)
+	if err != nil {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	if !viewable {
+		response.Fail(c, response.ErrForbidden)
+		return
+	}
+
+	var revs []model.PunchRevision
+	if err := database.DB.Where("punch_id = ?", punch.ID).Order("version").Find(&revs).Error; err != nil {
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	store := linkStore(c.Request.Context())
+	diffs := make([]*RevisionDiff, 0, len(revs))
+	for i := range revs {
+		if i > 0 {
+			diffs = append(diffs, diffRevisions(c.Request.Context(), store, &revs[i-1], &revs[i]))
+		}
+	}
+	for i := range revs {
+		revs[i].Images = pictureBed.ReadURLs(c.Request.Context(), store, revs[i].Images)
+		revs[i].Attachments = pictureBed.ReadURLs(c.Request.Context(), store, revs[i].Attachments)
+	}
+	response.Success(c, gin.H{
+		"revisions": revs,  // 按版本号升序
+		"diffs":     diffs, // diffs[i] 为第 i+1 版到第 i+2 版的差异
+	})
+}
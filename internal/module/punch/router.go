@@ -9,21 +9,15 @@ import (
 func (p *ModulePunch) InitRouter(r *gin.RouterGroup) {
 	// 定义打卡模块的路由组，所有打卡相关端点以 /punch 为前缀
 	commonGroup := r.Group("/punch")
-	adminGroup := r.Group("/punch")
-	adminGroup.Use(middleware.Auth(1))
-	{
-		// 审核打卡记录端点
-		adminGroup.POST("/review", ReviewPunch)
-		adminGroup.GET("/pending-list", GetPendingPunchList)
-		adminGroup.GET("/reviewed", GetReviewedPunchList)
-
-	}
-
 	commonGroup.Use(middleware.Auth(0))
 	{
+		// 审核打卡记录端点，由活动成员角色（审核员及以上）校验权限
+		commonGroup.POST("/review", middleware.Idempotent(), ReviewPunch)
+		commonGroup.GET("/pending-list", GetPendingPunchList)
+		commonGroup.GET("/reviewed", GetReviewedPunchList)
 
-		// 插入打卡记录端点
-		commonGroup.POST("/insert", InsertPunch)
+		// 插入打卡记录端点，审核、打卡与草稿提交支持 Idempotency-Key 防止重复提交
+		commonGroup.POST("/insert", middleware.Idempotent(), InsertPunch)
 		// 获取打卡记录端点
 		commonGroup.GET("/:column_id", GetPunchesByColumn)
 		// 获取今日栏目打卡人数端点
@@ -39,6 +33,14 @@ func (p *ModulePunch) InitRouter(r *gin.RouterGroup) {
 		commonGroup.GET("/recent-participation", GetRecentParticipation)
 		// 获取打卡记录详情端点
 		commonGroup.GET("/get/:id", GetPunchDetail)
+		// 获取打卡历史版本端点
+		commonGroup.GET("/revisions/:id", GetPunchRevisions)
+		// 打卡草稿：自动保存、列表、恢复、删除与提交
+		commonGroup.GET("/drafts", ListDrafts)
+		commonGroup.PUT("/draft/:column_id", SaveDraft)
+		commonGroup.GET("/draft/:column_id", GetDraft)
+		commonGroup.DELETE("/draft/:column_id", DeleteDraft)
+		commonGroup.POST("/draft/:column_id/submit", middleware.Idempotent(), SubmitDraft)
 		// 获取预签名上传 URL（推荐：前端直接上传到 S3）
 		commonGroup.POST("/presigned-upload-url", GetPresignedUploadURL)
 	}
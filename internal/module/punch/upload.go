@@ -0,0 +1,319 @@
+package punch
+
+import (
+	"activity-punch-system/config"
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/pictureBed"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// defaultMaxImageSize 未配置 S3.MaxImageSize 时的打卡图片大小上限（MB）
+const defaultMaxImageSize = 10
+
+// attachmentLinkExpire 附件预签名下载链接的有效期（秒）
+const attachmentLinkExpire = 3600
+
+// attachmentTypes 各类附件允许的 MIME 类型
+var attachmentTypes = map[string][]string{
+	model.AttachmentImage: {
+		"image/jpeg",
+		"image/png",
+		"image/gif",
+		"image/webp", // HEIC/HEIF 无法解码去除元数据，由客户端转换为 JPEG 后上传
+	},
+	model.AttachmentAudio: {
+		"audio/mpeg",
+		"audio/mp4",
+		"audio/x-m4a",
+		"audio/aac",
+		"audio/wav",
+		"audio/x-wav",
+		"audio/ogg",
+		"audio/webm",
+		"audio/amr",
+	},
+	model.AttachmentVideo: {
+		"video/mp4",
+		"video/quicktime",
+		"video/webm",
+		"video/3gpp",
+	},
+	model.AttachmentDocument: {
+		"application/pdf",
+		"application/msword",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.ms-excel",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"application/vnd.ms-powerpoint",
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		"text/plain",
+	},
+}
+
+// defaultMaxSizes 非图片附件的默认大小上限（MB），图片由 S3.MaxImageSize 配置
+var defaultMaxSizes = map[string]int{
+	model.AttachmentAudio:    20,
+	model.AttachmentVideo:    100,
+	model.AttachmentDocument: 20,
+}
+
+// attachmentNames 附件类型在提示中的名称
+var attachmentNames = map[string]string{
+	model.AttachmentImage:    "图片",
+	model.AttachmentAudio:    "音频",
+	model.AttachmentVideo:    "视频",
+	model.AttachmentDocument: "文档",
+}
+
+// AttachmentReq 打卡提交的非图片附件
+type AttachmentReq struct {
+	URL      string `json:"url" binding:"required"`   // 预签名上传返回的 file_url
+	Name     string `json:"name" binding:"max=255"`   // 原始文件名，为空时使用对象 key
+	Duration int    `json:"duration" binding:"min=0"` // 音视频时长（秒）
+}
+
+// normalizeContentType 规范化上传声明的 MIME 类型，未声明时按文件扩展名推断
+func normalizeContentType(contentType, filename string) string {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(strings.ToLower(path.Ext(filename)))
+	}
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mt
+	}
+	return strings.ToLower(contentType)
+}
+
+// attachmentKind 按 MIME 类型判断附件类型，不支持的类型返回空字符串
+func attachmentKind(contentType string) string {
+	for kind, types := range attachmentTypes {
+		if slices.Contains(types, contentType) {
+			return kind
+		}
+	}
+	return ""
+}
+
+// maxAttachmentSize 某类附件的大小上限（字节），栏目未设置时使用默认上限
+func maxAttachmentSize(kind string, rule model.AttachmentRule) int64 {
+	mb := rule.MaxSize
+	if mb <= 0 {
+		mb = defaultMaxSizes[kind]
+		if kind == model.AttachmentImage {
+			if mb = config.Get().S3.MaxImageSize; mb <= 0 {
+				mb = defaultMaxImageSize
+			}
+		}
+	}
+	return int64(mb) << 20
+}
+
+// checkUpload 校验上传对象须为签发给该用户的上传 key，且已上传到存储中，返回对象 key 与元信息。
+// 返回的错误可直接交给 response.Fail
+func checkUpload(ctx context.Context, store pictureBed.Storage, userID uint, rawURL, label string) (string, *pictureBed.ObjectInfo, error) {
+	key, ok := pictureBed.ResolveKey(store, rawURL)
+	if !ok {
+		return "", nil, response.ErrInvalidRequest.WithTips(label + "地址无效")
+	}
+	var issued model.UploadKey
+	if err := database.DB.Where("object_key = ? AND user_id = ?", key, userID).First(&issued).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Warn("打卡附件非本人上传", "user_id", userID, "key", key)
+			return "", nil, response.ErrInvalidRequest.WithTips(label + "不是由你上传的")
+		}
+		return "", nil, response.ErrDatabase.WithOrigin(err)
+	}
+	info, err := store.HeadObject(ctx, key)
+	if err != nil {
+		if errors.Is(err, pictureBed.ErrObjectNotFound) {
+			return "", nil, response.ErrInvalidRequest.WithTips(label + "尚未上传成功，请重新上传")
+		}
+		log.Error("查询打卡附件失败", "error", err, "key", key)
+		return "", nil, response.ErrServerInternal.WithTips("校验附件失败，请稍后重试")
+	}
+	return key, info, nil
+}
+
+// verifyImages 校验打卡提交的图片：数量符合栏目限制，须为签发给该用户的上传 key，对象已存在于存储桶中，且类型与大小符合要求，
+// 返回待保存的图片引用（私有模式下为对象 key）。
+// punchID 不为 0 时，该打卡已有的图片视为已校验并沿用原引用，兼容上线前的历史图片与客户端回传的签名链接。
+// 返回的错误可直接交给 response.Fail
+func verifyImages(ctx context.Context, userID, punchID uint, urls []string, rules model.AttachmentRules) ([]string, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	rule, ok := rules.Rule(model.AttachmentImage)
+	if !ok {
+		return nil, response.ErrInvalidRequest.WithTips("该栏目不接受图片")
+	}
+	if len(urls) > rule.MaxCount {
+		return nil, response.ErrInvalidRequest.WithTips(fmt.Sprintf("该栏目每次打卡最多上传 %d 张图片", rule.MaxCount))
+	}
+
+	store, err := pictureBed.New(ctx)
+	if err != nil {
+		log.Error("初始化对象存储失败", "error", err)
+		return nil, response.ErrServerInternal.WithTips("初始化存储服务失败")
+	}
+	existing := make(map[string]string)
+	if punchID != 0 {
+		var olds []string
+		if err := database.DB.Model(&model.PunchImg{}).Where("punch_id = ?", punchID).
+			Pluck("img_url", &olds).Error; err != nil {
+			return nil, response.ErrDatabase.WithOrigin(err)
+		}
+		for _, old := range olds {
+			existing[old] = old
+			if key, ok := pictureBed.ResolveKey(store, old); ok {
+				existing[key] = old
+			}
+		}
+	}
+
+	limit := maxAttachmentSize(model.AttachmentImage, rule)
+	refs := make([]string, 0, len(urls))
+	for i, u := range urls {
+		if old, ok := existing[u]; ok {
+			refs = append(refs, old)
+			continue
+		}
+		if key, ok := pictureBed.ResolveKey(store, u); ok {
+			if old, ok := existing[key]; ok {
+				refs = append(refs, old)
+				continue
+			}
+		}
+		tips := fmt.Sprintf("第 %d 张图片", i+1)
+		key, info, err := checkUpload(ctx, store, userID, u, tips)
+		if err != nil {
+			return nil, err
+		}
+		if attachmentKind(normalizeContentType(info.ContentType, key)) != model.AttachmentImage {
+			return nil, response.ErrInvalidRequest.WithTips(tips + "格式不支持")
+		}
+		if info.Size <= 0 || info.Size > limit {
+			return nil, response.ErrInvalidRequest.WithTips(fmt.Sprintf("%s大小超出限制（最大 %dMB）", tips, limit>>20))
+		}
+		refs = append(refs, pictureBed.StoredRef(store, key, u))
+	}
+	return refs, nil
+}
+
+// verifyAttachments 校验打卡提交的音频、视频与文档附件，返回待保存的附件记录。
+// 类型与大小以存储中的对象为准，数量、大小与时长须符合栏目对该类型的限制；
+// punchID 不为 0 时，该打卡已有的附件沿用原记录，不再检查存储
+func verifyAttachments(ctx context.Context, userID, punchID uint, reqs []AttachmentReq, rules model.AttachmentRules) ([]model.PunchAttachment, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	store, err := pictureBed.New(ctx)
+	if err != nil {
+		log.Error("初始化对象存储失败", "error", err)
+		return nil, response.ErrServerInternal.WithTips("初始化存储服务失败")
+	}
+	existing := make(map[string]model.PunchAttachment)
+	if punchID != 0 {
+		var olds []model.PunchAttachment
+		if err := database.DB.Where("punch_id = ?", punchID).Find(&olds).Error; err != nil {
+			return nil, response.ErrDatabase.WithOrigin(err)
+		}
+		for _, old := range olds {
+			existing[old.URL] = old
+			if key, ok := pictureBed.ResolveKey(store, old.URL); ok {
+				existing[key] = old
+			}
+		}
+	}
+
+	counts := make(map[string]int)
+	attachments := make([]model.PunchAttachment, 0, len(reqs))
+	for i, req := range reqs {
+		tips := fmt.Sprintf("第 %d 个附件", i+1)
+		att, ok := existing[req.URL]
+		if !ok {
+			if key, resolved := pictureBed.ResolveKey(store, req.URL); resolved {
+				att, ok = existing[key]
+			}
+		}
+		att.Model = model.Model{}
+		if !ok {
+			key, info, err := checkUpload(ctx, store, userID, req.URL, tips)
+			if err != nil {
+				return nil, err
+			}
+			contentType := normalizeContentType(info.ContentType, key)
+			kind := attachmentKind(contentType)
+			if kind == "" || kind == model.AttachmentImage {
+				return nil, response.ErrInvalidRequest.WithTips(tips + "格式不支持，图片请放在图片中提交")
+			}
+			att = model.PunchAttachment{Kind: kind, URL: pictureBed.StoredRef(store, key, req.URL), Name: path.Base(key), MimeType: contentType, Size: info.Size}
+		}
+		if req.Name != "" {
+			att.Name = req.Name
+		}
+		att.Duration = req.Duration
+
+		name := attachmentNames[att.Kind]
+		rule, ok := rules.Rule(att.Kind)
+		if !ok {
+			return nil, response.ErrInvalidRequest.WithTips("该栏目不接受" + name)
+		}
+		if counts[att.Kind]++; counts[att.Kind] > rule.MaxCount {
+			return nil, response.ErrInvalidRequest.WithTips(fmt.Sprintf("该栏目每次打卡最多上传 %d 个%s", rule.MaxCount, name))
+		}
+		if limit := maxAttachmentSize(att.Kind, rule); att.Size <= 0 || att.Size > limit {
+			return nil, response.ErrInvalidRequest.WithTips(fmt.Sprintf("%s大小超出限制（最大 %dMB）", tips, limit>>20))
+		}
+		if rule.MaxDuration > 0 && att.Duration > rule.MaxDuration {
+			return nil, response.ErrInvalidRequest.WithTips(fmt.Sprintf("%s时长超出限制（最长 %d 秒）", tips, rule.MaxDuration))
+		}
+		attachments = append(attachments, att)
+	}
+	return attachments, nil
+}
+
+// loadAttachments 查询打卡的附件，store 不为空时将附件地址转换为可访问的链接（私有模式下为短期签名链接），
+// 并为每个附件生成预签名下载链接
+func loadAttachments(ctx context.Context, store pictureBed.Storage, punchID uint) []model.PunchAttachment {
+	var attachments []model.PunchAttachment
+	if err := database.DB.Where("punch_id = ?", punchID).Order("id").Find(&attachments).Error; err != nil {
+		log.Error("查询打卡附件失败", "error", err, "punch_id", punchID)
+		return []model.PunchAttachment{}
+	}
+	if store == nil {
+		return attachments
+	}
+	for i := range attachments {
+		key, ok := pictureBed.ResolveKey(store, attachments[i].URL)
+		attachments[i].URL = pictureBed.ReadURL(ctx, store, attachments[i].URL)
+		if !ok {
+			continue
+		}
+		link, err := store.GeneratePresignedDownloadURL(ctx, key, attachmentLinkExpire)
+		if err != nil {
+			log.Warn("生成附件下载链接失败", "error", err, "key", key)
+			continue
+		}
+		attachments[i].DownloadURL = link
+	}
+	return attachments
+}
+
+// linkStore 创建用于生成读取与下载链接的对象存储，失败时返回 nil，图片与附件只返回原地址
+func linkStore(ctx context.Context) pictureBed.Storage {
+	store, err := pictureBed.New(ctx)
+	if err != nil {
+		log.Error("初始化对象存储失败", "error", err)
+		return nil
+	}
+	return store
+}
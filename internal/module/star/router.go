@@ -17,9 +17,9 @@ func (*ModuleStar) InitRouter(r *gin.RouterGroup) {
 
 	adminGroup := starGroup.Use(middleware.Auth(1))
 	{
-		adminGroup.POST("/add", add)
+		adminGroup.POST("/add", middleware.Idempotent(), add)
 		adminGroup.GET("/list", list)
-		adminGroup.DELETE("/cancel", cancel)
+		adminGroup.DELETE("/cancel", middleware.Idempotent(), cancel)
 		adminGroup.GET("/ask", ask)
 	}
 }
@@ -3,6 +3,7 @@ package star
 import (
 	"activity-punch-system/internal/global/database"
 	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/pictureBed"
 	"activity-punch-system/internal/global/response"
 	"activity-punch-system/internal/model"
 	"github.com/gin-gonic/gin"
@@ -76,18 +77,51 @@ func list(c *gin.Context) {
 		response.Fail(c, response.ErrDatabase)
 	} else {
 		response.Success(c, struct {
-			UserId   uint         `json:"user_id"`
-			PageSize int          `json:"page_size"`
-			Page     int          `json:"page"`
-			Stars    []model.Star `json:"stars"`
+			UserId   uint           `json:"user_id"`
+			PageSize int            `json:"page_size"`
+			Page     int            `json:"page"`
+			Stars    []starWithImgs `json:"stars"`
 		}{
 			UserId:   user.ID,
 			PageSize: pageSize,
 			Page:     page,
-			Stars:    stars,
+			Stars:    withImgs(c, user, stars),
 		})
 	}
 }
+
+// starWithImgs 收藏及被收藏打卡的缩略图
+type starWithImgs struct {
+	model.Star
+	Imgs []string `json:"imgs"`
+}
+
+// withImgs 为收藏的打卡附上缩略图，只返回按 model.CanViewPunch 规则可查看的打卡的图片
+func withImgs(c *gin.Context, user *jwt.Claims, stars []model.Star) []starWithImgs {
+	ctx := c.Request.Context()
+	store, err := pictureBed.New(ctx)
+	if err != nil {
+		log.Error("初始化对象存储失败", "error", err)
+	}
+	result := make([]starWithImgs, 0, len(stars))
+	for _, star := range stars {
+		item := starWithImgs{Star: star, Imgs: []string{}}
+		p := star.Punch
+		viewable, err := model.CanViewPunch(database.DB, p.Column.Project.ActivityID, p.UserID, user.ID, user.StudentID)
+		if err != nil {
+			log.Error("查询打卡查看权限失败", "error", err, "punch_id", star.PunchID)
+		}
+		if viewable {
+			var imgs []model.PunchImg
+			database.DB.Where("punch_id = ?", star.PunchID).Order("id").Find(&imgs)
+			for _, img := range imgs {
+				item.Imgs = append(item.Imgs, pictureBed.ReadURL(ctx, store, img.Thumb()))
+			}
+		}
+		result = append(result, item)
+	}
+	return result
+}
 func cancel(c *gin.Context) {
 	user, ok := jwt.GetUserPayload(c)
 	if !ok {
@@ -1,24 +1,18 @@
 package activity
 
 import (
-	"activity-punch-system/config"
 	"activity-punch-system/internal/global/database"
 	"activity-punch-system/internal/global/jwt"
 	"activity-punch-system/internal/global/response"
 	"activity-punch-system/internal/model"
+	"activity-punch-system/internal/module/stats/tree"
 	"activity-punch-system/tools"
-	"bytes"
 	"errors"
-	"fmt"
 	"log/slog"
-	"net/url"
-	"os"
-	"path/filepath"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/xuri/excelize/v2"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -61,7 +55,16 @@ func Rank(c *gin.Context) {
 	}
 
 	//强制遍历一遍来更新
-	if force && user.RoleID > 0 { //todo: 权限对吗
+	// 只有活动管理员及以上可以强制刷新
+	if force {
+		role, err := model.MemberRole(database.DB, a.ID, user.StudentID)
+		if err != nil {
+			response.Fail(c, response.ErrDatabase)
+			return
+		}
+		force = role >= model.MemberManager
+	}
+	if force {
 		columnIds, err := getColumnIds(a.ID)
 		if err != nil {
 			response.Fail(c, response.ErrDatabase)
@@ -191,7 +194,7 @@ func Brief(c *gin.Context) {
 		return
 	}
 	var result briefResult
-	if err := briefStats(a.ID, user.ID, columnIDs, time.Now().Unix(), &result); err != nil {
+	if err := briefStats(a.ID, a.Day(), user.ID, columnIDs, time.Now().Unix(), &result); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			response.Fail(c, response.ErrNotFound)
 			return
@@ -203,168 +206,72 @@ func Brief(c *gin.Context) {
 	response.Success(c, result)
 }
 
-func RankExport(c *gin.Context) {
-	a, ok := activityIdValidator(c)
+// Tree 获取用户在某活动下 [start_time, end_time] 内的得分树(活动-项目-栏目)，
+// 每个节点带有分数小计以及通过/待审核/驳回的打卡数,
+// 管理员可通过 user_id 查询任意用户，普通用户只能查询自己
+func Tree(c *gin.Context) {
+	user, ok := jwt.GetUserPayload(c)
 	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
 		return
 	}
-	a.Name = a.Name[0:min(10, len(a.Name))]
-	endDateStr := strconv.FormatInt(a.EndDate, 10)
-	loc := time.Local
-	endDate, _ := time.ParseInLocation("20060102", endDateStr, loc)
-	endDate = endDate.AddDate(0, 0, 1)
-	currentTime := time.Now()
-	if currentTime.Before(endDate) {
-		response.Fail(c, &response.Error{
-			Code:    403,
-			Message: "活动尚未结束，无法导出排名",
-		})
+	a, ok := activityIdValidator(c)
+	if !ok {
 		return
 	}
-
-	dateStr := currentTime.Format("20060102")
-	filename := filepath.Join(
-		config.Get().Storage.Home,
-		"stats",
-		"activity",
-		fmt.Sprintf("activity%d_%s_ranks.xlsx", a.ID, dateStr),
-	)
-	if !tools.FileExist(filename) {
-		is, err := isReviewNotOver(a.ID)
+	userID := user.ID
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		id, err := strconv.ParseUint(userIDStr, 10, 0)
 		if err != nil {
-			Log.Error("数据库 查询 activity-project-column-punch 表错误", "error", err.Error())
-			response.Fail(c, response.ErrDatabase)
+			response.Fail(c, response.ErrInvalidRequest.WithTips("user_id 格式错误"))
 			return
 		}
-		if is {
-			response.Fail(c, &response.Error{
-				Code:    403,
-				Message: "活动打卡记录尚未审核结束，无法导出排名",
-			})
+		if uint(id) != user.ID && user.RoleID < 1 {
+			response.Fail(c, response.ErrForbidden.WithTips("无权查看其他用户的得分"))
 			return
 		}
-		dir := filepath.Dir(filename)
-
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			Log.Error("创建目录失败", "dir", dir, "error", err.Error())
-			response.Fail(c, response.ErrServerInternal)
-			return
-		}
-
-		ranks, err := selectActivityRankInExcel(a.ID)
+		userID = uint(id)
+	}
+	startTime, endTime := int64(0), time.Now().Unix()
+	if s := c.Query("start_time"); s != "" {
+		t, err := strconv.ParseInt(s, 10, 64)
 		if err != nil {
-			Log.Error("数据库 查询活动排名失败", "error", err.Error())
-			response.Fail(c, response.ErrDatabase)
-			return
-		}
-		f := excelize.NewFile()
-		defer tools.PanicOnErr(f.Close())
-		if err := tools.ExportToExcel(f, fmt.Sprintf("活动%d(%s)得分排名", a.ID, a.Name), ranks); err != nil {
-			Log.Error("导出excel错误", "error", err)
-			response.Fail(c, response.ErrServerInternal)
+			response.Fail(c, response.ErrInvalidRequest.WithTips("start_time 格式错误"))
 			return
 		}
-		_ = f.DeleteSheet("Sheet1")
-		if err := f.SaveAs(filename); err != nil {
-			Log.Error("保存文件错误", "error", err.Error())
-			response.Fail(c, response.ErrServerInternal)
+		startTime = t
+	}
+	if s := c.Query("end_time"); s != "" {
+		t, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			response.Fail(c, response.ErrInvalidRequest.WithTips("end_time 格式错误"))
 			return
 		}
+		endTime = t
 	}
-	if err := tools.SendStoredFile(c, filename, fmt.Sprintf("活动%d(%s)得分排名", a.ID, a.Name), tools.ExcelContentType); err != nil {
-		Log.Error("发送文件错误", "error", err.Error())
-		response.Fail(c, response.ErrServerInternal)
-	}
-}
-
-func Export(c *gin.Context) {
-	a, ok := activityIdValidator(c)
-	if !ok {
+	if startTime > endTime {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("start_time 不能晚于 end_time"))
 		return
 	}
-	a.Name = a.Name[0:min(10, len(a.Name))]
-	f := excelize.NewFile()
-	defer tools.PanicOnErr(f.Close())
 
-	ranks, err := selectActivityRankInExcel(a.ID)
+	at, err := loadActivityTree(a, userID, startTime, endTime)
 	if err != nil {
-		Log.Error("数据库 查询活动排名失败", "error", err.Error())
+		Log.Error("数据库 查询活动下的项目、栏目与得分失败", "error", err.Error())
 		response.Fail(c, response.ErrDatabase)
 		return
 	}
-
-	if err := tools.ExportToExcel(f, fmt.Sprintf("活动%d(%s)得分排名", a.ID, a.Name), ranks); err != nil {
-		Log.Error("导出excel错误", "error", err)
-		response.Fail(c, response.ErrServerInternal)
-		return
-	}
-	projects := []model.Project{}
-	if err := database.DB.Model(&model.Project{}).Where("activity_id = ? AND deleted_at IS NULL", a.ID).Find(&projects).Error; err != nil {
-		Log.Error("查询 project 表错误", "error", err)
-		response.Fail(c, response.ErrDatabase)
-		return
-	}
-	if err := tools.ExportToExcel(f, fmt.Sprintf("活动%d(%s)下的项目", a.ID, a.Name), projects); err != nil {
-		Log.Error("导出excel错误", "error", err)
-		response.Fail(c, response.ErrServerInternal)
-		return
-	}
-	for _, p := range projects {
-		columns := []model.Column{}
-		if err := database.DB.Model(&model.Column{}).Where("project_id = ? AND deleted_at IS NULL", p.ID).Find(&columns).Error; err != nil {
-			Log.Error("查询 column 表错误", "error", err)
-			response.Fail(c, response.ErrDatabase)
-			return
-		}
-		if err := tools.ExportToExcel(f, fmt.Sprintf("项目%d(%s)下的栏目", p.ID, p.Name[0:min(10, len(p.Name))]), columns); err != nil {
-			Log.Error("导出excel错误", "error", err)
-			response.Fail(c, response.ErrServerInternal)
-			return
-		}
-		for _, column := range columns {
-			column.Name = column.Name[0:min(10, len(column.Name))]
-			punches := []model.Punch{}
-			if err := database.DB.Model(&model.Punch{}).Where("column_id = ? AND deleted_at IS NULL", column.ID).Find(&punches).Error; err != nil {
-				Log.Error("查询 punch 表错误", "error", err)
-				response.Fail(c, response.ErrDatabase)
-				return
-			}
-			if err := tools.ExportToExcel(f, fmt.Sprintf("栏目%d(%s)的打卡记录", column.ID, column.Name), punches); err != nil {
-				Log.Error("导出excel错误", "error", err)
-				response.Fail(c, response.ErrServerInternal)
-				return
-			}
-			scores := []model.Score{}
-			if err := database.DB.Model(&model.Score{}).Where("column_id = ? AND deleted_at IS NULL", column.ID).Find(&scores).Error; err != nil {
-				Log.Error("查询 score 表错误", "error", err)
-				response.Fail(c, response.ErrDatabase)
-				return
-			}
-			if err := tools.ExportToExcel(f, fmt.Sprintf("栏目%d(%s)的得分记录", column.ID, column.Name), scores); err != nil {
-				Log.Error("导出excel错误", "error", err)
-				response.Fail(c, response.ErrServerInternal)
-				return
-			}
-
-		}
-
-	}
-	_ = f.DeleteSheet("Sheet1")
-
-	buf := &bytes.Buffer{}
-	if err := f.Write(buf); err != nil {
-		Log.Error("导出excel错误", "error", err)
-		response.Fail(c, response.ErrServerInternal)
+	root, err := tree.Unfold3[Activity, Project, Column](at, userID, startTime, endTime)
+	if err != nil {
+		Log.Error("构建得分树失败", "error", err.Error(), "activity_id", a.ID, "user_id", userID)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
 		return
 	}
-
-	c.Header("Content-Type", "application/octet-stream")
-	c.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.QueryEscape(a.Name+".xlsx"))
-	if _, err := c.Writer.Write(buf.Bytes()); err != nil {
-		Log.Error("导出excel错误", "error", err)
-		response.Fail(c, response.ErrServerInternal)
-	}
-
+	response.Success(c, gin.H{
+		"user_id":    userID,
+		"start_time": startTime,
+		"end_time":   endTime,
+		"tree":       root,
+	})
 }
 
 func activityIdValidator(c *gin.Context) (*model.Activity, bool) {
@@ -390,7 +297,3 @@ func activityIdValidator(c *gin.Context) (*model.Activity, bool) {
 	}
 	return &a[0], true
 }
-
-type Activity struct{ model.Activity }
-type Project struct{ model.Project }
-type Column struct{ model.Column }
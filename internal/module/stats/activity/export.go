@@ -0,0 +1,281 @@
+package activity
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"activity-punch-system/internal/module/stats/export"
+	"activity-punch-system/tools"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	exportKindRank     = "activity_rank"
+	exportKindActivity = "activity"
+)
+
+// RegisterExports 注册活动相关的导出类型
+func RegisterExports() {
+	export.Register(exportKindRank, buildRankExport)
+	export.Register(exportKindActivity, buildActivityExport)
+}
+
+// RankExport 提交活动排名导出任务，活动结束且审核完毕后才可导出，当天已有的同格式任务直接复用
+func RankExport(c *gin.Context) {
+	a, ok := activityIdValidator(c)
+	if !ok {
+		return
+	}
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	day := a.Day()
+	endDate, _ := day.ParseDate("20060102", strconv.FormatInt(a.EndDate, 10))
+	if time.Now().Before(day.StartOf(endDate.AddDate(0, 0, 1))) {
+		response.Fail(c, &response.Error{
+			Code:    403,
+			Message: "活动尚未结束，无法导出排名",
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", tools.FormatExcel)
+	ext, contentType, err := tools.ExportFileType(format, false)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("format 仅支持 xlsx、csv、jsonl"))
+		return
+	}
+	job, err := export.Latest(exportKindRank, a.ID, format, day.Today())
+	if err != nil {
+		Log.Error("数据库 查询导出任务失败", "error", err.Error())
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+	if job != nil {
+		response.Success(c, job)
+		return
+	}
+	is, err := isReviewNotOver(a.ID)
+	if err != nil {
+		Log.Error("数据库 查询 activity-project-column-punch 表错误", "error", err.Error())
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+	if is {
+		response.Fail(c, &response.Error{
+			Code:    403,
+			Message: "活动打卡记录尚未审核结束，无法导出排名",
+		})
+		return
+	}
+	job = &model.ExportJob{
+		Kind:        exportKindRank,
+		ActivityID:  a.ID,
+		UserID:      user.ID,
+		Format:      format,
+		Shared:      true,
+		FileName:    fmt.Sprintf("活动%d(%s)得分排名%s", a.ID, tools.ShortName(a.Name), ext),
+		ContentType: contentType,
+	}
+	if err := export.Submit(job); err != nil {
+		Log.Error("提交导出任务失败", "error", err.Error())
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+	response.Success(c, job)
+}
+
+// Export 提交活动完整数据导出任务：排名、项目、栏目及各栏目的打卡与得分记录，
+// format 为 csv 时每张表一个文件打包为 zip
+func Export(c *gin.Context) {
+	a, ok := activityIdValidator(c)
+	if !ok {
+		return
+	}
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	role, err := model.MemberRole(database.DB, a.ID, user.StudentID)
+	if err != nil {
+		Log.Error("查询 activity_member 表错误", "error", err)
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+	if role < model.MemberManager {
+		response.Fail(c, response.ErrForbidden.WithTips("无权导出该活动"))
+		return
+	}
+	format := c.DefaultQuery("format", tools.FormatExcel)
+	ext, contentType, err := tools.ExportFileType(format, true)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("format 仅支持 xlsx、csv、jsonl"))
+		return
+	}
+	job := &model.ExportJob{
+		Kind:        exportKindActivity,
+		ActivityID:  a.ID,
+		UserID:      user.ID,
+		Format:      format,
+		FileName:    tools.ShortName(a.Name) + ext,
+		ContentType: contentType,
+	}
+	if err := export.Submit(job); err != nil {
+		Log.Error("提交导出任务失败", "error", err.Error())
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+	response.Success(c, job)
+}
+
+func buildRankExport(job *model.ExportJob, w io.Writer, progress func(int)) error {
+	a, err := selectActivity(job.ActivityID)
+	if err != nil {
+		return err
+	}
+	tw, err := tools.NewTableWriter(job.Format, w, false)
+	if err != nil {
+		return err
+	}
+	table, err := tools.BeginTable(tw, fmt.Sprintf("活动%d(%s)得分排名", a.ID, tools.ShortName(a.Name)), activityRankInExcel{})
+	if err != nil {
+		return err
+	}
+	if err := eachActivityRank(a.ID, func(r *activityRankInExcel) error { return table.Write(r) }); err != nil {
+		return err
+	}
+	progress(90)
+	return tw.Close()
+}
+
+// buildActivityExport 逐行读取并写出活动的排名、项目、栏目及各栏目的打卡与得分记录，
+// 打卡与得分各只查询一次，按栏目输出顺序排序后依次切分到各栏目的表中
+func buildActivityExport(job *model.ExportJob, w io.Writer, progress func(int)) error {
+	a, err := selectActivity(job.ActivityID)
+	if err != nil {
+		return err
+	}
+	name := tools.ShortName(a.Name)
+	tw, err := tools.NewTableWriter(job.Format, w, true)
+	if err != nil {
+		return err
+	}
+
+	table, err := tools.BeginTable(tw, fmt.Sprintf("活动%d(%s)得分排名", a.ID, name), activityRankInExcel{})
+	if err != nil {
+		return err
+	}
+	if err := eachActivityRank(a.ID, func(r *activityRankInExcel) error { return table.Write(r) }); err != nil {
+		return err
+	}
+	progress(10)
+
+	projects, columnsOf, columnIDs, err := selectActivityStructure(a.ID)
+	if err != nil {
+		return err
+	}
+	if err := tools.WriteTable(tw, fmt.Sprintf("活动%d(%s)下的项目", a.ID, name), projects); err != nil {
+		return err
+	}
+	progress(15)
+
+	punches, err := openCursor[model.Punch](selectColumnsPunches(columnIDs))
+	if err != nil {
+		return err
+	}
+	defer punches.close()
+	scores, err := openCursor[model.Score](selectColumnsScores(columnIDs))
+	if err != nil {
+		return err
+	}
+	defer scores.close()
+
+	total, done := len(columnIDs), 0
+	for _, p := range projects {
+		columns := columnsOf[p.ID]
+		if err := tools.WriteTable(tw, fmt.Sprintf("项目%d(%s)下的栏目", p.ID, tools.ShortName(p.Name)), columns); err != nil {
+			return err
+		}
+		for _, column := range columns {
+			columnName := tools.ShortName(column.Name)
+			table, err := tools.BeginTable(tw, fmt.Sprintf("栏目%d(%s)的打卡记录", column.ID, columnName), model.Punch{})
+			if err != nil {
+				return err
+			}
+			if err := punches.each(
+				func(p *model.Punch) bool { return uint(p.ColumnID) == column.ID },
+				func(p *model.Punch) error { return table.Write(p) },
+			); err != nil {
+				return err
+			}
+			if table, err = tools.BeginTable(tw, fmt.Sprintf("栏目%d(%s)的得分记录", column.ID, columnName), model.Score{}); err != nil {
+				return err
+			}
+			if err := scores.each(
+				func(s *model.Score) bool { return s.ColumnID == column.ID },
+				func(s *model.Score) error { return table.Write(s) },
+			); err != nil {
+				return err
+			}
+			done++
+			progress(15 + 80*done/total)
+		}
+	}
+	return tw.Close()
+}
+
+// rowCursor 逐行读取有序的查询结果，供按栏目切分写出
+type rowCursor[T any] struct {
+	rows *sql.Rows
+	cur  *T
+	err  error
+}
+
+func openCursor[T any](db *gorm.DB) (*rowCursor[T], error) {
+	rows, err := db.Rows()
+	if err != nil {
+		return nil, err
+	}
+	c := &rowCursor[T]{rows: rows}
+	c.next()
+	return c, nil
+}
+
+func (c *rowCursor[T]) next() {
+	if !c.rows.Next() {
+		c.cur, c.err = nil, c.rows.Err()
+		return
+	}
+	var v T
+	if err := database.DB.ScanRows(c.rows, &v); err != nil {
+		c.cur, c.err = nil, err
+		return
+	}
+	c.cur = &v
+}
+
+// each 对当前位置起连续满足 match 的行调用 fn
+func (c *rowCursor[T]) each(match func(*T) bool, fn func(*T) error) error {
+	for c.cur != nil && match(c.cur) {
+		if err := fn(c.cur); err != nil {
+			return err
+		}
+		c.next()
+	}
+	return c.err
+}
+
+func (c *rowCursor[T]) close() {
+	_ = c.rows.Close()
+}
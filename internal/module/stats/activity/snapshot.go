@@ -0,0 +1,52 @@
+package activity
+
+import (
+	"activity-punch-system/internal/global/clock"
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/schedule"
+	"activity-punch-system/internal/model"
+	"time"
+)
+
+// StartRankSnapshotScheduler 每小时整点检查进行中的活动，活动所在时区刚切换到新的一天时记录其前一天结束时的排名
+func StartRankSnapshotScheduler() {
+	schedule.Hourly("stats:rank_snapshot", 0, snapshotRolledOver)
+}
+
+// snapshotRolledOver 为最近一小时内切换到新一天的活动记录前一天的排名快照
+func snapshotRolledOver() {
+	now := time.Now()
+	// 各时区的日期与默认时区相差不超过一天，先按默认时区放宽一天筛选，再按活动自身的日界规则判断
+	var activities []model.Activity
+	if err := database.DB.Model(&model.Activity{}).Select("id", "start_date", "end_date", "timezone", "day_rollover").
+		Where("start_date <= ? AND end_date >= ?", clock.Default().Int(now), clock.Default().Int(now.AddDate(0, 0, -2))).
+		Find(&activities).Error; err != nil {
+		Log.Error("数据库 查询进行中的活动失败", "error", err.Error())
+		return
+	}
+	for _, a := range activities {
+		day := a.Day()
+		start := day.Start(now)
+		if now.Sub(start) >= time.Hour {
+			continue
+		}
+		date := day.Int(start.Add(-time.Second))
+		if a.StartDate > date || a.EndDate < date {
+			continue
+		}
+		if err := snapshotRank(a.ID, date); err != nil {
+			Log.Error("记录排名快照失败", "error", err.Error(), "activity_id", a.ID)
+		}
+	}
+}
+
+// snapshotRank 将活动当前排名记为 date 的快照，重复执行时覆盖
+func snapshotRank(activityID uint, date int64) error {
+	return database.DB.Exec(`
+		INSERT INTO rank_snapshot (activity_id, date, user_id, ranks, score)
+		SELECT activity_id, ?, user_id, RANK() OVER (ORDER BY score DESC), score
+		FROM total_score
+		WHERE activity_id = ?
+		ON DUPLICATE KEY UPDATE ranks = VALUES(ranks), score = VALUES(score)`,
+		date, activityID).Error
+}
@@ -1,8 +1,14 @@
 package activity
 
 import (
+	"activity-punch-system/internal/global/clock"
 	"activity-punch-system/internal/global/database"
 	"activity-punch-system/internal/model"
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func selectHistory(userId uint, askTime int64, offset, limit int, r *[]model.Activity) error {
@@ -75,9 +81,9 @@ type activityRankInExcel struct {
 	Grade     string `gorm:"column:grade" json:"grade" excel:"年级"`
 }
 
-func selectActivityRankInExcel(activityID uint) ([]activityRankInExcel, error) {
-	var ranks []activityRankInExcel
-	if err := database.DB.Table("total_score ts").
+// eachActivityRank 按排名逐行读取活动排名
+func eachActivityRank(activityID uint, fn func(*activityRankInExcel) error) error {
+	rows, err := database.DB.Table("total_score ts").
 		Select(`
 			u.id,
         	u.student_id,
@@ -92,10 +98,21 @@ func selectActivityRankInExcel(activityID uint) ([]activityRankInExcel, error) {
 		Joins("JOIN user u ON u.id = ts.user_id").
 		Order("ranks ASC").
 		Where("ts.activity_id = ?", activityID).
-		Scan(&ranks).Error; err != nil {
-		return nil, err
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var r activityRankInExcel
+		if err := database.DB.ScanRows(rows, &r); err != nil {
+			return err
+		}
+		if err := fn(&r); err != nil {
+			return err
+		}
 	}
-	return ranks, nil
+	return rows.Err()
 }
 
 type briefResult struct {
@@ -103,9 +120,21 @@ type briefResult struct {
 	TodayPuncherCount uint `json:"today_punched_user_count"`
 	TotalScore        uint `gorm:"column:ts" json:"total_score"`
 	model.Continuity
+	rankPosition
 }
 
-func briefStats(activityID, userID uint, columnIDs []uint, askTime int64, result *briefResult) error {
+// rankPosition 请求者在活动排名中的相对位置
+type rankPosition struct {
+	Participants   int64   `gorm:"-" json:"participants"`     // 有得分记录的参与人数
+	TopPercent     float64 `gorm:"-" json:"top_percent"`      // 排名位于前百分之几，无排名时为 100
+	AheadOfPercent float64 `gorm:"-" json:"ahead_of_percent"` // 得分超过了百分之几的参与者
+	GapToNextRank  uint    `gorm:"-" json:"gap_to_next_rank"` // 距上一名次还差的分数，已是第一时为 0
+	GapToTop10     uint    `gorm:"-" json:"gap_to_top10"`     // 距第 10 名的分数差，已在前 10 时为 0
+	GapToTop100    uint    `gorm:"-" json:"gap_to_top100"`    // 距第 100 名的分数差，已在前 100 时为 0
+	RankChange     *int    `gorm:"-" json:"rank_change"`      // 较昨日结束时排名的变化，正数为上升，昨日无排名时为 null
+}
+
+func briefStats(activityID uint, day clock.Day, userID uint, columnIDs []uint, askTime int64, result *briefResult) error {
 	var continuityResult model.Continuity
 	if err := database.DB.Table("continuity").Where("activity_id = ? AND user_id = ?", activityID, userID).
 		Scan(&continuityResult).Error; err != nil {
@@ -126,7 +155,7 @@ func briefStats(activityID, userID uint, columnIDs []uint, askTime int64, result
 	var todayPuncherCount uint
 	if err := database.DB.Table("punch").
 		Select("COUNT(DISTINCT user_id) AS tpuc").
-		Where("column_id IN (?) AND created_at >= ?", columnIDs, askTime-(askTime+8*3600)%86400). // UTC+8 当天零点
+		Where("column_id IN (?) AND created_at >= ?", columnIDs, day.Start(time.Unix(askTime, 0))). // 活动时区当天的开始时刻
 		Scan(&todayPuncherCount).Error; err != nil {
 		Log.Error("数据库 查询punch获得当天已经打卡此活动人数失败", "error", err.Error())
 		return err
@@ -135,6 +164,68 @@ func briefStats(activityID, userID uint, columnIDs []uint, askTime int64, result
 	result.TotalScore = totalScoreResult.TotalScore
 	result.Rank = totalScoreResult.Rank
 	result.TodayPuncherCount = todayPuncherCount
+	return selectRankPosition(activityID, day, userID, result.Rank, result.TotalScore, askTime, &result.rankPosition)
+}
+
+// selectRankPosition 计算百分位、与前一名次及前 10/100 名的分数差，以及较昨日的排名变化
+func selectRankPosition(activityID uint, day clock.Day, userID uint, rank int, score uint, askTime int64, result *rankPosition) error {
+	wrapper := func() *gorm.DB {
+		return database.DB.Table("total_score").Where("activity_id = ?", activityID)
+	}
+	if err := wrapper().Count(&result.Participants).Error; err != nil {
+		Log.Error("数据库 查询活动参与人数失败", "error", err.Error())
+		return err
+	}
+	result.TopPercent = 100
+	if result.Participants == 0 {
+		return nil
+	}
+	var behind int64
+	if err := wrapper().Where("score < ?", score).Count(&behind).Error; err != nil {
+		Log.Error("数据库 查询活动排名位置失败", "error", err.Error())
+		return err
+	}
+	result.AheadOfPercent = float64(behind) * 100 / float64(result.Participants)
+	if rank > 0 {
+		result.TopPercent = float64(rank) * 100 / float64(result.Participants)
+	}
+
+	var next sql.NullInt64
+	if err := wrapper().Select("MIN(score)").Where("score > ?", score).Scan(&next).Error; err != nil {
+		Log.Error("数据库 查询上一名次分数失败", "error", err.Error())
+		return err
+	}
+	if next.Valid {
+		result.GapToNextRank = uint(next.Int64) - score
+	}
+	for _, t := range []struct {
+		n   int
+		gap *uint
+	}{{10, &result.GapToTop10}, {100, &result.GapToTop100}} {
+		var threshold []uint
+		if err := wrapper().Order("score DESC").Offset(t.n-1).Limit(1).Pluck("score", &threshold).Error; err != nil {
+			Log.Error("数据库 查询前N名分数线失败", "error", err.Error(), "n", t.n)
+			return err
+		}
+		// 参与人数不足 n 时视为已在前 n 名
+		if len(threshold) > 0 && threshold[0] > score {
+			*t.gap = threshold[0] - score
+		}
+	}
+
+	if rank > 0 {
+		yesterday := day.Int(day.Start(time.Unix(askTime, 0)).Add(-time.Second))
+		var snapshot []model.RankSnapshot
+		if err := database.DB.Where("activity_id = ? AND date = ? AND user_id = ?", activityID, yesterday, userID).
+			Limit(1).Find(&snapshot).Error; err != nil {
+			Log.Error("数据库 查询排名快照失败", "error", err.Error())
+			return err
+		}
+		if len(snapshot) > 0 {
+			change := int(snapshot[0].Rank) - rank
+			result.RankChange = &change
+		}
+	}
 	return nil
 }
 func isReviewNotOver(activityID uint) (is bool, err error) {
@@ -150,3 +241,65 @@ func isReviewNotOver(activityID uint) (is bool, err error) {
 	return
 
 }
+
+func selectActivity(activityID uint) (*model.Activity, error) {
+	var a model.Activity
+	if err := database.DB.Model(&model.Activity{}).
+		Where("id = ? AND deleted_at IS NULL", activityID).
+		First(&a).Error; err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// selectActivityStructure 查询活动下的项目与按项目分组的栏目，columnIDs 为栏目的输出顺序
+func selectActivityStructure(activityID uint) (projects []model.Project, columnsOf map[uint][]model.Column, columnIDs []uint, err error) {
+	columnsOf = map[uint][]model.Column{}
+	if err = database.DB.Model(&model.Project{}).
+		Where("activity_id = ? AND deleted_at IS NULL", activityID).
+		Order("id ASC").
+		Find(&projects).Error; err != nil || len(projects) == 0 {
+		return
+	}
+	projectIDs := make([]uint, 0, len(projects))
+	for _, p := range projects {
+		projectIDs = append(projectIDs, p.ID)
+	}
+	var columns []model.Column
+	if err = database.DB.Model(&model.Column{}).
+		Where("project_id IN ? AND deleted_at IS NULL", projectIDs).
+		Order("id ASC").
+		Find(&columns).Error; err != nil {
+		return
+	}
+	for _, c := range columns {
+		columnsOf[c.ProjectID] = append(columnsOf[c.ProjectID], c)
+	}
+	for _, p := range projects {
+		for _, c := range columnsOf[p.ID] {
+			columnIDs = append(columnIDs, c.ID)
+		}
+	}
+	return
+}
+
+// orderByColumns 按 columnIDs 的顺序排列，同一栏目内按 id 排列
+func orderByColumns(columnIDs []uint) clause.OrderBy {
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:                "FIELD(column_id, ?), id",
+		Vars:               []any{columnIDs},
+		WithoutParentheses: true,
+	}}
+}
+
+func selectColumnsPunches(columnIDs []uint) *gorm.DB {
+	return database.DB.Model(&model.Punch{}).
+		Where("column_id IN ? AND deleted_at IS NULL", columnIDs).
+		Order(orderByColumns(columnIDs))
+}
+
+func selectColumnsScores(columnIDs []uint) *gorm.DB {
+	return database.DB.Model(&model.Score{}).
+		Where("column_id IN ? AND deleted_at IS NULL", columnIDs).
+		Order(orderByColumns(columnIDs))
+}
@@ -0,0 +1,126 @@
+package activity
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/model"
+	"activity-punch-system/internal/module/stats/tree"
+	"time"
+)
+
+// Activity Project Column 为 tree.Record 的实现，下层节点与栏目的统计在 loadActivityTree 中一次性载入
+
+type Activity struct {
+	model.Activity
+	projects []Project
+}
+type Project struct {
+	model.Project
+	columns []Column
+}
+type Column struct {
+	model.Column
+	stat tree.Stat
+}
+
+func (a Activity) NextLayer() []tree.Record { return tree.ToRecordSlice(a.projects) }
+func (a Activity) GetId() uint              { return a.ID }
+func (a Activity) GetName() string          { return a.Name }
+
+func (p Project) NextLayer() []tree.Record { return tree.ToRecordSlice(p.columns) }
+func (p Project) GetId() uint              { return p.ID }
+func (p Project) GetName() string          { return p.Name }
+
+func (c Column) NextLayer() []tree.Record { return nil }
+func (c Column) GetId() uint              { return c.ID }
+func (c Column) GetName() string          { return c.Name }
+
+// GetStat 返回 loadActivityTree 中按栏目聚合的统计，参数已在载入时使用
+func (c Column) GetStat(uint, int64, int64) (tree.Stat, error) { return c.stat, nil }
+
+// columnStat 按栏目聚合的 tree.Stat
+type columnStat struct {
+	ColumnID uint
+	tree.Stat
+}
+
+// selectColumnStats 统计用户在活动各栏目下 [start, end] 内打卡所得分数及各审核状态的打卡数，时间以打卡时间为准
+func selectColumnStats(columnIDs []uint, userID uint, start, end time.Time) (map[uint]tree.Stat, error) {
+	result := make(map[uint]tree.Stat, len(columnIDs))
+	if len(columnIDs) == 0 {
+		return result, nil
+	}
+	var scores []columnStat
+	if err := database.DB.Table("score").
+		Select("score.column_id, COALESCE(SUM(score.count), 0) AS score").
+		Joins("JOIN punch ON punch.id = score.punch_id").
+		Where("score.column_id IN ? AND score.user_id = ? AND score.deleted_at IS NULL", columnIDs, userID).
+		Where("punch.created_at >= ? AND punch.created_at <= ?", start, end).
+		Group("score.column_id").
+		Scan(&scores).Error; err != nil {
+		return nil, err
+	}
+	var counts []columnStat
+	if err := database.DB.Table("punch").
+		Select(`
+			column_id,
+			COALESCE(SUM(status = 1), 0) AS approved,
+			COALESCE(SUM(status = 0), 0) AS pending,
+			COALESCE(SUM(status = 2), 0) AS rejected
+		`).
+		Where("column_id IN ? AND user_id = ? AND deleted_at IS NULL", columnIDs, userID).
+		Where("created_at >= ? AND created_at <= ?", start, end).
+		Group("column_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range counts {
+		result[c.ColumnID] = c.Stat
+	}
+	for _, s := range scores {
+		stat := result[s.ColumnID]
+		stat.Score = s.Score
+		result[s.ColumnID] = stat
+	}
+	return result, nil
+}
+
+// loadActivityTree 载入活动下未删除的项目与栏目，以及用户在各栏目下 [startTime, endTime] 内的统计
+func loadActivityTree(a *model.Activity, userID uint, startTime, endTime int64) (*Activity, error) {
+	var projects []model.Project
+	if err := database.DB.Model(&model.Project{}).
+		Where("activity_id = ? AND deleted_at IS NULL", a.ID).
+		Order("id ASC").
+		Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	projectIDs := make([]uint, 0, len(projects))
+	for _, p := range projects {
+		projectIDs = append(projectIDs, p.ID)
+	}
+	var columns []model.Column
+	if len(projectIDs) > 0 {
+		if err := database.DB.Model(&model.Column{}).
+			Where("project_id IN ? AND deleted_at IS NULL", projectIDs).
+			Order("id ASC").
+			Find(&columns).Error; err != nil {
+			return nil, err
+		}
+	}
+	columnIDs := make([]uint, 0, len(columns))
+	for _, col := range columns {
+		columnIDs = append(columnIDs, col.ID)
+	}
+	stats, err := selectColumnStats(columnIDs, userID, time.Unix(startTime, 0), time.Unix(endTime, 0))
+	if err != nil {
+		return nil, err
+	}
+	byProject := make(map[uint][]Column, len(projects))
+	for _, col := range columns {
+		byProject[col.ProjectID] = append(byProject[col.ProjectID], Column{Column: col, stat: stats[col.ID]})
+	}
+	result := &Activity{Activity: *a}
+	for _, p := range projects {
+		result.projects = append(result.projects, Project{Project: p, columns: byProject[p.ID]})
+	}
+	return result, nil
+}
@@ -0,0 +1,98 @@
+// Package archive 打卡图片归档：将栏目、项目或活动下的全部打卡图片按 栏目/学号/日期 打包为 zip，
+// 附带 manifest.csv 记录每个文件对应的打卡，打包通过导出任务在后台完成
+package archive
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"activity-punch-system/internal/module/stats/export"
+	"activity-punch-system/tools"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var Log *slog.Logger
+
+const (
+	kindColumnImages   = "column_images"
+	kindProjectImages  = "project_images"
+	kindActivityImages = "activity_images"
+)
+
+// RegisterExports 注册图片归档的导出类型
+func RegisterExports() {
+	export.Register(kindColumnImages, buildImages)
+	export.Register(kindProjectImages, buildImages)
+	export.Register(kindActivityImages, buildImages)
+}
+
+// ColumnImages 提交栏目打卡图片归档任务
+func ColumnImages(c *gin.Context) {
+	submit(c, kindColumnImages)
+}
+
+// ProjectImages 提交项目打卡图片归档任务
+func ProjectImages(c *gin.Context) {
+	submit(c, kindProjectImages)
+}
+
+// ActivityImages 提交活动打卡图片归档任务
+func ActivityImages(c *gin.Context) {
+	submit(c, kindActivityImages)
+}
+
+// submit 校验请求者为目标所属活动的所有者后提交归档任务
+func submit(c *gin.Context, kind string) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("ID错误"))
+		return
+	}
+	a, name, err := resolveTarget(kind, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrNotFound)
+			return
+		}
+		Log.Error("数据库 查询归档对象失败", "error", err.Error(), "kind", kind, "id", id)
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+	role, err := model.MemberRole(database.DB, a.ID, user.StudentID)
+	if err != nil {
+		Log.Error("数据库 查询活动成员失败", "error", err.Error(), "activity_id", a.ID)
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+	if role < model.MemberManager {
+		response.Fail(c, response.ErrForbidden.WithTips("无权导出该活动的打卡图片"))
+		return
+	}
+	job := &model.ExportJob{
+		Kind:        kind,
+		ActivityID:  a.ID,
+		TargetID:    uint(id),
+		UserID:      user.ID,
+		Format:      "zip",
+		FileName:    fmt.Sprintf("%s打卡图片.zip", name),
+		ContentType: tools.ZipContentType,
+	}
+	if err := export.Submit(job); err != nil {
+		Log.Error("提交导出任务失败", "error", err.Error())
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+	response.Success(c, job)
+}
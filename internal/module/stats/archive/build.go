@@ -0,0 +1,150 @@
+package archive
+
+import (
+	"activity-punch-system/internal/global/clock"
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/pictureBed"
+	"activity-punch-system/internal/model"
+	"activity-punch-system/tools"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// 单张图片的下载超时
+const objectTimeout = 2 * time.Minute
+
+// manifestRow manifest.csv 中的一行，Error 不为空时该图片未能打包
+type manifestRow struct {
+	File      string `json:"file" excel:"文件"`
+	PunchID   uint   `json:"punch_id" excel:"打卡ID"`
+	ColumnID  uint   `json:"column_id" excel:"栏目ID"`
+	Column    string `json:"column" excel:"栏目"`
+	StudentID string `json:"student_id" excel:"学号"`
+	Name      string `json:"name" excel:"姓名"`
+	PunchedAt string `json:"punched_at" excel:"打卡时间"`
+	Status    int    `json:"status" excel:"审核状态"`
+	Content   string `json:"content" excel:"打卡内容"`
+	ImgURL    string `json:"img_url" excel:"原始地址"`
+	Error     string `json:"error" excel:"错误"`
+}
+
+// buildImages 逐张从图床读取图片写入 zip，不在内存中保留图片内容，最后写入 manifest.csv
+func buildImages(job *model.ExportJob, w io.Writer, progress func(int)) error {
+	columnIDs, err := selectColumnIDs(job.Kind, job.TargetID)
+	if err != nil {
+		return err
+	}
+	images, err := selectImages(columnIDs)
+	if err != nil {
+		return err
+	}
+	// 归档范围内的栏目属于同一活动，图片按活动日界规则下的打卡日期归入目录
+	day := clock.Default()
+	if len(columnIDs) > 0 {
+		if day, err = model.ColumnDay(database.DB, columnIDs[0]); err != nil {
+			return err
+		}
+	}
+	progress(5)
+
+	store, err := pictureBed.New(context.Background())
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	manifest := make([]manifestRow, 0, len(images))
+	index := 0
+	for i, img := range images {
+		punchedAt := img.CreatedAt.In(day.Location)
+		if i > 0 && images[i-1].PunchID == img.PunchID {
+			index++
+		} else {
+			index = 1
+		}
+		row := manifestRow{
+			PunchID:   img.PunchID,
+			ColumnID:  img.ColumnID,
+			Column:    img.ColumnName,
+			StudentID: img.StudentID,
+			Name:      img.Name,
+			PunchedAt: punchedAt.Format(time.DateTime),
+			Status:    img.Status,
+			Content:   img.Content,
+			ImgURL:    img.ImgURL,
+		}
+		key, ok := pictureBed.ResolveKey(store, img.ImgURL)
+		if !ok {
+			row.Error = "不是图床中的对象"
+		} else {
+			row.File = path.Join(
+				fmt.Sprintf("%d_%s", img.ColumnID, safeName(img.ColumnName)),
+				safeName(img.StudentID),
+				day.Date(img.CreatedAt).Format(time.DateOnly),
+				fmt.Sprintf("%d_%d%s", img.PunchID, index, strings.ToLower(path.Ext(key))),
+			)
+			if written, err := copyObject(zw, store, key, row.File, punchedAt); err != nil {
+				Log.Warn("打包打卡图片失败", "error", err.Error(), "job_id", job.ID, "img_id", img.ID)
+				row.Error = err.Error()
+				if !written {
+					row.File = ""
+				}
+			}
+		}
+		manifest = append(manifest, row)
+		progress(5 + 90*(i+1)/len(images))
+	}
+
+	mw, err := zw.Create("manifest.csv")
+	if err != nil {
+		return err
+	}
+	tw, err := tools.NewTableWriter(tools.FormatCSV, mw, false)
+	if err != nil {
+		return err
+	}
+	if err := tools.WriteTable(tw, "manifest", manifest); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// copyObject 将对象以不压缩的方式写为 zip 中的一个文件，图片本身已是压缩格式；
+// written 表示 zip 中已创建该文件，此时出错文件内容不完整
+func copyObject(zw *zip.Writer, store pictureBed.Storage, key, name string, modified time.Time) (written bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), objectTimeout)
+	defer cancel()
+	body, err := store.GetObject(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = body.Close() }()
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: modified})
+	if err != nil {
+		return false, err
+	}
+	_, err = io.Copy(fw, body)
+	return true, err
+}
+
+// safeName 去掉文件名中不允许出现的字符
+func safeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "_"
+	}
+	return name
+}
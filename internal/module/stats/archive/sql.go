@@ -0,0 +1,101 @@
+package archive
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/model"
+	"activity-punch-system/tools"
+	"fmt"
+	"time"
+)
+
+// resolveTarget 查询归档对象所属的活动与对象名称
+func resolveTarget(kind string, id uint) (*model.Activity, string, error) {
+	var activityID uint
+	var name string
+	switch kind {
+	case kindColumnImages:
+		var column model.Column
+		if err := database.DB.Preload("Project").First(&column, id).Error; err != nil {
+			return nil, "", err
+		}
+		activityID, name = column.Project.ActivityID, fmt.Sprintf("栏目%d(%s)", column.ID, tools.ShortName(column.Name))
+	case kindProjectImages:
+		var project model.Project
+		if err := database.DB.First(&project, id).Error; err != nil {
+			return nil, "", err
+		}
+		activityID, name = project.ActivityID, fmt.Sprintf("项目%d(%s)", project.ID, tools.ShortName(project.Name))
+	case kindActivityImages:
+		activityID = id
+	default:
+		return nil, "", fmt.Errorf("未知的归档类型: %s", kind)
+	}
+	var a model.Activity
+	if err := database.DB.First(&a, activityID).Error; err != nil {
+		return nil, "", err
+	}
+	if kind == kindActivityImages {
+		name = fmt.Sprintf("活动%d(%s)", a.ID, tools.ShortName(a.Name))
+	}
+	return &a, name, nil
+}
+
+// selectColumnIDs 查询归档对象下未删除的栏目
+func selectColumnIDs(kind string, id uint) ([]uint, error) {
+	var ids []uint
+	db := database.DB.Table("`column` c").Where("c.deleted_at IS NULL")
+	switch kind {
+	case kindColumnImages:
+		db = db.Where("c.id = ?", id)
+	case kindProjectImages:
+		db = db.Where("c.project_id = ?", id)
+	case kindActivityImages:
+		db = db.Joins("JOIN project p ON p.id = c.project_id AND p.deleted_at IS NULL").
+			Where("p.activity_id = ?", id)
+	default:
+		return nil, fmt.Errorf("未知的归档类型: %s", kind)
+	}
+	return ids, db.Order("c.id ASC").Pluck("c.id", &ids).Error
+}
+
+// imageRow 一张打卡图片及其所属打卡、用户和栏目的信息
+type imageRow struct {
+	ID         uint      `gorm:"column:id"`
+	ImgURL     string    `gorm:"column:img_url"`
+	PunchID    uint      `gorm:"column:punch_id"`
+	Content    string    `gorm:"column:content"`
+	Status     int       `gorm:"column:status"`
+	CreatedAt  time.Time `gorm:"column:created_at"`
+	StudentID  string    `gorm:"column:student_id"`
+	Name       string    `gorm:"column:name"`
+	ColumnID   uint      `gorm:"column:column_id"`
+	ColumnName string    `gorm:"column:column_name"`
+}
+
+// selectImages 查询栏目下全部未删除打卡的图片，按 栏目、学号、打卡 排列
+func selectImages(columnIDs []uint) ([]imageRow, error) {
+	var rows []imageRow
+	if len(columnIDs) == 0 {
+		return rows, nil
+	}
+	err := database.DB.Table("punch_img pi").
+		Select(`
+			pi.id,
+			pi.img_url,
+			pi.punch_id,
+			p.content,
+			p.status,
+			p.created_at,
+			u.student_id,
+			u.name,
+			c.id AS column_id,
+			c.name AS column_name
+		`).
+		Joins("JOIN punch p ON p.id = pi.punch_id AND p.deleted_at IS NULL").
+		Joins("JOIN user u ON u.id = p.user_id").
+		Joins("JOIN `column` c ON c.id = p.column_id").
+		Where("pi.deleted_at IS NULL AND p.column_id IN ?", columnIDs).
+		Order("c.id ASC, u.student_id ASC, p.id ASC, pi.id ASC").
+		Scan(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,208 @@
+// Package calendar 用户打卡日历（热力图），指定活动时按活动的日界规则分桶，否则按默认时区的自然日分桶
+package calendar
+
+import (
+	"activity-punch-system/internal/global/clock"
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var Log *slog.Logger
+
+// maxDays 单次查询允许的最大天数
+const maxDays = 366
+
+type day struct {
+	Date          string `json:"date"`
+	PunchCount    int    `json:"punch_count"`    // 当天未删除的打卡数
+	ApprovedCount int    `json:"approved_count"` // 当天审核通过的打卡数
+	Points        uint   `json:"points"`         // 当天打卡所获得的积分（含完成奖励）
+	StreakCounted bool   `json:"streak_counted"` // 当天是否计入连续打卡（与 Continuity 一致，提交即计入，删除不回退）
+}
+
+// compactYear 年视图，各数组下标为距 start_date 的天数，streak 为 0/1 组成的字符串
+type compactYear struct {
+	Year           int    `json:"year"`
+	StartDate      string `json:"start_date"`
+	Days           int    `json:"days"`
+	ActiveDays     int    `json:"active_days"`
+	PunchCounts    []int  `json:"punch_counts"`
+	ApprovedCounts []int  `json:"approved_counts"`
+	Points         []uint `json:"points"`
+	Streak         string `json:"streak"`
+}
+
+// Calendar 获取用户的打卡日历
+// 查询参数:
+//   - activity_id: 可选，不传则统计所有活动
+//   - user_id: 可选，仅管理员可查询其他用户
+//   - start_date/end_date: 格式 2006-01-02，闭区间，默认最近一年
+//   - year: 给定年份时返回该自然年的紧凑视图，忽略 start_date/end_date
+func Calendar(c *gin.Context) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	userID := user.ID
+	if s := c.Query("user_id"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 0)
+		if err != nil {
+			response.Fail(c, response.ErrInvalidRequest.WithTips("user_id 格式错误"))
+			return
+		}
+		if uint(id) != user.ID && user.RoleID < 1 {
+			response.Fail(c, response.ErrForbidden.WithTips("无权查看其他用户的打卡日历"))
+			return
+		}
+		userID = uint(id)
+	}
+	var activityID uint
+	if s := c.Query("activity_id"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 0)
+		if err != nil {
+			response.Fail(c, response.ErrInvalidRequest.WithTips("activity_id 格式错误"))
+			return
+		}
+		activityID = uint(id)
+	}
+	rule := clock.Default()
+	if activityID != 0 {
+		var err error
+		if rule, err = model.ActivityDay(database.DB, activityID); err != nil {
+			Log.Error("数据库 查询活动日界规则失败", "error", err.Error(), "activity_id", activityID)
+			response.Fail(c, response.ErrDatabase)
+			return
+		}
+	}
+
+	var start, end time.Time // 日期范围 [start, end)，均为当地零点
+	year := 0
+	if s := c.Query("year"); s != "" {
+		y, err := strconv.Atoi(s)
+		if err != nil || y < 2000 || y > 9999 {
+			response.Fail(c, response.ErrInvalidRequest.WithTips("year 格式错误"))
+			return
+		}
+		year = y
+		start = time.Date(y, 1, 1, 0, 0, 0, 0, rule.Location)
+		end = start.AddDate(1, 0, 0)
+	} else {
+		today := rule.Date(time.Now())
+		start, end = today.AddDate(-1, 0, 1), today.AddDate(0, 0, 1)
+		if s := c.Query("start_date"); s != "" {
+			t, err := rule.ParseDate("2006-01-02", s)
+			if err != nil {
+				response.Fail(c, response.ErrInvalidRequest.WithTips("start_date 格式错误，应为 2006-01-02"))
+				return
+			}
+			start = t
+		}
+		if s := c.Query("end_date"); s != "" {
+			t, err := rule.ParseDate("2006-01-02", s)
+			if err != nil {
+				response.Fail(c, response.ErrInvalidRequest.WithTips("end_date 格式错误，应为 2006-01-02"))
+				return
+			}
+			end = t.AddDate(0, 0, 1)
+		}
+	}
+	if !start.Before(end) {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("start_date 不能晚于 end_date"))
+		return
+	}
+	if end.AddDate(0, 0, -maxDays).After(start) {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("查询范围不能超过 366 天"))
+		return
+	}
+
+	days, err := buildDays(rule, userID, activityID, start, end)
+	if err != nil {
+		Log.Error("数据库 查询打卡日历失败", "error", err.Error(), "user_id", userID, "activity_id", activityID)
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+
+	if year != 0 {
+		response.Success(c, compact(year, start, days))
+		return
+	}
+	response.Success(c, gin.H{
+		"user_id":     userID,
+		"activity_id": activityID,
+		"days":        days,
+	})
+}
+
+// buildDays 将日期 [start, end) 内的打卡与得分按日界规则分桶，没有记录的日期也会返回
+func buildDays(rule clock.Day, userID, activityID uint, start, end time.Time) ([]day, error) {
+	punches, err := selectPunches(userID, activityID, rule.StartOf(start), rule.StartOf(end))
+	if err != nil {
+		return nil, err
+	}
+	scores, err := selectScores(userID, activityID, rule.StartOf(start), rule.StartOf(end))
+	if err != nil {
+		return nil, err
+	}
+
+	var days []day
+	index := make(map[time.Time]int)
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		index[d] = len(days)
+		days = append(days, day{Date: d.Format("2006-01-02")})
+	}
+	for _, p := range punches {
+		i, ok := index[rule.Date(p.CreatedAt)]
+		if !ok {
+			continue
+		}
+		days[i].StreakCounted = true
+		if p.Deleted {
+			continue
+		}
+		days[i].PunchCount++
+		if p.Status == 1 {
+			days[i].ApprovedCount++
+		}
+	}
+	for _, s := range scores {
+		if i, ok := index[rule.Date(s.PunchDate)]; ok {
+			days[i].Points += s.Count
+		}
+	}
+	return days, nil
+}
+
+func compact(year int, start time.Time, days []day) compactYear {
+	result := compactYear{
+		Year:           year,
+		StartDate:      start.Format("2006-01-02"),
+		Days:           len(days),
+		PunchCounts:    make([]int, len(days)),
+		ApprovedCounts: make([]int, len(days)),
+		Points:         make([]uint, len(days)),
+	}
+	var streak strings.Builder
+	streak.Grow(len(days))
+	for i, d := range days {
+		result.PunchCounts[i] = d.PunchCount
+		result.ApprovedCounts[i] = d.ApprovedCount
+		result.Points[i] = d.Points
+		if d.StreakCounted {
+			result.ActiveDays++
+			streak.WriteByte('1')
+		} else {
+			streak.WriteByte('0')
+		}
+	}
+	result.Streak = streak.String()
+	return result
+}
@@ -0,0 +1,55 @@
+package calendar
+
+import (
+	"activity-punch-system/internal/global/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type punchRow struct {
+	CreatedAt time.Time
+	Status    int
+	Deleted   bool
+}
+
+type scoreRow struct {
+	PunchDate time.Time
+	Count     uint
+}
+
+// selectPunches 查询用户在 [start, end) 内的所有打卡（含已删除的，用于判断连续打卡），activityID 为 0 时不限活动
+func selectPunches(userID, activityID uint, start, end time.Time) ([]punchRow, error) {
+	var rows []punchRow
+	query := database.DB.Table("punch").
+		Select("punch.created_at, punch.status, punch.deleted_at IS NOT NULL AS deleted").
+		Where("punch.user_id = ? AND punch.created_at >= ? AND punch.created_at < ?", userID, start, end)
+	query = withActivity(query, "punch.column_id", activityID)
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// selectScores 查询用户在 [start, end) 内按打卡日期记录的得分
+func selectScores(userID, activityID uint, start, end time.Time) ([]scoreRow, error) {
+	var rows []scoreRow
+	query := database.DB.Table("score").
+		Select("score.punch_date, score.count").
+		Where("score.user_id = ? AND score.deleted_at IS NULL AND score.punch_date >= ? AND score.punch_date < ?", userID, start, end)
+	query = withActivity(query, "score.column_id", activityID)
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func withActivity(query *gorm.DB, columnField string, activityID uint) *gorm.DB {
+	if activityID == 0 {
+		return query
+	}
+	return query.
+		Joins("JOIN `column` ON `column`.id = "+columnField).
+		Joins("JOIN project ON project.id = `column`.project_id").
+		Where("project.activity_id = ?", activityID)
+}
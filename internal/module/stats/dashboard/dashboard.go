@@ -0,0 +1,115 @@
+// Package dashboard 活动看板：每日活跃、新增、审核情况、栏目参与度和留存漏斗，
+// 重度聚合由 StartScheduler 每日预先计算，接口只读取计算结果
+package dashboard
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/permission"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/global/schedule"
+	"activity-punch-system/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var Log *slog.Logger
+
+// manualRefreshInterval 同一活动两次手动刷新看板的最小间隔
+const manualRefreshInterval = time.Minute
+
+// Dashboard 获取活动看板，仅活动所有者可查看，refresh=true 时立即刷新（有频率限制）
+func Dashboard(c *gin.Context) {
+	a, ok := ownedActivity(c)
+	if !ok {
+		return
+	}
+
+	d, err := selectDashboard(a.ID)
+	if err != nil {
+		Log.Error("数据库 查询活动看板失败", "error", err.Error(), "activity_id", a.ID)
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+	refresh := d == nil
+	if !refresh && c.Query("refresh") == "true" {
+		// 手动刷新与定时任务一样只重新计算有变化的日期，且同一活动每分钟最多刷新一次，超出时返回已有结果
+		if refresh, err = schedule.Acquire(fmt.Sprintf("stats:dashboard:%d", a.ID), manualRefreshInterval); err != nil {
+			Log.Warn("获取看板刷新锁失败", "error", err.Error(), "activity_id", a.ID)
+		}
+	}
+	if refresh {
+		if err := Refresh(a); err != nil {
+			Log.Error("刷新活动看板失败", "error", err.Error(), "activity_id", a.ID)
+			response.Fail(c, response.ErrDatabase.WithOrigin(err))
+			return
+		}
+		if d, err = selectDashboard(a.ID); err != nil || d == nil {
+			Log.Error("数据库 查询活动看板失败", "error", err, "activity_id", a.ID)
+			response.Fail(c, response.ErrDatabase)
+			return
+		}
+	}
+	days, err := selectDailyStats(a.ID)
+	if err != nil {
+		Log.Error("数据库 查询活动每日统计失败", "error", err.Error(), "activity_id", a.ID)
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+
+	var columns []columnParticipation
+	var funnel []funnelStep
+	if err := json.Unmarshal([]byte(d.Columns), &columns); err != nil {
+		Log.Error("解析栏目参与度失败", "error", err.Error(), "activity_id", a.ID)
+		response.Fail(c, response.ErrServerInternal)
+		return
+	}
+	if err := json.Unmarshal([]byte(d.Funnel), &funnel); err != nil {
+		Log.Error("解析留存漏斗失败", "error", err.Error(), "activity_id", a.ID)
+		response.Fail(c, response.ErrServerInternal)
+		return
+	}
+
+	var approvalRate, rejectionRate float64
+	if d.PunchCount > 0 {
+		approvalRate = float64(d.ApprovedCount) / float64(d.PunchCount)
+		rejectionRate = float64(d.RejectedCount) / float64(d.PunchCount)
+	}
+	response.Success(c, gin.H{
+		"summary":        d,
+		"approval_rate":  approvalRate,
+		"rejection_rate": rejectionRate,
+		"daily":          days,
+		"columns":        columns,
+		"funnel":         funnel,
+	})
+}
+
+// ownedActivity 校验路径参数中的活动存在且请求者为其所有者
+func ownedActivity(c *gin.Context) (*model.Activity, bool) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return nil, false
+	}
+	var a model.Activity
+	if err := database.DB.First(&a, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrNotFound.WithTips("活动不存在"))
+			return nil, false
+		}
+		Log.Error("查询 activity 表错误", "error", err)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return nil, false
+	}
+	if !permission.RequireRole(c, a.ID, user.StudentID, model.MemberOwner, "查看该活动的统计") {
+		return nil, false
+	}
+	return &a, true
+}
@@ -0,0 +1,40 @@
+package dashboard
+
+import (
+	"activity-punch-system/internal/global/schedule"
+	"time"
+)
+
+// StartScheduler 启动看板的每日预计算任务，每小时第 10 分钟检查一次，
+// 活动所在时区在最近一小时内切换到新的一天时刷新，此时前一天的数据已完整
+func StartScheduler() {
+	schedule.Hourly("stats:dashboard", 10, refreshAll)
+}
+
+func refreshAll() {
+	now := time.Now()
+	activities, err := selectRefreshTargets(now)
+	if err != nil {
+		Log.Error("数据库 查询需要刷新看板的活动失败", "error", err.Error())
+		return
+	}
+	count := 0
+	for i := range activities {
+		a := &activities[i]
+		day := a.Day()
+		start := day.Start(now)
+		if now.Sub(start) >= time.Hour {
+			continue
+		}
+		// 已开始且结束不超过 1 天
+		if a.StartDate > day.Int(now) || a.EndDate < day.Int(start.Add(-time.Second)) {
+			continue
+		}
+		if err := Refresh(a); err != nil {
+			Log.Error("刷新活动看板失败", "error", err.Error(), "activity_id", a.ID)
+			continue
+		}
+		count++
+	}
+	Log.Info("活动看板刷新完成", "count", count)
+}
@@ -0,0 +1,199 @@
+package dashboard
+
+import (
+	"activity-punch-system/internal/global/clock"
+	"activity-punch-system/internal/model"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// maxFunnelDays 漏斗最多统计到第几天
+const maxFunnelDays = 60
+
+// daysBetween 两个日期相差的天数，按四舍五入处理夏令时造成的 23 或 25 小时
+func daysBetween(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+// dayRule 日界规则的标识，规则变化后已保存的每日统计需全部重新计算
+func dayRule(d clock.Day) string {
+	return fmt.Sprintf("%s|%d", d.Location.String(), d.Rollover)
+}
+
+type columnParticipation struct {
+	columnRow
+	Participants      uint    `json:"participants"`       // 在该栏目打过卡的人数
+	PunchCount        uint    `json:"punch_count"`        // 该栏目打卡数
+	ApprovedCount     uint    `json:"approved_count"`     // 该栏目审核通过数
+	ParticipationRate float64 `json:"participation_rate"` // 占活动参与人数的比例
+}
+
+// funnelStep 至少有 Days 天打过卡的人数，Rate 为相对首日（即全部参与者）的留存比例
+type funnelStep struct {
+	Days  int     `json:"days"`
+	Users uint    `json:"users"`
+	Rate  float64 `json:"rate"`
+}
+
+// Refresh 更新活动看板，按活动的日界规则从活动开始计算到今天（或活动结束日）。
+// 聚合均在数据库中完成；每日统计只重新计算上次刷新后有打卡新增、修改、审核或删除的日期，
+// 首次计算或日界规则变化时重新计算全部日期
+func Refresh(a *model.Activity) error {
+	prev, err := selectDashboard(a.ID)
+	if err != nil {
+		return err
+	}
+	now := time.Now() // 在查询前取时间，计算期间发生的修改留到下次刷新
+	day := a.Day()
+	rule := dayRule(day)
+	full := prev == nil || prev.DayRule != rule
+
+	span, err := selectPunchSpan(a.ID)
+	if err != nil {
+		return err
+	}
+	today := day.Date(now)
+	first, last := today, today
+	if start, err := day.ParseDate("20060102", strconv.FormatInt(a.StartDate, 10)); err == nil {
+		first = start
+	} else if span.First != nil {
+		first = day.Date(*span.First)
+	}
+	if end, err := day.ParseDate("20060102", strconv.FormatInt(a.EndDate, 10)); err == nil && end.Before(last) {
+		last = end
+	}
+	var days []time.Time
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+
+	existing := make(map[int64]model.ActivityDailyStat)
+	if !full {
+		stats, err := selectDailyStats(a.ID)
+		if err != nil {
+			return err
+		}
+		for _, s := range stats {
+			existing[s.Date] = s
+		}
+	}
+	newParticipants, err := selectNewParticipants(a.ID, day, days)
+	if err != nil {
+		return err
+	}
+
+	// 需要重新计算的日期：尚无记录、当日首次打卡人数变化，或有打卡在上次刷新后发生变化
+	dirty := make(map[int64]bool)
+	for _, d := range days {
+		date := day.Int(day.StartOf(d))
+		if s, ok := existing[date]; full || !ok || s.NewParticipants != newParticipants[date] {
+			dirty[date] = true
+		}
+	}
+	if !full {
+		changed, err := selectChangedPunchDates(a.ID, day, days, prev.RefreshedAt)
+		if err != nil {
+			return err
+		}
+		for _, date := range changed {
+			dirty[date] = true
+		}
+	}
+	var dirtyDays []time.Time
+	for _, d := range days {
+		if dirty[day.Int(day.StartOf(d))] {
+			dirtyDays = append(dirtyDays, d)
+		}
+	}
+
+	daily, err := selectDailyCounts(a.ID, day, dirtyDays)
+	if err != nil {
+		return err
+	}
+	latencies, err := selectDailyMedianLatency(a.ID, day, dirtyDays)
+	if err != nil {
+		return err
+	}
+	dailyStats := make([]model.ActivityDailyStat, 0, len(dirtyDays))
+	for _, d := range dirtyDays {
+		date := day.Int(day.StartOf(d))
+		stat := daily[date]
+		stat.ActivityID, stat.Date, stat.UpdatedAt = a.ID, date, now
+		stat.NewParticipants = newParticipants[date]
+		stat.MedianReviewLatency = latencies[date]
+		dailyStats = append(dailyStats, stat)
+	}
+
+	summary, err := selectSummary(a.ID)
+	if err != nil {
+		return err
+	}
+	summary.ActivityID, summary.RefreshedAt, summary.DayRule = a.ID, now, rule
+
+	columns, err := selectActivityColumns(a.ID)
+	if err != nil {
+		return err
+	}
+	columnStats, err := selectColumnStats(a.ID)
+	if err != nil {
+		return err
+	}
+	participations := make([]columnParticipation, 0, len(columns))
+	for _, c := range columns {
+		cp := columnParticipation{columnRow: c}
+		if s, ok := columnStats[c.ID]; ok {
+			cp.Participants, cp.PunchCount, cp.ApprovedCount = s.Participants, s.PunchCount, s.ApprovedCount
+			if summary.Participants > 0 {
+				cp.ParticipationRate = float64(cp.Participants) / float64(summary.Participants)
+			}
+		}
+		participations = append(participations, cp)
+	}
+
+	// 按每人打卡的天数累计出"至少打卡 N 天"的人数，范围外的打卡同样计入
+	spanDays := days
+	if span.First != nil {
+		for d := day.Date(*span.First); d.Before(first); d = d.AddDate(0, 0, 1) {
+			spanDays = append(spanDays, d)
+		}
+		for d := last.AddDate(0, 0, 1); !d.After(day.Date(*span.Last)); d = d.AddDate(0, 0, 1) {
+			spanDays = append(spanDays, d)
+		}
+	}
+	activeDays, err := selectActiveDayCounts(a.ID, day, spanDays)
+	if err != nil {
+		return err
+	}
+	maxDays := daysBetween(first, last) + 1
+	if maxDays > maxFunnelDays {
+		maxDays = maxFunnelDays
+	}
+	dayCounts := make([]uint, maxDays+1)
+	for n, users := range activeDays {
+		dayCounts[min(n, maxDays)] += users
+	}
+	funnel := make([]funnelStep, maxDays)
+	var atLeast uint
+	for n := maxDays; n >= 1; n-- {
+		atLeast += dayCounts[n]
+		funnel[n-1] = funnelStep{Days: n, Users: atLeast}
+		if summary.Participants > 0 {
+			funnel[n-1].Rate = float64(atLeast) / float64(summary.Participants)
+		}
+	}
+
+	columnsJSON, err := json.Marshal(participations)
+	if err != nil {
+		return err
+	}
+	funnelJSON, err := json.Marshal(funnel)
+	if err != nil {
+		return err
+	}
+	summary.Columns = string(columnsJSON)
+	summary.Funnel = string(funnelJSON)
+	return saveDashboard(dailyStats, summary, day.Int(day.StartOf(first)), day.Int(day.StartOf(last)))
+}
@@ -0,0 +1,210 @@
+package dashboard
+
+import (
+	"activity-punch-system/internal/global/clock"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"activity-punch-system/tools"
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	granularityDay  = "day"
+	granularityWeek = "week"
+)
+
+// cohort 同一期（天/周）首次打卡的参与者，Active[k] 为其中在第 k 期仍有打卡的人数，第 0 期即首次打卡当期
+type cohort struct {
+	Cohort    string    `json:"cohort"` // 该期开始日期
+	Size      uint      `json:"size"`
+	Active    []uint    `json:"active"`
+	Retention []float64 `json:"retention"`
+}
+
+// retentionInExcel 导出时将每个批次展开为逐期的行
+type retentionInExcel struct {
+	Cohort    string  `excel:"批次"`
+	Size      uint    `excel:"批次人数"`
+	Period    int     `excel:"第几期"`
+	Active    uint    `excel:"仍在打卡人数"`
+	Retention float64 `excel:"留存率"`
+}
+
+// Retention 活动的批次留存分析，按首次打卡的天或周分批
+// 查询参数: granularity=day|week（默认 week），college、grade 可选
+func Retention(c *gin.Context) {
+	a, ok := ownedActivity(c)
+	if !ok {
+		return
+	}
+	granularity, ok := granularityParam(c)
+	if !ok {
+		return
+	}
+	cohorts, err := buildCohorts(a, granularity, c.Query("college"), c.Query("grade"))
+	if err != nil {
+		Log.Error("数据库 查询留存数据失败", "error", err.Error(), "activity_id", a.ID)
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+	response.Success(c, gin.H{
+		"granularity": granularity,
+		"cohorts":     cohorts,
+	})
+}
+
+// RetentionExport 以 Excel 导出批次留存分析，参数同 Retention
+func RetentionExport(c *gin.Context) {
+	a, ok := ownedActivity(c)
+	if !ok {
+		return
+	}
+	granularity, ok := granularityParam(c)
+	if !ok {
+		return
+	}
+	cohorts, err := buildCohorts(a, granularity, c.Query("college"), c.Query("grade"))
+	if err != nil {
+		Log.Error("数据库 查询留存数据失败", "error", err.Error(), "activity_id", a.ID)
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+	var rows []retentionInExcel
+	for _, ch := range cohorts {
+		for k := range ch.Active {
+			rows = append(rows, retentionInExcel{
+				Cohort:    ch.Cohort,
+				Size:      ch.Size,
+				Period:    k,
+				Active:    ch.Active[k],
+				Retention: ch.Retention[k],
+			})
+		}
+	}
+
+	name := fmt.Sprintf("活动%d留存(%s)", a.ID, granularity)
+	f := excelize.NewFile()
+	defer func() { _ = f.Close() }()
+	if err := tools.ExportToExcel(f, name, rows); err != nil {
+		Log.Error("导出excel错误", "error", err)
+		response.Fail(c, response.ErrServerInternal)
+		return
+	}
+	if len(rows) > 0 {
+		_ = f.DeleteSheet("Sheet1")
+	}
+	buf := &bytes.Buffer{}
+	if err := f.Write(buf); err != nil {
+		Log.Error("导出excel错误", "error", err)
+		response.Fail(c, response.ErrServerInternal)
+		return
+	}
+	c.Header("Content-Type", tools.ExcelContentType)
+	c.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.QueryEscape(name+".xlsx"))
+	if _, err := c.Writer.Write(buf.Bytes()); err != nil {
+		Log.Error("导出excel错误", "error", err)
+	}
+}
+
+func granularityParam(c *gin.Context) (string, bool) {
+	switch g := c.DefaultQuery("granularity", granularityWeek); g {
+	case granularityDay, granularityWeek:
+		return g, true
+	default:
+		response.Fail(c, response.ErrInvalidRequest.WithTips("granularity 只能为 day 或 week"))
+		return "", false
+	}
+}
+
+// periodStart 返回 t 所在期（按活动日界规则划分的自然日，或以周一为起点的自然周）的开始日期
+func periodStart(day clock.Day, t time.Time, granularity string) time.Time {
+	d := day.Date(t)
+	if granularity == granularityWeek {
+		d = d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+	}
+	return d
+}
+
+// periodIndex 返回 to 所在期相对 from 所在期的期数
+func periodIndex(from, to time.Time, granularity string) int {
+	days := daysBetween(from, to)
+	if granularity == granularityWeek {
+		return days / 7
+	}
+	return days
+}
+
+func buildCohorts(a *model.Activity, granularity, college, grade string) ([]cohort, error) {
+	span, err := selectPunchSpan(a.ID)
+	if err != nil || span.First == nil {
+		return []cohort{}, err
+	}
+
+	// 统计截止到今天或活动结束当期
+	day := a.Day()
+	last := periodStart(day, time.Now(), granularity)
+	if end, err := day.ParseDate("20060102", strconv.FormatInt(a.EndDate, 10)); err == nil {
+		if endPeriod := periodStart(day, day.StartOf(end), granularity); endPeriod.Before(last) {
+			last = endPeriod
+		}
+	}
+
+	// 覆盖全部打卡的各期，首次打卡与有打卡的期在数据库中按人聚合
+	n := 1
+	if granularity == granularityWeek {
+		n = 7
+	}
+	periodEnd := last
+	if p := periodStart(day, *span.Last, granularity); p.After(periodEnd) {
+		periodEnd = p
+	}
+	var starts []time.Time
+	byDate := make(map[int64]time.Time)
+	for p := periodStart(day, *span.First, granularity); !p.After(periodEnd); p = p.AddDate(0, 0, n) {
+		starts = append(starts, p)
+		byDate[day.Int(day.StartOf(p))] = p
+	}
+	rows, err := selectCohortActivity(a.ID, day, starts, n, college, grade)
+	if err != nil {
+		return nil, err
+	}
+
+	byStart := make(map[time.Time]*cohort)
+	for _, r := range rows {
+		start, period := byDate[r.Cohort], byDate[r.Period]
+		ch, ok := byStart[start]
+		if !ok {
+			size := periodIndex(start, last, granularity) + 1
+			if size < 1 {
+				size = 1
+			}
+			ch = &cohort{Cohort: start.Format("2006-01-02"), Active: make([]uint, size)}
+			byStart[start] = ch
+		}
+		if r.Period == r.Cohort {
+			ch.Size = r.Users
+		}
+		if k := periodIndex(start, period, granularity); k < len(ch.Active) {
+			ch.Active[k] += r.Users
+		}
+	}
+
+	cohorts := make([]cohort, 0, len(byStart))
+	for _, ch := range byStart {
+		ch.Retention = make([]float64, len(ch.Active))
+		for k, n := range ch.Active {
+			ch.Retention[k] = float64(n) / float64(ch.Size)
+		}
+		cohorts = append(cohorts, *ch)
+	}
+	sort.Slice(cohorts, func(i, j int) bool { return cohorts[i].Cohort < cohorts[j].Cohort })
+	return cohorts, nil
+}
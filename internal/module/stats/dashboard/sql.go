@@ -0,0 +1,337 @@
+package dashboard
+
+import (
+	"activity-punch-system/internal/global/clock"
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/model"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type columnRow struct {
+	ID        uint   `json:"column_id"`
+	Name      string `json:"name"`
+	ProjectID uint   `json:"project_id"`
+}
+
+// activityPunches 活动下打卡的查询，未排除已删除的打卡
+func activityPunches() *gorm.DB {
+	return database.DB.Table("punch").
+		Joins("JOIN `column` ON `column`.id = punch.column_id").
+		Joins("JOIN project ON project.id = `column`.project_id")
+}
+
+// periodTable 由每期（从开始日期起连续 n 天）的开始日期 date 及其起止时间组成的派生表，
+// 用于在 SQL 中按活动的日界规则划分打卡所属的日期或周
+func periodTable(day clock.Day, starts []time.Time, n int) *gorm.DB {
+	parts := make([]string, 0, len(starts))
+	args := make([]any, 0, len(starts)*3)
+	for _, d := range starts {
+		parts = append(parts, "SELECT ? AS date, ? AS start_at, ? AS end_at")
+		args = append(args, day.Int(day.StartOf(d)), day.StartOf(d), day.StartOf(d.AddDate(0, 0, n)))
+	}
+	return database.DB.Raw(strings.Join(parts, " UNION ALL "), args...)
+}
+
+// dayTable 以自然日为期的 periodTable
+func dayTable(day clock.Day, days []time.Time) *gorm.DB {
+	return periodTable(day, days, 1)
+}
+
+// joinPeriods 将打卡关联到其所属一期的开始日期 d.date，不在各期范围内的打卡被排除
+func joinPeriods(query *gorm.DB, day clock.Day, starts []time.Time, n int) *gorm.DB {
+	return query.Joins("JOIN (?) AS d ON punch.created_at >= d.start_at AND punch.created_at < d.end_at", periodTable(day, starts, n))
+}
+
+// joinDays 将打卡关联到其所属的日期 d.date，不在 days 范围内的打卡被排除
+func joinDays(query *gorm.DB, day clock.Day, days []time.Time) *gorm.DB {
+	return joinPeriods(query, day, days, 1)
+}
+
+type punchSpan struct {
+	First *time.Time
+	Last  *time.Time
+}
+
+// selectPunchSpan 查询活动下未删除打卡的最早与最晚时间，无打卡时均为 nil
+func selectPunchSpan(activityID uint) (punchSpan, error) {
+	var span punchSpan
+	err := activityPunches().
+		Select("MIN(punch.created_at) AS first, MAX(punch.created_at) AS last").
+		Where("project.activity_id = ? AND punch.deleted_at IS NULL", activityID).
+		Scan(&span).Error
+	return span, err
+}
+
+// selectChangedPunchDates 查询 since 之后有打卡新增、修改、审核或删除的日期
+func selectChangedPunchDates(activityID uint, day clock.Day, days []time.Time, since time.Time) ([]int64, error) {
+	var dates []int64
+	if len(days) == 0 {
+		return dates, nil
+	}
+	err := joinDays(activityPunches(), day, days).
+		Distinct("d.date").
+		Where("project.activity_id = ? AND (punch.updated_at > ? OR punch.deleted_at > ?)", activityID, since, since).
+		Pluck("d.date", &dates).Error
+	return dates, err
+}
+
+type dateCount struct {
+	Date  int64
+	Count uint
+}
+
+// selectNewParticipants 按日期统计首次打卡的人数
+func selectNewParticipants(activityID uint, day clock.Day, days []time.Time) (map[int64]uint, error) {
+	result := make(map[int64]uint)
+	if len(days) == 0 {
+		return result, nil
+	}
+	firsts := activityPunches().
+		Select("punch.user_id, MIN(punch.created_at) AS first_at").
+		Where("project.activity_id = ? AND punch.deleted_at IS NULL", activityID).
+		Group("punch.user_id")
+	var rows []dateCount
+	err := database.DB.Table("(?) AS f", firsts).
+		Select("d.date, COUNT(*) AS count").
+		Joins("JOIN (?) AS d ON f.first_at >= d.start_at AND f.first_at < d.end_at", dayTable(day, days)).
+		Group("d.date").
+		Scan(&rows).Error
+	for _, r := range rows {
+		result[r.Date] = r.Count
+	}
+	return result, err
+}
+
+// selectDailyCounts 按日期统计打卡人数、打卡数与审核结果，不含首次打卡人数与审核耗时
+func selectDailyCounts(activityID uint, day clock.Day, days []time.Time) (map[int64]model.ActivityDailyStat, error) {
+	result := make(map[int64]model.ActivityDailyStat)
+	if len(days) == 0 {
+		return result, nil
+	}
+	var rows []model.ActivityDailyStat
+	err := joinDays(activityPunches(), day, days).
+		Select("d.date, COUNT(DISTINCT punch.user_id) AS active_punchers, COUNT(*) AS punch_count, "+
+			"SUM(punch.status = 1) AS approved_count, SUM(punch.status = 2) AS rejected_count").
+		Where("project.activity_id = ? AND punch.deleted_at IS NULL", activityID).
+		Group("d.date").
+		Scan(&rows).Error
+	for _, r := range rows {
+		result[r.Date] = r
+	}
+	return result, err
+}
+
+// reviewLatencies 已审核打卡的审核耗时（秒）及其在 partition 内按耗时排序的序号与总数，
+// 序号位于中间的一或两行的平均值即为中位数。partition 为空时在全部打卡中排序
+func reviewLatencies(query *gorm.DB, partition string) *gorm.DB {
+	const latency = "TIMESTAMPDIFF(SECOND, punch.created_at, punch.reviewed_at)"
+	columns, partitionBy := "", ""
+	if partition != "" {
+		columns, partitionBy = partition+", ", "PARTITION BY "+partition
+	}
+	return query.
+		Select(fmt.Sprintf("%s%s AS latency, ROW_NUMBER() OVER (%s ORDER BY %s) AS rn, COUNT(*) OVER (%s) AS cnt",
+			columns, latency, partitionBy, latency, partitionBy)).
+		Where("punch.deleted_at IS NULL AND punch.status != 0 AND punch.reviewed_at IS NOT NULL")
+}
+
+// medianOf 取 reviewLatencies 结果中位于中间的行
+const medianOf = "t.rn IN (FLOOR((t.cnt + 1) / 2), CEIL((t.cnt + 1) / 2))"
+
+// selectDailyMedianLatency 按日期统计审核耗时中位数，无已审核打卡的日期不在结果中
+func selectDailyMedianLatency(activityID uint, day clock.Day, days []time.Time) (map[int64]int64, error) {
+	result := make(map[int64]int64)
+	if len(days) == 0 {
+		return result, nil
+	}
+	latencies := reviewLatencies(joinDays(activityPunches(), day, days), "d.date").
+		Where("project.activity_id = ?", activityID)
+	var rows []struct {
+		Date    int64
+		Latency int64
+	}
+	err := database.DB.Table("(?) AS t", latencies).
+		Select("t.date, CAST(FLOOR(AVG(t.latency)) AS SIGNED) AS latency").
+		Where(medianOf).
+		Group("t.date").
+		Scan(&rows).Error
+	for _, r := range rows {
+		result[r.Date] = r.Latency
+	}
+	return result, err
+}
+
+// selectSummary 按整个活动统计参与人数、打卡数、审核结果与审核耗时中位数
+func selectSummary(activityID uint) (*model.ActivityDashboard, error) {
+	var d model.ActivityDashboard
+	err := activityPunches().
+		Select("COUNT(DISTINCT punch.user_id) AS participants, COUNT(*) AS punch_count, "+
+			"COALESCE(SUM(punch.status = 1), 0) AS approved_count, COALESCE(SUM(punch.status = 2), 0) AS rejected_count").
+		Where("project.activity_id = ? AND punch.deleted_at IS NULL", activityID).
+		Scan(&d).Error
+	if err != nil {
+		return nil, err
+	}
+	latencies := reviewLatencies(activityPunches(), "").Where("project.activity_id = ?", activityID)
+	err = database.DB.Table("(?) AS t", latencies).
+		Select("CAST(COALESCE(FLOOR(AVG(t.latency)), 0) AS SIGNED)").
+		Where(medianOf).
+		Scan(&d.MedianReviewLatency).Error
+	return &d, err
+}
+
+type columnStat struct {
+	ColumnID      uint
+	Participants  uint
+	PunchCount    uint
+	ApprovedCount uint
+}
+
+// selectColumnStats 按栏目统计参与人数、打卡数与审核通过数
+func selectColumnStats(activityID uint) (map[uint]columnStat, error) {
+	var rows []columnStat
+	err := activityPunches().
+		Select("punch.column_id, COUNT(DISTINCT punch.user_id) AS participants, COUNT(*) AS punch_count, SUM(punch.status = 1) AS approved_count").
+		Where("project.activity_id = ? AND punch.deleted_at IS NULL", activityID).
+		Group("punch.column_id").
+		Scan(&rows).Error
+	result := make(map[uint]columnStat, len(rows))
+	for _, r := range rows {
+		result[r.ColumnID] = r
+	}
+	return result, err
+}
+
+// selectActiveDayCounts 统计打卡天数为 N 的人数，key 为 N
+func selectActiveDayCounts(activityID uint, day clock.Day, days []time.Time) (map[int]uint, error) {
+	result := make(map[int]uint)
+	if len(days) == 0 {
+		return result, nil
+	}
+	perUser := joinDays(activityPunches(), day, days).
+		Select("punch.user_id, COUNT(DISTINCT d.date) AS n").
+		Where("project.activity_id = ? AND punch.deleted_at IS NULL", activityID).
+		Group("punch.user_id")
+	var rows []struct {
+		N     int
+		Users uint
+	}
+	err := database.DB.Table("(?) AS t", perUser).
+		Select("t.n, COUNT(*) AS users").
+		Group("t.n").
+		Scan(&rows).Error
+	for _, r := range rows {
+		result[r.N] = r.Users
+	}
+	return result, err
+}
+
+// selectActivityColumns 查询活动下所有未删除的栏目
+func selectActivityColumns(activityID uint) ([]columnRow, error) {
+	var rows []columnRow
+	err := database.DB.Table("`column`").
+		Select("`column`.id, `column`.name, `column`.project_id").
+		Joins("JOIN project ON project.id = `column`.project_id").
+		Where("project.activity_id = ? AND project.deleted_at IS NULL AND `column`.deleted_at IS NULL", activityID).
+		Order("`column`.project_id ASC, `column`.id ASC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// selectRefreshTargets 查询可能需要刷新看板的活动：已开始且结束不超过 1 天的活动
+func selectRefreshTargets(now time.Time) ([]model.Activity, error) {
+	var activities []model.Activity
+	// 各时区的日期与默认时区相差不超过一天，按默认时区放宽一天筛选，由调用方按活动自身的日界规则判断
+	d := clock.Default()
+	err := database.DB.Model(&model.Activity{}).
+		Where("start_date <= ? AND end_date >= ?", d.Int(now.AddDate(0, 0, 1)), d.Int(now.AddDate(0, 0, -2))).
+		Find(&activities).Error
+	return activities, err
+}
+
+// saveDashboard 在一个事务中写入重新计算的每日统计与整体聚合结果，并删除 [first, last] 范围外的每日统计
+func saveDashboard(days []model.ActivityDailyStat, d *model.ActivityDashboard, first, last int64) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("activity_id = ? AND (date < ? OR date > ?)", d.ActivityID, first, last).
+			Delete(&model.ActivityDailyStat{}).Error; err != nil {
+			return err
+		}
+		if len(days) > 0 {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "activity_id"}, {Name: "date"}},
+				DoUpdates: clause.AssignmentColumns([]string{
+					"active_punchers", "new_participants", "punch_count",
+					"approved_count", "rejected_count", "median_review_latency", "updated_at",
+				}),
+			}).CreateInBatches(&days, 200).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(d).Error
+	})
+}
+
+func selectDailyStats(activityID uint) ([]model.ActivityDailyStat, error) {
+	var days []model.ActivityDailyStat
+	err := database.DB.Model(&model.ActivityDailyStat{}).
+		Where("activity_id = ?", activityID).
+		Order("date ASC").
+		Find(&days).Error
+	return days, err
+}
+
+// selectDashboard 未计算过时返回 nil
+func selectDashboard(activityID uint) (*model.ActivityDashboard, error) {
+	var d []model.ActivityDashboard
+	if err := database.DB.Where("activity_id = ?", activityID).Limit(1).Find(&d).Error; err != nil {
+		return nil, err
+	}
+	if len(d) == 0 {
+		return nil, nil
+	}
+	return &d[0], nil
+}
+
+// cohortActivity 首次打卡在 Cohort 期、在 Period 期有打卡的人数，均为该期开始日期
+type cohortActivity struct {
+	Cohort int64
+	Period int64
+	Users  uint
+}
+
+// selectCohortActivity 按首次打卡所在期与有打卡的期统计人数，starts 为各期开始日期，每期 n 天，
+// 可按学院、年级筛选用户
+func selectCohortActivity(activityID uint, day clock.Day, starts []time.Time, n int, college, grade string) ([]cohortActivity, error) {
+	var rows []cohortActivity
+	if len(starts) == 0 {
+		return rows, nil
+	}
+	// 每人有打卡的各期
+	userPeriods := joinPeriods(activityPunches(), day, starts, n).
+		Select("punch.user_id, d.date").
+		Where("project.activity_id = ? AND punch.deleted_at IS NULL", activityID)
+	if college != "" || grade != "" {
+		userPeriods = userPeriods.Joins("JOIN user ON user.id = punch.user_id")
+	}
+	if college != "" {
+		userPeriods = userPeriods.Where("user.college = ?", college)
+	}
+	if grade != "" {
+		userPeriods = userPeriods.Where("user.grade = ?", grade)
+	}
+	userPeriods = userPeriods.Group("punch.user_id, d.date")
+	firsts := database.DB.Table("(?) AS up", userPeriods).
+		Select("up.user_id, MIN(up.date) AS cohort").
+		Group("up.user_id")
+	err := database.DB.Table("(?) AS up", userPeriods).
+		Joins("JOIN (?) AS f ON f.user_id = up.user_id", firsts).
+		Select("f.cohort, up.date AS period, COUNT(*) AS users").
+		Group("f.cohort, up.date").
+		Scan(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,107 @@
+package export
+
+import (
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/jwt"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"activity-punch-system/tools"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// List 获取本人提交的导出任务
+func List(c *gin.Context) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return
+	}
+	offset, limit := tools.GetPage(c)
+	var total int64
+	var jobs []model.ExportJob
+	db := database.DB.Model(&model.ExportJob{}).Where("user_id = ?", user.ID)
+	if err := db.Count(&total).Error; err != nil {
+		Log.Error("数据库 查询导出任务失败", "error", err.Error())
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+	if err := db.Order("id DESC").Offset(offset).Limit(limit).Find(&jobs).Error; err != nil {
+		Log.Error("数据库 查询导出任务失败", "error", err.Error())
+		response.Fail(c, response.ErrDatabase)
+		return
+	}
+	response.Success(c, gin.H{
+		"total": total,
+		"list":  jobs,
+	})
+}
+
+// Status 查询导出任务的状态与进度
+func Status(c *gin.Context) {
+	job, ok := visibleJob(c)
+	if !ok {
+		return
+	}
+	response.Success(c, job)
+}
+
+// Download 下载已完成的导出文件
+func Download(c *gin.Context) {
+	job, ok := visibleJob(c)
+	if !ok {
+		return
+	}
+	switch job.Status {
+	case statusDone:
+	case statusExpired:
+		response.Fail(c, response.ErrNotFound.WithTips("导出文件已过期，请重新导出"))
+		return
+	case statusFailed:
+		response.Fail(c, response.ErrServerInternal.WithTips("导出失败: "+job.Error))
+		return
+	default:
+		response.Fail(c, response.ErrInvalidRequest.WithTips("导出尚未完成"))
+		return
+	}
+	if !tools.FileExist(job.FilePath) {
+		response.Fail(c, response.ErrNotFound.WithTips("导出文件不存在，请重新导出"))
+		return
+	}
+	if err := tools.SendStoredFile(c, job.FilePath, job.FileName, job.ContentType); err != nil {
+		Log.Error("发送文件错误", "error", err.Error())
+		response.Fail(c, response.ErrServerInternal)
+	}
+}
+
+// visibleJob 校验路径参数中的导出任务存在且请求者可见：本人提交或任务为共享
+func visibleJob(c *gin.Context) (*model.ExportJob, bool) {
+	user, ok := jwt.GetUserPayload(c)
+	if !ok {
+		response.Fail(c, response.ErrUnauthorized)
+		return nil, false
+	}
+	id, err := strconv.ParseUint(c.Param("job_id"), 10, 64)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRequest.WithTips("任务ID错误"))
+		return nil, false
+	}
+	var job model.ExportJob
+	if err := database.DB.First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrNotFound)
+			return nil, false
+		}
+		Log.Error("数据库 查询导出任务失败", "error", err.Error())
+		response.Fail(c, response.ErrDatabase)
+		return nil, false
+	}
+	if job.UserID != user.ID && !job.Shared {
+		response.Fail(c, response.ErrForbidden)
+		return nil, false
+	}
+	return &job, true
+}
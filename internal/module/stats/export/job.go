@@ -0,0 +1,243 @@
+// Package export 后台导出任务：提交后由 worker 异步生成文件，前端轮询进度，完成后下载，
+// 文件保存在 Storage.Home/stats/export 下，超过保留时长后删除
+package export
+
+import (
+	"activity-punch-system/config"
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/schedule"
+	"activity-punch-system/internal/model"
+	"activity-punch-system/tools"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var Log *slog.Logger
+
+const (
+	statusPending = iota
+	statusRunning
+	statusDone
+	statusFailed
+	statusExpired
+)
+
+const (
+	workerCount      = 2
+	pollInterval     = 5 * time.Second
+	staleAfter       = 30 * time.Minute // 进行中的任务超过该时长未更新视为实例已退出
+	defaultRetention = 72               // 默认保留时长（小时）
+)
+
+// Builder 将导出内容写入 w，并通过 progress 上报 0-100 的进度
+type Builder func(job *model.ExportJob, w io.Writer, progress func(percent int)) error
+
+var (
+	builders = map[string]Builder{}
+	wake     = make(chan struct{}, workerCount)
+)
+
+// Register 注册某一导出类型的生成函数，需在 Start 之前调用
+func Register(kind string, b Builder) {
+	builders[kind] = b
+}
+
+// Start 启动导出 worker 与过期文件清理任务
+func Start() {
+	if err := database.DB.Model(&model.ExportJob{}).
+		Where("status = ? AND updated_at < ?", statusRunning, time.Now().Add(-staleAfter)).
+		Updates(map[string]any{"status": statusFailed, "error": "任务中断"}).Error; err != nil {
+		Log.Error("数据库 重置中断的导出任务失败", "error", err.Error())
+	}
+	for i := 0; i < workerCount; i++ {
+		go worker()
+	}
+	schedule.Every("stats:export:expire", time.Hour, expire)
+}
+
+// Submit 创建导出任务并唤醒 worker
+func Submit(job *model.ExportJob) error {
+	if _, ok := builders[job.Kind]; !ok {
+		return fmt.Errorf("未知的导出类型: %s", job.Kind)
+	}
+	job.Status = statusPending
+	job.Progress = 0
+	if err := database.DB.Create(job).Error; err != nil {
+		return err
+	}
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Latest 查询 since 之后提交的、仍然有效（排队、进行中或已完成）的同类同格式导出任务，没有时返回 nil
+func Latest(kind string, activityID uint, format string, since time.Time) (*model.ExportJob, error) {
+	var job model.ExportJob
+	err := database.DB.Model(&model.ExportJob{}).
+		Where("kind = ? AND activity_id = ? AND format = ? AND created_at >= ?", kind, activityID, format, since).
+		Where("status IN ?", []int{statusPending, statusRunning, statusDone}).
+		Order("id DESC").
+		First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func worker() {
+	for {
+		job, err := claim()
+		if err != nil {
+			Log.Error("数据库 获取导出任务失败", "error", err.Error())
+		}
+		if job == nil {
+			select {
+			case <-wake:
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		run(job)
+	}
+}
+
+// claim 取出最早的排队任务并标记为进行中，多实例下以条件更新保证只有一个实例取得
+func claim() (*model.ExportJob, error) {
+	var jobs []model.ExportJob
+	if err := database.DB.Model(&model.ExportJob{}).
+		Where("status = ?", statusPending).
+		Order("id ASC").
+		Limit(workerCount).
+		Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		r := database.DB.Model(&model.ExportJob{}).
+			Where("id = ? AND status = ?", jobs[i].ID, statusPending).
+			Update("status", statusRunning)
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		if r.RowsAffected == 1 {
+			jobs[i].Status = statusRunning
+			return &jobs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func run(job *model.ExportJob) {
+	log := Log.With("job_id", job.ID, "kind", job.Kind, "activity_id", job.ActivityID)
+	path := filepath.Join(
+		config.Get().Storage.Home,
+		"stats",
+		"export",
+		fmt.Sprintf("%d_%s%s", job.ID, tools.RandString(8), filepath.Ext(job.FileName)),
+	)
+	err := build(job, path)
+	if err != nil {
+		_ = os.Remove(path)
+		log.Error("导出任务失败", "error", err.Error())
+		msg := []rune(err.Error())
+		if err := database.DB.Model(job).Updates(map[string]any{
+			"status":      statusFailed,
+			"error":       string(msg[:min(len(msg), 255)]),
+			"finished_at": time.Now(),
+		}).Error; err != nil {
+			log.Error("数据库 更新导出任务失败", "error", err.Error())
+		}
+		return
+	}
+	now := time.Now()
+	if err := database.DB.Model(job).Updates(map[string]any{
+		"status":      statusDone,
+		"progress":    100,
+		"file_path":   path,
+		"finished_at": now,
+		"expires_at":  now.Add(retention()),
+	}).Error; err != nil {
+		_ = os.Remove(path)
+		log.Error("数据库 更新导出任务失败", "error", err.Error())
+	}
+}
+
+func build(job *model.ExportJob, path string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("导出时发生 panic: %v", r)
+		}
+	}()
+	b, ok := builders[job.Kind]
+	if !ok {
+		return fmt.Errorf("未知的导出类型: %s", job.Kind)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	last := 0
+	progress := func(percent int) {
+		percent = max(0, min(percent, 99))
+		if percent == last {
+			return
+		}
+		last = percent
+		if err := database.DB.Model(job).Update("progress", percent).Error; err != nil {
+			Log.Warn("数据库 更新导出进度失败", "error", err.Error(), "job_id", job.ID)
+		}
+	}
+	if err := b(job, f, progress); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// expire 删除超过保留时长的导出文件
+func expire() {
+	var jobs []model.ExportJob
+	if err := database.DB.Model(&model.ExportJob{}).
+		Where("status = ? AND expires_at < ?", statusDone, time.Now()).
+		Find(&jobs).Error; err != nil {
+		Log.Error("数据库 查询过期导出任务失败", "error", err.Error())
+		return
+	}
+	for i := range jobs {
+		if err := os.Remove(jobs[i].FilePath); err != nil && !os.IsNotExist(err) {
+			Log.Error("删除过期导出文件失败", "error", err.Error(), "path", jobs[i].FilePath)
+			continue
+		}
+		if err := database.DB.Model(&jobs[i]).Updates(map[string]any{
+			"status":    statusExpired,
+			"file_path": "",
+		}).Error; err != nil {
+			Log.Error("数据库 更新导出任务失败", "error", err.Error(), "job_id", jobs[i].ID)
+		}
+	}
+	if len(jobs) > 0 {
+		Log.Info("过期导出文件清理完成", "count", len(jobs))
+	}
+}
+
+func retention() time.Duration {
+	hours := config.Get().Storage.ExportRetention
+	if hours <= 0 {
+		hours = defaultRetention
+	}
+	return time.Duration(hours) * time.Hour
+}
@@ -3,7 +3,11 @@ package stats
 import (
 	"activity-punch-system/internal/global/logger"
 	"activity-punch-system/internal/module/stats/activity"
+	"activity-punch-system/internal/module/stats/archive"
+	"activity-punch-system/internal/module/stats/calendar"
 	"activity-punch-system/internal/module/stats/column"
+	"activity-punch-system/internal/module/stats/dashboard"
+	"activity-punch-system/internal/module/stats/export"
 	"log/slog"
 )
 
@@ -19,4 +23,13 @@ func (*ModuleStats) Init() {
 	log = logger.New("Stats")
 	column.Log = log
 	activity.Log = log
+	activity.StartRankSnapshotScheduler()
+	calendar.Log = log
+	dashboard.Log = log
+	dashboard.StartScheduler()
+	export.Log = log
+	activity.RegisterExports()
+	archive.Log = log
+	archive.RegisterExports()
+	export.Start()
 }
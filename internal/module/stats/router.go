@@ -3,7 +3,11 @@ package stats
 import (
 	"activity-punch-system/internal/global/middleware"
 	"activity-punch-system/internal/module/stats/activity"
+	"activity-punch-system/internal/module/stats/archive"
+	"activity-punch-system/internal/module/stats/calendar"
 	"activity-punch-system/internal/module/stats/column"
+	"activity-punch-system/internal/module/stats/dashboard"
+	"activity-punch-system/internal/module/stats/export"
 	"github.com/gin-gonic/gin"
 )
 
@@ -24,7 +28,16 @@ func (*ModuleStats) InitRouter(r *gin.RouterGroup) {
 			activityCommon.POST("/:id/rank", activity.Rank)
 			activityCommon.POST("/:id/detail", activity.Detail)
 			activityCommon.GET("/:id/brief", activity.Brief)
-			activityCommon.GET("/:id/rank/export", activity.RankExport)
+			activityCommon.GET("/:id/tree", activity.Tree)
+			activityCommon.POST("/:id/rank/export", activity.RankExport)
+			activityCommon.GET("/:id/rank/export", activity.RankExport) // 兼容旧客户端，同样提交任务并返回任务信息
+		}
+		commonGroup.GET("/calendar", calendar.Calendar)
+		exportCommon := commonGroup.Group("/export")
+		{
+			exportCommon.GET("", export.List)
+			exportCommon.GET("/:job_id", export.Status)
+			exportCommon.GET("/:job_id/download", export.Download)
 		}
 	}
 	adminGroup := r.Group("/stats")
@@ -37,7 +50,15 @@ func (*ModuleStats) InitRouter(r *gin.RouterGroup) {
 		//}
 		activityAdmin := commonGroup.Group("/activity")
 		{
-			activityAdmin.GET("/:id/export", activity.Export)
+			activityAdmin.POST("/:id/export", activity.Export)
+			activityAdmin.GET("/:id/export", activity.Export) // 兼容旧客户端，同样提交任务并返回任务信息
+			activityAdmin.GET("/:id/dashboard", dashboard.Dashboard)
+			activityAdmin.GET("/:id/retention", dashboard.Retention)
+			activityAdmin.GET("/:id/retention/export", dashboard.RetentionExport)
 		}
+		// 打卡图片归档由活动成员角色校验权限
+		commonGroup.POST("/column/:id/images/export", archive.ColumnImages)
+		commonGroup.POST("/project/:id/images/export", archive.ProjectImages)
+		commonGroup.POST("/activity/:id/images/export", archive.ActivityImages)
 	}
 }
@@ -2,6 +2,8 @@
 // byd
 package tree
 
+import "fmt"
+
 type Record interface {
 	NextLayer() []Record
 	GetId() uint
@@ -9,54 +11,78 @@ type Record interface {
 }
 type BottomRecord interface {
 	Record
-	GetScore(string, int64, int64) float64
+	GetStat(userId uint, startTime, endTime int64) (Stat, error)
 }
-type Node struct {
-	Id       uint    `json:"id"`
-	Name     string  `json:"name"`
+
+// Stat 叶子节点(栏目)上统计出的分数与各审核状态的打卡数，上层节点为子节点之和
+type Stat struct {
 	Score    float64 `json:"score"`
+	Approved int64   `json:"approved_count"`
+	Pending  int64   `json:"pending_count"`
+	Rejected int64   `json:"rejected_count"`
+}
+
+func (s *Stat) add(o Stat) {
+	s.Score += o.Score
+	s.Approved += o.Approved
+	s.Pending += o.Pending
+	s.Rejected += o.Rejected
+}
+
+type Node struct {
+	Id   uint   `json:"id"`
+	Name string `json:"name"`
+	Stat
 	Children []*Node `json:"children"`
 }
 
-func Unfold3[A, P Record, C BottomRecord](a *A, userId string, startTime, endTime int64) *Node {
+func Unfold3[A, P Record, C BottomRecord](a *A, userId uint, startTime, endTime int64) (*Node, error) {
 	n := Node{
-		Id:    (*a).GetId(),
-		Name:  (*a).GetName(),
-		Score: 0.0,
+		Id:       (*a).GetId(),
+		Name:     (*a).GetName(),
+		Children: []*Node{},
 	}
 
 	for _, p := range (*a).NextLayer() {
 		pp, ok := (p).(P)
-		if ok {
-			panic("类型错误")
+		if !ok {
+			return nil, fmt.Errorf("类型错误: %T", p)
+		}
+		nn, err := Unfold2[P, C](&pp, userId, startTime, endTime)
+		if err != nil {
+			return nil, err
 		}
-		nn := Unfold2[P, C](&pp, userId, startTime, endTime)
 		n.Children = append(n.Children, nn)
-		n.Score += nn.Score
+		n.add(nn.Stat)
 	}
-	return &n
+	return &n, nil
 }
-func Unfold2[P Record, C BottomRecord](p *P, userId string, startTime, endTime int64) *Node {
+func Unfold2[P Record, C BottomRecord](p *P, userId uint, startTime, endTime int64) (*Node, error) {
 	n := Node{
-		Id:    (*p).GetId(),
-		Name:  (*p).GetName(),
-		Score: 0.0,
+		Id:       (*p).GetId(),
+		Name:     (*p).GetName(),
+		Children: []*Node{},
 	}
 
 	for _, c := range (*p).NextLayer() {
 		cc, ok := (c).(C)
 		if !ok {
-			panic("类型错误")
+			return nil, fmt.Errorf("类型错误: %T", c)
+		}
+		stat, err := cc.GetStat(userId, startTime, endTime)
+		if err != nil {
+			return nil, err
 		}
 		nn := Node{
-			Id:    cc.GetId(),
-			Name:  cc.GetName(),
-			Score: cc.GetScore(userId, startTime, endTime),
+			Id:       cc.GetId(),
+			Name:     cc.GetName(),
+			Stat:     stat,
+			Children: []*Node{},
 		}
 		n.Children = append(n.Children, &nn)
-		n.Score += nn.Score
+		n.add(nn.Stat)
 	}
-	return &n
+	return &n, nil
 }
 func ToRecordSlice[T Record](list []T) []Record {
 	result := make([]Record, len(list))
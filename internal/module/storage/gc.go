@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"activity-punch-system/config"
+	"activity-punch-system/internal/global/database"
+	"activity-punch-system/internal/global/pictureBed"
+	"activity-punch-system/internal/global/response"
+	"activity-punch-system/internal/model"
+	"activity-punch-system/tools"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultGCGrace = 72        // 默认保留时长（小时）
+	maxReportKeys  = 1000      // 报告中最多记录的孤儿对象 key 数
+	gcTimeout      = time.Hour // 单次清理的超时
+)
+
+// 孤儿对象清理报告状态
+const (
+	gcRunning = iota
+	gcDone
+	gcFailed
+)
+
+var (
+	// gcMu 同一实例内同一时刻只运行一次清理，定时任务在多实例间由 schedule 保证
+	gcMu         sync.Mutex
+	errGCRunning = errors.New("已有清理任务在运行")
+	errNoPrefix  = errors.New("未配置 S3.Prefix，拒绝清理整个存储桶")
+)
+
+// GCReq 定义手动触发清理请求的结构体
+type GCReq struct {
+	DryRun bool `json:"dry_run"` // 试运行：只生成报告不删除
+}
+
+// ListGCReports 分页查询清理报告，列表不返回孤儿对象 key
+func ListGCReports(c *gin.Context) {
+	offset, limit := tools.GetPage(c)
+	var total int64
+	var reports []model.StorageGCReport
+	db := database.DB.Model(&model.StorageGCReport{})
+	if err := db.Count(&total).Error; err != nil {
+		log.Error("查询清理报告失败", "error", err)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	if err := db.Omit("orphan_keys").Order("id DESC").Offset(offset).Limit(limit).Find(&reports).Error; err != nil {
+		log.Error("查询清理报告失败", "error", err)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	response.Success(c, gin.H{
+		"total": total,
+		"list":  reports,
+	})
+}
+
+// GetGCReport 查询单次清理的报告，包括孤儿对象 key
+func GetGCReport(c *gin.Context) {
+	var report model.StorageGCReport
+	if err := database.DB.First(&report, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrNotFound.WithTips("清理报告不存在"))
+			return
+		}
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	response.Success(c, report)
+}
+
+// RunGC 手动触发一次清理，在后台执行，通过报告查询结果
+func RunGC(c *gin.Context) {
+	var req GCReq
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.Fail(c, response.ErrInvalidRequest.WithOrigin(err))
+		return
+	}
+	report, err := startGC(req.DryRun, true)
+	if errors.Is(err, errGCRunning) {
+		response.Fail(c, response.ErrAlreadyExists.WithTips(err.Error()))
+		return
+	}
+	if err != nil {
+		log.Error("创建清理报告失败", "error", err)
+		response.Fail(c, response.ErrDatabase.WithOrigin(err))
+		return
+	}
+	go finishGC(report)
+	response.Success(c, report)
+}
+
+// scheduledGC 每日定时清理，S3.GCDryRun 开启时只生成报告
+func scheduledGC() {
+	report, err := startGC(config.Get().S3.GCDryRun, false)
+	if err != nil {
+		log.Error("启动定时清理失败", "error", err)
+		return
+	}
+	finishGC(report)
+}
+
+// startGC 获取清理锁并创建报告，成功后须调用 finishGC 释放锁
+func startGC(dryRun, manual bool) (*model.StorageGCReport, error) {
+	if !gcMu.TryLock() {
+		return nil, errGCRunning
+	}
+	report := &model.StorageGCReport{DryRun: dryRun, Manual: manual}
+	if err := database.DB.Create(report).Error; err != nil {
+		gcMu.Unlock()
+		return nil, err
+	}
+	return report, nil
+}
+
+// finishGC 执行清理并保存报告，结束后释放清理锁
+func finishGC(report *model.StorageGCReport) {
+	defer gcMu.Unlock()
+	report.Status = gcDone
+	if err := sweep(report); err != nil {
+		log.Error("清理孤儿对象失败", "error", err, "report", report.ID)
+		report.Status = gcFailed
+		report.Error = tools.Truncate(err.Error(), 255) // 与 error 列长度一致，过长的错误会导致报告无法保存
+	}
+	finished := time.Now()
+	report.FinishedAt = &finished
+	if err := database.DB.Save(report).Error; err != nil {
+		log.Error("保存清理报告失败", "error", err, "report", report.ID)
+	}
+	log.Info("清理孤儿对象完成", "report", report.ID, "dry_run", report.DryRun,
+		"scanned", report.Scanned, "orphaned", report.Orphaned, "deleted", report.Deleted, "failed", report.Failed, "bytes", report.Bytes)
+}
+
+// gcGrace 未被引用的对象保留时长
+func gcGrace() time.Duration {
+	hours := config.Get().S3.GCGrace
+	if hours <= 0 {
+		hours = defaultGCGrace
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// sweep 列出 Prefix 下的对象，删除未被引用且超过保留期的对象，结果写入 report。
+// 先列对象再收集引用，清理期间新增引用的对象不会被误删
+func sweep(report *model.StorageGCReport) error {
+	prefix := strings.Trim(config.Get().S3.Prefix, "/")
+	if prefix == "" {
+		return errNoPrefix
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gcTimeout)
+	defer cancel()
+	store, err := pictureBed.New(ctx)
+	if err != nil {
+		return err
+	}
+	objects, err := store.ListObjects(ctx, prefix+"/")
+	if err != nil {
+		return err
+	}
+	refs, err := collectReferences(store)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-gcGrace())
+	keys := make([]string, 0)
+	for _, obj := range objects {
+		report.Scanned++
+		if _, ok := refs[obj.Key]; ok {
+			report.Referenced++
+			continue
+		}
+		if obj.LastModified.After(cutoff) {
+			report.Recent++
+			continue
+		}
+		report.Orphaned++
+		report.Bytes += obj.Size
+		if len(keys) < maxReportKeys {
+			keys = append(keys, obj.Key)
+		}
+		if report.DryRun {
+			continue
+		}
+		if err := store.DeleteObject(ctx, obj.Key); err != nil {
+			log.Warn("删除孤儿对象失败", "key", obj.Key, "error", err)
+			report.Failed++
+			continue
+		}
+		report.Deleted++
+	}
+	report.OrphanKeys = strings.Join(keys, "\n")
+
+	if !report.DryRun {
+		// 超过保留期的上传 key 对应的对象已被引用或已清理，不再需要签发记录；
+		// 草稿中的图片提交时仍需校验归属，保留其签发记录
+		drafts, err := draftKeys(store)
+		if err != nil {
+			log.Warn("查询草稿图片失败", "error", err)
+			return nil
+		}
+		q := database.DB.Where("expires_at < ?", cutoff)
+		if len(drafts) > 0 {
+			q = q.Where("object_key NOT IN ?", drafts)
+		}
+		if err := q.Delete(&model.UploadKey{}).Error; err != nil {
+			log.Warn("清理过期上传 key 失败", "error", err)
+		}
+	}
+	return nil
+}
+
+// collectReferences 收集仍被引用的对象 key：未删除打卡的图片及其缩略图、中图、附件与历史版本，草稿中的图片，
+// 以及用户头像和活动、项目、栏目封面（含已删除的记录，以便恢复）
+func collectReferences(store pictureBed.Storage) (map[string]struct{}, error) {
+	refs := make(map[string]struct{})
+	add := func(urls []string) {
+		for _, u := range urls {
+			if key, ok := pictureBed.ResolveKey(store, u); ok {
+				refs[key] = struct{}{}
+			}
+		}
+	}
+	for _, col := range []string{"img_url", "thumb_url", "medium_url"} {
+		var urls []string
+		if err := database.DB.Table("punch_img").
+			Joins("JOIN punch ON punch.id = punch_img.punch_id AND punch.deleted_at IS NULL").
+			Where("punch_img.deleted_at IS NULL AND punch_img."+col+" <> ''").
+			Pluck("punch_img."+col, &urls).Error; err != nil {
+			return nil, err
+		}
+		add(urls)
+	}
+	var attachmentURLs []string
+	if err := database.DB.Table("punch_attachment").
+		Joins("JOIN punch ON punch.id = punch_attachment.punch_id AND punch.deleted_at IS NULL").
+		Where("punch_attachment.deleted_at IS NULL").
+		Pluck("punch_attachment.url", &attachmentURLs).Error; err != nil {
+		return nil, err
+	}
+	add(attachmentURLs)
+	// 历史版本中已被替换的图片与附件仍需保留，供审核与申诉核查
+	var revisions []model.PunchRevision
+	if err := database.DB.Table("punch_revision").Select("punch_revision.images, punch_revision.attachments").
+		Joins("JOIN punch ON punch.id = punch_revision.punch_id AND punch.deleted_at IS NULL").
+		Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	for _, rev := range revisions {
+		add(rev.Images)
+		add(rev.Attachments)
+	}
+	drafts, err := draftKeys(store)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range drafts {
+		refs[key] = struct{}{}
+	}
+	for _, table := range []string{"user", "activity", "project", "`column`"} {
+		var urls []string
+		if err := database.DB.Table(table).Where("avatar <> ''").Pluck("avatar", &urls).Error; err != nil {
+			return nil, err
+		}
+		add(urls)
+	}
+	return refs, nil
+}
+
+// draftKeys 打卡草稿中引用的图片 key
+func draftKeys(store pictureBed.Storage) ([]string, error) {
+	var drafts []model.PunchDraft
+	if err := database.DB.Select("images").Find(&drafts).Error; err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	for _, draft := range drafts {
+		for _, ref := range draft.Images {
+			if key, ok := pictureBed.ResolveKey(store, ref); ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"activity-punch-system/internal/global/logger"
+	"activity-punch-system/internal/global/schedule"
+	"log/slog"
+)
+
+var log *slog.Logger
+
+type ModuleStorage struct{}
+
+func (*ModuleStorage) GetName() string {
+	return "Storage"
+}
+
+func (*ModuleStorage) Init() {
+	log = logger.New("Storage")
+	schedule.Daily("storage:gc", 4, 30, scheduledGC)
+}
@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"activity-punch-system/internal/global/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (*ModuleStorage) InitRouter(r *gin.RouterGroup) {
+	// 对象存储维护，仅系统管理员可用
+	adminGroup := r.Group("/storage")
+	adminGroup.Use(middleware.Auth(1))
+	{
+		// 孤儿对象清理：查询报告、手动触发（可试运行）
+		adminGroup.GET("/gc", ListGCReports)
+		adminGroup.GET("/gc/:id", GetGCReport)
+		adminGroup.POST("/gc", RunGC)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/xuri/excelize/v2"
 )
 
+// ExportToExcel 将结构体切片按 excel 标签写入工作表，空切片不创建工作表
 func ExportToExcel(f *excelize.File, sheet string, data interface{}) error {
 	v := reflect.ValueOf(data)
 	if v.Kind() != reflect.Slice {
@@ -31,42 +32,7 @@ func ExportToExcel(f *excelize.File, sheet string, data interface{}) error {
 		return err
 	}
 
-	type fieldInfo struct {
-		index  []int
-		header string
-	}
-
-	var fields []fieldInfo
-
-	var collect func(t reflect.Type, parent []int)
-	collect = func(t reflect.Type, parent []int) {
-		for i := 0; i < t.NumField(); i++ {
-			sf := t.Field(i)
-
-			if sf.PkgPath != "" {
-				continue
-			}
-
-			idx := append(append([]int(nil), parent...), i)
-
-			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
-				collect(sf.Type, idx)
-				continue
-			}
-
-			tag := sf.Tag.Get("excel")
-			if tag == "-" {
-				continue
-			}
-			if tag == "" {
-				tag = sf.Name
-			}
-
-			fields = append(fields, fieldInfo{index: idx, header: tag})
-		}
-	}
-
-	collect(elemType, nil)
+	fields := exportFields(elemType)
 
 	// 写表头
 	for i, fi := range fields {
@@ -74,7 +40,7 @@ func ExportToExcel(f *excelize.File, sheet string, data interface{}) error {
 		if err != nil {
 			return err
 		}
-		if err := f.SetCellValue(sheet, cell, fi.header); err != nil {
+		if err := f.SetCellValue(sheet, cell, fi.Header); err != nil {
 			return err
 		}
 	}
@@ -90,20 +56,10 @@ func ExportToExcel(f *excelize.File, sheet string, data interface{}) error {
 			elem = elem.Elem()
 		}
 
-		for colIndex, fi := range fields {
-			fv := elem.FieldByIndex(fi.index)
-
-			var value interface{}
-			if fv.Kind() == reflect.Ptr {
-				if fv.IsNil() {
-					value = ""
-				} else {
-					value = fv.Elem().Interface()
-				}
-			} else {
-				value = fv.Interface()
+		for colIndex, value := range fieldValues(elem, fields) {
+			if value == nil {
+				value = ""
 			}
-
 			cell, err := excelize.CoordinatesToCellName(colIndex+1, row+2)
 			if err != nil {
 				return err
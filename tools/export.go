@@ -0,0 +1,370 @@
+package tools
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 导出格式
+const (
+	FormatExcel = "xlsx"
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+const (
+	CSVContentType   = "text/csv; charset=utf-8"
+	JSONLContentType = "application/x-ndjson"
+	ZipContentType   = "application/zip"
+)
+
+// utf8BOM 写在 CSV 开头，Excel 据此按 UTF-8 打开
+const utf8BOM = "\xEF\xBB\xBF"
+
+// ExportField 由结构体字段的 excel 标签得到的一列，Header 为表头，Key 为 JSON Lines 中的键（取 json 标签）
+type ExportField struct {
+	Header string
+	Key    string
+	index  []int
+}
+
+// TableWriter 按表逐行写出数据，同一时刻只写一张表，BeginTable 会结束上一张表
+type TableWriter interface {
+	BeginTable(name string, fields []ExportField) error
+	WriteRow(values []any) error
+	Close() error
+}
+
+// NewTableWriter 创建指定格式的 TableWriter，multiTable 为 true 时 CSV 以 zip 打包每张表一个文件，
+// JSON Lines 在每行加上 _table 字段
+func NewTableWriter(format string, w io.Writer, multiTable bool) (TableWriter, error) {
+	switch format {
+	case FormatExcel:
+		return &excelWriter{out: w, f: excelize.NewFile()}, nil
+	case FormatCSV:
+		if multiTable {
+			return &csvWriter{zw: zip.NewWriter(w)}, nil
+		}
+		return &csvWriter{out: w}, nil
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w), multiTable: multiTable}, nil
+	default:
+		return nil, fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// ExportFileType 返回导出文件的扩展名与 Content-Type
+func ExportFileType(format string, multiTable bool) (ext, contentType string, err error) {
+	switch format {
+	case FormatExcel:
+		return ".xlsx", ExcelContentType, nil
+	case FormatCSV:
+		if multiTable {
+			return ".zip", ZipContentType, nil
+		}
+		return ".csv", CSVContentType, nil
+	case FormatJSONL:
+		return ".jsonl", JSONLContentType, nil
+	default:
+		return "", "", fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// Table 一张正在写出的表，逐行写入与 sample 同类型的结构体
+type Table struct {
+	w      TableWriter
+	typ    reflect.Type
+	fields []ExportField
+}
+
+// BeginTable 以 sample 的类型（结构体或其指针）确定列并写出表头
+func BeginTable(w TableWriter, name string, sample any) (*Table, error) {
+	t := reflect.TypeOf(sample)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%T 不是结构体", sample)
+	}
+	fields := exportFields(t)
+	if err := w.BeginTable(name, fields); err != nil {
+		return nil, err
+	}
+	return &Table{w: w, typ: t, fields: fields}, nil
+}
+
+// Write 写入一行，row 为结构体或其指针，nil 指针跳过
+func (t *Table) Write(row any) error {
+	v := reflect.ValueOf(row)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() != t.typ {
+		return fmt.Errorf("行类型 %s 与表类型 %s 不一致", v.Type(), t.typ)
+	}
+	return t.w.WriteRow(fieldValues(v, t.fields))
+}
+
+// WriteTable 将结构体切片写为一张表，空切片也会写出表头
+func WriteTable(w TableWriter, name string, data any) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("data %v 不是切片", data)
+	}
+	table, err := BeginTable(w, name, reflect.Zero(v.Type().Elem()).Interface())
+	if err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := table.Write(v.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportFields 按 excel 标签收集可导出的字段，匿名嵌入的结构体展开，标签为 - 的字段跳过
+func exportFields(t reflect.Type) []ExportField {
+	var fields []ExportField
+	var collect func(t reflect.Type, parent []int)
+	collect = func(t reflect.Type, parent []int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+
+			if sf.PkgPath != "" {
+				continue
+			}
+
+			idx := append(append([]int(nil), parent...), i)
+
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				collect(sf.Type, idx)
+				continue
+			}
+
+			tag := sf.Tag.Get("excel")
+			if tag == "-" {
+				continue
+			}
+			if tag == "" {
+				tag = sf.Name
+			}
+			key, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+			if key == "" || key == "-" {
+				key = sf.Name
+			}
+
+			fields = append(fields, ExportField{Header: tag, Key: key, index: idx})
+		}
+	}
+	collect(t, nil)
+	return fields
+}
+
+// fieldValues 取出一行各列的值，nil 指针取 nil
+func fieldValues(v reflect.Value, fields []ExportField) []any {
+	values := make([]any, len(fields))
+	for i, fi := range fields {
+		fv := v.FieldByIndex(fi.index)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		values[i] = fv.Interface()
+	}
+	return values
+}
+
+func headers(fields []ExportField) []string {
+	h := make([]string, len(fields))
+	for i, f := range fields {
+		h[i] = f.Header
+	}
+	return h
+}
+
+// excelWriter 以 StreamWriter 逐行写入工作表，Close 时输出整个工作簿
+type excelWriter struct {
+	out    io.Writer
+	f      *excelize.File
+	sw     *excelize.StreamWriter
+	row    int
+	sheets int
+}
+
+func (e *excelWriter) BeginTable(name string, fields []ExportField) error {
+	if err := e.flush(); err != nil {
+		return err
+	}
+	if name == "" {
+		name = fmt.Sprintf("Sheet%d", e.sheets+2)
+	}
+	if _, err := e.f.NewSheet(name); err != nil {
+		return err
+	}
+	sw, err := e.f.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+	e.sw, e.row = sw, 0
+	e.sheets++
+	h := headers(fields)
+	row := make([]any, len(h))
+	for i := range h {
+		row[i] = h[i]
+	}
+	return e.WriteRow(row)
+}
+
+func (e *excelWriter) WriteRow(values []any) error {
+	if e.sw == nil {
+		return fmt.Errorf("尚未开始写表")
+	}
+	e.row++
+	cell, err := excelize.CoordinatesToCellName(1, e.row)
+	if err != nil {
+		return err
+	}
+	row := make([]any, len(values))
+	for i, v := range values {
+		if v == nil {
+			v = ""
+		}
+		row[i] = v
+	}
+	return e.sw.SetRow(cell, row)
+}
+
+func (e *excelWriter) flush() error {
+	if e.sw == nil {
+		return nil
+	}
+	err := e.sw.Flush()
+	e.sw = nil
+	return err
+}
+
+func (e *excelWriter) Close() error {
+	defer func() { _ = e.f.Close() }()
+	if err := e.flush(); err != nil {
+		return err
+	}
+	if e.sheets > 0 {
+		_ = e.f.DeleteSheet("Sheet1")
+	}
+	return e.f.Write(e.out)
+}
+
+// csvWriter 写出带 BOM 的 UTF-8 CSV，zw 不为 nil 时每张表写为 zip 中的一个文件
+type csvWriter struct {
+	out    io.Writer
+	zw     *zip.Writer
+	cw     *csv.Writer
+	tables int
+}
+
+func (c *csvWriter) BeginTable(name string, fields []ExportField) error {
+	if c.cw != nil {
+		c.cw.Flush()
+		if err := c.cw.Error(); err != nil {
+			return err
+		}
+	}
+	c.tables++
+	var w io.Writer = c.out
+	if c.zw != nil {
+		if name == "" {
+			name = fmt.Sprintf("table%d", c.tables)
+		}
+		fw, err := c.zw.Create(fmt.Sprintf("%02d_%s.csv", c.tables, name))
+		if err != nil {
+			return err
+		}
+		w = fw
+	} else if c.tables > 1 {
+		return fmt.Errorf("单个 CSV 文件只能写一张表")
+	}
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+	c.cw = csv.NewWriter(w)
+	return c.cw.Write(headers(fields))
+}
+
+func (c *csvWriter) WriteRow(values []any) error {
+	if c.cw == nil {
+		return fmt.Errorf("尚未开始写表")
+	}
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = csvValue(v)
+	}
+	return c.cw.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	if c.cw != nil {
+		c.cw.Flush()
+		if err := c.cw.Error(); err != nil {
+			return err
+		}
+	}
+	if c.zw != nil {
+		return c.zw.Close()
+	}
+	return nil
+}
+
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.DateTime)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// jsonlWriter 每行输出一个 JSON 对象，键取自 json 标签
+type jsonlWriter struct {
+	enc        *json.Encoder
+	multiTable bool
+	table      string
+	fields     []ExportField
+}
+
+func (j *jsonlWriter) BeginTable(name string, fields []ExportField) error {
+	j.table, j.fields = name, fields
+	return nil
+}
+
+func (j *jsonlWriter) WriteRow(values []any) error {
+	obj := make(map[string]any, len(values)+1)
+	for i, v := range values {
+		obj[j.fields[i].Key] = v
+	}
+	if j.multiTable {
+		obj["_table"] = j.table
+	}
+	return j.enc.Encode(obj)
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// RowError 导入时某一行的错误，Row 为工作表中的行号（表头为第 1 行）
+type RowError struct {
+	Sheet   string `json:"sheet"`
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e RowError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("%s 第 %d 行 %s: %s", e.Sheet, e.Row, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%s 第 %d 行: %s", e.Sheet, e.Row, e.Message)
+}
+
+// ReadExcelTable 按 excel 标签将工作表读入 out 指向的结构体切片，是 ExportToExcel 的逆过程。
+// 表头按标签匹配，未知表头与 time.Time 字段（创建、更新时间等只读列）忽略，空行跳过；
+// rows 为每个元素在工作表中的行号，单元格无法转换时记入 errs 且该行不写入 out
+func ReadExcelTable(f *excelize.File, sheet string, out any) (rows []int, errs []RowError, err error) {
+	pv := reflect.ValueOf(out)
+	if pv.Kind() != reflect.Ptr || pv.Elem().Kind() != reflect.Slice {
+		return nil, nil, fmt.Errorf("out %T 不是切片指针", out)
+	}
+	sv := pv.Elem()
+	elemType := sv.Type().Elem()
+	if elemType.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("out %T 不是结构体切片指针", out)
+	}
+
+	byHeader := map[string]ExportField{}
+	for _, fi := range exportFields(elemType) {
+		byHeader[fi.Header] = fi
+	}
+
+	data, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil, nil
+	}
+	columns := make([]*ExportField, len(data[0]))
+	for i, h := range data[0] {
+		if fi, ok := byHeader[strings.TrimSpace(h)]; ok {
+			columns[i] = &fi
+		}
+	}
+
+	for r, cells := range data[1:] {
+		rowNum := r + 2
+		if isBlankRow(cells) {
+			continue
+		}
+		elem := reflect.New(elemType).Elem()
+		ok := true
+		for i, cell := range cells {
+			if i >= len(columns) || columns[i] == nil {
+				continue
+			}
+			if err := setCell(elem.FieldByIndex(columns[i].index), strings.TrimSpace(cell)); err != nil {
+				errs = append(errs, RowError{Sheet: sheet, Row: rowNum, Column: columns[i].Header, Message: err.Error()})
+				ok = false
+			}
+		}
+		if ok {
+			sv.Set(reflect.Append(sv, elem))
+			rows = append(rows, rowNum)
+		}
+	}
+	return rows, errs, nil
+}
+
+func isBlankRow(cells []string) bool {
+	for _, c := range cells {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// setCell 将单元格文本转换为字段类型，空单元格保留零值（指针为 nil）
+func setCell(fv reflect.Value, s string) error {
+	t := fv.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if s == "" || t == reflect.TypeOf(time.Time{}) {
+		return nil
+	}
+	if fv.Kind() == reflect.Ptr {
+		v := reflect.New(fv.Type().Elem())
+		if err := setCell(v.Elem(), s); err != nil {
+			return err
+		}
+		fv.Set(v)
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		switch strings.ToLower(s) {
+		case "1", "true", "是", "y", "yes":
+			fv.SetBool(true)
+		case "0", "false", "否", "n", "no":
+			fv.SetBool(false)
+		default:
+			return fmt.Errorf("%q 不是布尔值", s)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := parseInteger(s)
+		if err != nil || fv.OverflowInt(n) {
+			return fmt.Errorf("%q 不是整数", s)
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := parseInteger(s)
+		if err != nil || n < 0 || fv.OverflowUint(uint64(n)) {
+			return fmt.Errorf("%q 不是非负整数", s)
+		}
+		fv.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%q 不是数字", s)
+		}
+		fv.SetFloat(n)
+	default:
+		return fmt.Errorf("不支持的字段类型 %s", fv.Type())
+	}
+	return nil
+}
+
+// parseInteger 解析整数，兼容 Excel 以浮点形式保存的整数（如 20250101 存为 2.0250101E7）
+func parseInteger(s string) (int64, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != float64(int64(f)) {
+		return 0, fmt.Errorf("%q 不是整数", s)
+	}
+	return int64(f), nil
+}
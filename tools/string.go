@@ -19,3 +19,17 @@ func RandString(length int) string {
 
 	return sb.String()
 }
+
+// Truncate 按字符截取字符串的前 n 个字符，不会截断多字节字符
+func Truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// ShortName 截取名称的前 10 个字符，用于导出文件名、工作表名与压缩包内的目录名
+func ShortName(name string) string {
+	return Truncate(name, 10)
+}